@@ -1,11 +1,29 @@
 package benchmark
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
+	"math"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
 	"time"
 
+	"github.com/jonny-burkholder/swarm/internal/dashboard"
+	"github.com/jonny-burkholder/swarm/internal/loader"
 	"github.com/jonny-burkholder/swarm/internal/logger"
+	"github.com/jonny-burkholder/swarm/internal/metrics"
+	"github.com/jonny-burkholder/swarm/internal/models"
+	"github.com/jonny-burkholder/swarm/internal/ramp"
+	"github.com/jonny-burkholder/swarm/internal/results"
+	defaulthttp "github.com/jonny-burkholder/swarm/internal/runners/default/http"
+	"github.com/jonny-burkholder/swarm/internal/sink"
 )
 
 type BenchmarkCommand struct {
@@ -13,33 +31,68 @@ type BenchmarkCommand struct {
 	Logger logger.Logger
 
 	// Flag values
-	Collection string
-	Config     string
-	LogLevel   string
-	Runs       int
-	Concurrent int
-	Duration   time.Duration
-	Async      bool
-	Save       bool
-	Out        string
+	Collection  string
+	Config      string
+	LogLevel    string
+	Runs        int
+	Concurrent  int
+	Duration    time.Duration
+	Timeout     time.Duration
+	Async       bool
+	MaxInFlight int
+	MaxBodySize int64
+	DiscardBody bool
+	KeepFailed  bool
+	KeepAll     bool
+	Save        bool
+	Out         string
+	Stream      string
+	Progress    string
+	MetricsAddr string
+	Push        string
+	PushTo      string
+	TestID      string
+	Ramp        string
+	RampShape   string
+	RampSteps   int
+	Rate        float64
+	MaxWorkers  int
+
+	ExpectedInterval time.Duration
+	PushInterval     time.Duration
+
+	// stages is the ramp, from --ramp or the config file
+	stages []models.Stage
+
+	// flags is kept so we know which values were set explicitly,
+	// and so should win over the config file
+	flags *flag.FlagSet
 }
 
 // NewBenchmarkCommand creates a new benchmark command with default values
 func NewBenchmarkCommand() *BenchmarkCommand {
 	return &BenchmarkCommand{
 		// Set sensible defaults
-		LogLevel:   "info",
-		Runs:       1,
-		Concurrent: 1,
-		Duration:   0, // 0 means use runs instead of duration
-		Async:      false,
-		Save:       false,
-		Out:        "stdout",
+		LogLevel:    "info",
+		Runs:        1,
+		Concurrent:  1,
+		Duration:    0, // 0 means use runs instead of duration
+		Timeout:     30 * time.Second,
+		MaxBodySize: 1 << 20, // 1MiB
+		Async:       false,
+		Save:        false,
+		Out:         "stdout",
+		Progress:    "auto",
+		RampShape:   models.RampLinear,
+
+		PushInterval: 10 * time.Second,
 	}
 }
 
 // SetupFlags configures the flag set for the benchmark command
 func (b *BenchmarkCommand) SetupFlags(fs *flag.FlagSet) {
+	b.flags = fs
+
 	// Required flags
 	fs.StringVar(&b.Collection, "collection", b.Collection, "Collection file to run benchmarks against")
 	fs.StringVar(&b.Collection, "c", b.Collection, "Collection file to run benchmarks against (short)")
@@ -57,18 +110,49 @@ func (b *BenchmarkCommand) SetupFlags(fs *flag.FlagSet) {
 	fs.DurationVar(&b.Duration, "duration", b.Duration, "Duration to run tests (e.g., 30s, 5m). If set, overrides --runs")
 	fs.DurationVar(&b.Duration, "d", b.Duration, "Duration to run tests (short)")
 
+	fs.DurationVar(&b.Timeout, "timeout", b.Timeout, "Timeout for each request (e.g., 5s). 0 means no timeout")
+	fs.DurationVar(&b.Timeout, "t", b.Timeout, "Timeout for each request (short)")
+
 	fs.BoolVar(&b.Async, "async", b.Async, "Run requests asynchronously within each worker")
 	fs.BoolVar(&b.Async, "a", b.Async, "Run requests asynchronously within each worker (short)")
 
+	fs.IntVar(&b.MaxInFlight, "max-in-flight", b.MaxInFlight, "Maximum requests each async worker sends at once. 0 means no limit")
+	fs.IntVar(&b.MaxInFlight, "m", b.MaxInFlight, "Maximum requests each async worker sends at once (short)")
+
+	fs.Int64Var(&b.MaxBodySize, "max-body-size", b.MaxBodySize, "Maximum bytes of each response body to keep. 0 means keep it all")
+	fs.BoolVar(&b.DiscardBody, "discard-body", b.DiscardBody, "Don't keep response bodies at all, only count their size")
+	fs.BoolVar(&b.KeepFailed, "keep-failed", b.KeepFailed, "Keep the runs that failed, so they're saved with the results. Every run is counted in the report either way")
+	fs.BoolVar(&b.KeepAll, "keep-all", b.KeepAll, "Keep every run, so they're all saved with the results. Takes a lot of memory on a long benchmark")
+
+	// Arrival rate flags
+	fs.Float64Var(&b.Rate, "rate", b.Rate, "Start this many runs a second, however long they take, instead of whenever a worker is free. With --ramp, the rate the ramp starts from")
+	fs.IntVar(&b.MaxWorkers, "max-workers", b.MaxWorkers, "With --rate, how many workers there can be when --concurrent aren't enough. Runs that are due when they're all busy are dropped")
+
+	fs.DurationVar(&b.ExpectedInterval, "expected-interval", b.ExpectedInterval, "Also report latencies corrected for coordinated omission, taking each worker to have been meant to send a request this often")
+
+	// Ramp flags
+	fs.StringVar(&b.Ramp, "ramp", b.Ramp, "Stages of workers to ramp through, like 50:1m,50:5m:constant,0:30s. Overrides --concurrent, and --runs if there's no --duration")
+	fs.StringVar(&b.RampShape, "ramp-shape", b.RampShape, "Shape of ramp stages that don't set their own: constant, linear, step, exponential, or logarithmic")
+	fs.IntVar(&b.RampSteps, "ramp-steps", b.RampSteps, "How many steps step stages take. 0 means the default")
+
 	// Output flags
 	fs.StringVar(&b.LogLevel, "log-level", b.LogLevel, "Log level (debug, info, warn, error)")
 	fs.StringVar(&b.LogLevel, "l", b.LogLevel, "Log level (short)")
 
-	fs.BoolVar(&b.Save, "save", b.Save, "Save benchmark results to disk")
+	fs.BoolVar(&b.Save, "save", b.Save, "Save benchmark results to SWARMPATH/results")
 	fs.BoolVar(&b.Save, "s", b.Save, "Save benchmark results to disk (short)")
 
-	fs.StringVar(&b.Out, "out", b.Out, "Output destination (stdout, file path, or format)")
+	fs.StringVar(&b.Out, "out", b.Out, "Output destination: stdout, a file path, or json. Paths ending in .json get the full results")
 	fs.StringVar(&b.Out, "o", b.Out, "Output destination (short)")
+
+	fs.StringVar(&b.Stream, "stream", b.Stream, "File to write every run to as json lines, as soon as it finishes")
+	fs.StringVar(&b.Progress, "progress", b.Progress, "How to show progress while running: live, plain, off, or auto for live on a terminal and plain lines otherwise")
+	fs.StringVar(&b.MetricsAddr, "metrics-addr", b.MetricsAddr, "Serve prometheus metrics at /metrics on this address while running, like localhost:9090. A port on its own is on localhost")
+
+	fs.StringVar(&b.Push, "push", b.Push, "Push metrics while running to statsd, influx, or otlp")
+	fs.StringVar(&b.PushTo, "push-to", b.PushTo, "Where to push metrics: host:port for statsd (localhost:8125 by default), the write url for influx, or the metrics url for otlp (http://localhost:4318/v1/metrics by default)")
+	fs.DurationVar(&b.PushInterval, "push-interval", b.PushInterval, "How often to push metrics")
+	fs.StringVar(&b.TestID, "test-id", b.TestID, "Tag pushed metrics with this, to tell benchmarks apart. Defaults to the collection file's name and the time")
 }
 
 // Validate checks that the provided flags are valid
@@ -77,44 +161,397 @@ func (b *BenchmarkCommand) Validate() error {
 		return fmt.Errorf("collection file is required (use -c or --collection)")
 	}
 
-	if b.Runs <= 0 && b.Duration <= 0 {
+	if b.Runs <= 0 && b.Duration <= 0 && len(b.stages) == 0 {
 		return fmt.Errorf("either --runs or --duration must be specified and greater than 0")
 	}
 
-	if b.Concurrent <= 0 {
+	if b.Concurrent <= 0 && len(b.stages) == 0 && b.Rate == 0 {
 		return fmt.Errorf("concurrent workers must be greater than 0")
 	}
 
-	validLogLevels := map[string]bool{
-		"debug": true,
-		"info":  true,
-		"warn":  true,
-		"error": true,
+	if b.Rate < 0 || b.MaxWorkers < 0 {
+		return fmt.Errorf("rate and max workers can't be negative")
 	}
 
-	if !validLogLevels[b.LogLevel] {
-		return fmt.Errorf("invalid log level '%s', must be one of: debug, info, warn, error", b.LogLevel)
+	if b.ExpectedInterval < 0 {
+		return fmt.Errorf("expected interval can't be negative")
+	}
+
+	if b.ExpectedInterval > 0 && b.Rate > 0 {
+		return fmt.Errorf("--expected-interval can't be used with --rate, which already measures latency from when each request was due")
+	}
+
+	if b.MaxInFlight < 0 {
+		return fmt.Errorf("max in-flight requests can't be negative")
+	}
+
+	if b.MaxBodySize < 0 {
+		return fmt.Errorf("max body size can't be negative")
+	}
+
+	if b.Timeout < 0 {
+		return fmt.Errorf("timeout can't be negative")
+	}
+
+	switch b.Progress {
+	case "auto", "live", "plain", "off":
+	default:
+		return fmt.Errorf("unknown progress '%s', must be one of: auto, live, plain, off", b.Progress)
+	}
+
+	switch b.Push {
+	case "", "statsd", "otlp":
+	case "influx":
+		if b.PushTo == "" {
+			return fmt.Errorf("pushing to influx needs its write url (use --push-to)")
+		}
+	default:
+		return fmt.Errorf("unknown push '%s', must be one of: statsd, influx, otlp", b.Push)
+	}
+
+	if b.PushInterval <= 0 {
+		return fmt.Errorf("push interval must be greater than 0")
+	}
+
+	if _, err := logger.ParseLevel(b.LogLevel); err != nil {
+		return err
 	}
 
 	return nil
 }
 
+// Run loads the collection and config, runs the benchmark, and writes the results
 func (b *BenchmarkCommand) Run() error {
+	b.defaults()
+	if b.Ramp != "" {
+		stages, err := ramp.ParseStages(b.Ramp, b.RampShape, b.RampSteps)
+		if err != nil {
+			return fmt.Errorf("validation failed: %w", err)
+		}
+		b.stages = stages
+	}
+	if err := b.loadConfig(); err != nil {
+		return fmt.Errorf("loading config: %w", err)
+	}
 	if err := b.Validate(); err != nil {
 		return fmt.Errorf("validation failed: %w", err)
 	}
 
-	// TODO: Implement the actual benchmark logic
-	fmt.Printf("Running benchmark with:\n")
-	fmt.Printf("  Collection: %s\n", b.Collection)
-	fmt.Printf("  Config: %s\n", b.Config)
-	fmt.Printf("  Runs: %d\n", b.Runs)
-	fmt.Printf("  Duration: %v\n", b.Duration)
-	fmt.Printf("  Concurrent: %d\n", b.Concurrent)
-	fmt.Printf("  Async: %t\n", b.Async)
-	fmt.Printf("  Save: %t\n", b.Save)
-	fmt.Printf("  Output: %s\n", b.Out)
-	fmt.Printf("  Log Level: %s\n", b.LogLevel)
+	if b.Logger == nil {
+		lvl, _ := logger.ParseLevel(b.LogLevel) // already validated
+		b.Logger = logger.DefaultLogger(lvl)
+	}
+
+	collections, err := loader.LoadCollections(b.Collection)
+	if err != nil {
+		return fmt.Errorf("loading collection: %w", err)
+	}
+
+	cfg := b.config()
+	var dash *dashboard.Dashboard
+	// what's logged from other goroutines while the benchmark runs. b.Logger isn't
+	// swapped out for it, since those goroutines would race with the swap
+	runLog := b.Logger
+	var held *logger.Held
+	var profile *ramp.Profile
+	if len(cfg.Ramp) > 0 {
+		if profile, err = ramp.NewProfile(cfg.Ramp); err != nil {
+			return fmt.Errorf("ramp: %w", err)
+		}
+		if cfg.Rate > 0 {
+			// the ramp is of the rate, starting from --rate
+			profile.Start = cfg.Rate
+		} else {
+			// there need to be enough workers for the busiest part of the ramp
+			cfg.Concurrent = max(int(math.Ceil(profile.Peak())), 1)
+		}
+		// unless told otherwise the benchmark lasts as long as the ramp does
+		if cfg.Duration <= 0 {
+			cfg.Duration = profile.Duration()
+		}
+	}
+	if b.Runner == nil {
+		runner := defaulthttp.New(cfg)
+		if profile != nil {
+			runner.Ramp = profile.At
+		}
+		if b.Stream != "" {
+			stream, err := sink.NewFile(b.Stream)
+			if err != nil {
+				return fmt.Errorf("creating stream file: %w", err)
+			}
+			runner.Sink = sink.Multi(runner.Sink, stream)
+		}
+		if dash = b.dashboard(cfg, runner.Busy); dash != nil {
+			runner.Sink = sink.Multi(runner.Sink, dash)
+			if dash.Live() {
+				// logs go to the same terminal, and would land in the middle of the view
+				held = logger.Hold(b.Logger)
+				runLog = held
+			}
+		}
+		if b.MetricsAddr != "" {
+			m := metrics.New()
+			m.Workers, m.InFlight = runner.Busy, runner.InFlight
+			srv, err := m.Serve(metricsAddr(b.MetricsAddr))
+			if err != nil {
+				return fmt.Errorf("serving metrics: %w", err)
+			}
+			defer srv.Close()
+			b.Logger.Info("serving metrics", "url", "http://"+srv.Addr+"/metrics")
+			runner.Sink = sink.Multi(runner.Sink, m)
+		}
+		if b.Push != "" {
+			exporter, err := b.exporter()
+			if err != nil {
+				return fmt.Errorf("pushing metrics: %w", err)
+			}
+			pusher := metrics.NewPusher(exporter, b.testID(), b.PushInterval)
+			pusher.Workers, pusher.InFlight = runner.Busy, runner.InFlight
+			pusher.OnError = func(err error) {
+				runLog.Warn("couldn't push metrics", "error", err.Error())
+			}
+			runner.Sink = sink.Multi(runner.Sink, pusher)
+		}
+		b.Runner = runner
+	}
+
+	toRun := make([]*models.Collection, len(collections))
+	for i := range collections {
+		toRun[i] = &collections[i]
+	}
+
+	// the first ctrl-c stops the benchmark gracefully. Once that's happened we
+	// stop listening, so a second one kills swarm straight away
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	// deferred after stop so that it runs first, and finishing normally doesn't look like an interrupt
+	defer context.AfterFunc(ctx, func() {
+		stop()
+		runLog.Warn("benchmark interrupted, waiting for in-flight requests to finish")
+	})()
+
+	b.Logger.Info("starting benchmark", "collection", b.Collection, "runs", cfg.Runs, "duration", cfg.Duration.String(), "concurrent", cfg.Concurrent, "async", cfg.Async)
+	start := time.Now()
+	err = b.Runner.Run(ctx, toRun)
+	end := time.Now()
+	if held != nil {
+		held.Release()
+	}
+	b.Logger.Info("benchmark finished", "elapsed", end.Sub(start).String())
+	for _, collection := range toRun {
+		runs := len(collection.Runs)
+		if collection.Aggregates != nil {
+			// runs aren't all kept, but they're all aggregated
+			runs = collection.Aggregates.Runs
+		}
+		b.Logger.Info("collection finished", "collection", collection.Name, "runs", runs)
+	}
+
+	// write out whatever we have, even if some collections failed
+	file := b.resultsFile(cfg, toRun, start, end)
+	if b.Save {
+		path, saveErr := file.Save(saveDir())
+		if saveErr != nil {
+			saveErr = fmt.Errorf("saving results: %w", saveErr)
+		} else {
+			b.Logger.Info("results saved", "path", path)
+		}
+		err = errors.Join(err, saveErr)
+	}
+
+	return errors.Join(err, b.writeReport(toRun, file))
+}
+
+// defaults looks in SWARMPATH for a collection and config, if they weren't passed in
+func (b *BenchmarkCommand) defaults() {
+	swarmpath := os.Getenv("SWARMPATH")
+	if swarmpath == "" {
+		return
+	}
+
+	if b.Collection == "" {
+		path := filepath.Join(swarmpath, "collection.yml")
+		if _, err := os.Stat(path); err == nil {
+			b.Collection = path
+		}
+	}
+
+	if b.Config == "" {
+		path := filepath.Join(swarmpath, "config.yml")
+		if _, err := os.Stat(path); err == nil {
+			b.Config = path
+		}
+	}
+}
+
+// loadConfig applies the values in the config file, if there is one.
+// Flags that were passed explicitly take precedence
+func (b *BenchmarkCommand) loadConfig() error {
+	if b.Config == "" {
+		return nil
+	}
+
+	cfg, err := loader.LoadConfig(b.Config, b.config())
+	if err != nil {
+		return err
+	}
+
+	set := map[string]bool{}
+	if b.flags != nil {
+		b.flags.Visit(func(f *flag.Flag) {
+			set[f.Name] = true
+		})
+	}
+
+	if !set["runs"] && !set["r"] {
+		b.Runs = cfg.Runs
+	}
+	if !set["concurrent"] && !set["n"] {
+		b.Concurrent = cfg.Concurrent
+	}
+	if !set["async"] && !set["a"] {
+		b.Async = cfg.Async
+	}
+	if !set["max-in-flight"] && !set["m"] {
+		b.MaxInFlight = cfg.MaxInFlight
+	}
+	if !set["duration"] && !set["d"] {
+		b.Duration = cfg.Duration
+	}
+	if !set["timeout"] && !set["t"] {
+		b.Timeout = cfg.Timeout
+	}
+	if !set["max-body-size"] {
+		b.MaxBodySize = cfg.MaxBodySize
+	}
+	if !set["discard-body"] {
+		b.DiscardBody = cfg.DiscardBody
+	}
+	if !set["keep-failed"] {
+		b.KeepFailed = cfg.KeepFailed
+	}
+	if !set["keep-all"] {
+		b.KeepAll = cfg.KeepAll
+	}
+	if !set["ramp"] {
+		b.stages = cfg.Ramp
+	}
+	if !set["rate"] {
+		b.Rate = cfg.Rate
+	}
+	if !set["max-workers"] {
+		b.MaxWorkers = cfg.MaxWorkers
+	}
+	if !set["expected-interval"] {
+		b.ExpectedInterval = cfg.ExpectedInterval
+	}
 
 	return nil
 }
+
+// config builds the runner config from the flag values
+func (b *BenchmarkCommand) config() models.Config {
+	return models.Config{
+		Runs:        b.Runs,
+		Concurrent:  b.Concurrent,
+		Async:       b.Async,
+		MaxInFlight: b.MaxInFlight,
+		Duration:    b.Duration,
+		Timeout:     b.Timeout,
+		MaxBodySize: b.MaxBodySize,
+		DiscardBody: b.DiscardBody,
+		KeepFailed:  b.KeepFailed,
+		KeepAll:     b.KeepAll,
+		Ramp:        b.stages,
+		Rate:        b.Rate,
+		MaxWorkers:  b.MaxWorkers,
+
+		ExpectedInterval: b.ExpectedInterval,
+	}
+}
+
+// dashboard creates the dashboard --progress asks for, or nil if it's off. The live view goes
+// on stdout, before the report. Plain lines go to stderr with the logs, so they don't end up
+// in a report that's piped somewhere
+func (b *BenchmarkCommand) dashboard(cfg models.Config, workers func() int) *dashboard.Dashboard {
+	mode := b.Progress
+	if mode == "auto" {
+		mode = "plain"
+		if dashboard.IsTerminal(os.Stdout) {
+			mode = "live"
+		}
+	}
+
+	opts := dashboard.Options{Duration: cfg.Duration, Workers: workers}
+	switch mode {
+	case "live":
+		opts.TTY = true
+		return dashboard.New(os.Stdout, opts)
+	case "plain":
+		return dashboard.New(os.Stderr, opts)
+	default:
+		return nil
+	}
+}
+
+// metricsAddr is addr, on localhost if it's only a port
+func metricsAddr(addr string) string {
+	if !strings.Contains(addr, ":") {
+		return "localhost:" + addr
+	}
+	return addr
+}
+
+// exporter creates the exporter --push asks for
+func (b *BenchmarkCommand) exporter() (metrics.Exporter, error) {
+	switch b.Push {
+	case "statsd":
+		addr := b.PushTo
+		if addr == "" {
+			addr = "localhost:8125"
+		}
+		return metrics.NewStatsD(addr)
+	case "influx":
+		return metrics.NewInflux(b.PushTo, os.Getenv("INFLUX_TOKEN")), nil
+	default:
+		url := b.PushTo
+		if url == "" {
+			url = "http://localhost:4318/v1/metrics"
+		}
+		return metrics.NewOTLP(url), nil
+	}
+}
+
+// testID is --test-id, or the collection file's name and the time if it wasn't set,
+// the same way saved results are named
+func (b *BenchmarkCommand) testID() string {
+	if b.TestID != "" {
+		return b.TestID
+	}
+	base := strings.TrimSuffix(filepath.Base(b.Collection), filepath.Ext(b.Collection))
+	return fmt.Sprintf("%s-%s", base, time.Now().Format("20060102-150405"))
+}
+
+// writeReport writes the text report to stdout or the file in --out. If --out is "json", or
+// a file ending in .json, the results file is written instead
+func (b *BenchmarkCommand) writeReport(collections []*models.Collection, file results.File) error {
+	if b.Out == "json" {
+		return json.NewEncoder(os.Stdout).Encode(file)
+	}
+	if strings.EqualFold(filepath.Ext(b.Out), ".json") {
+		return file.Write(b.Out)
+	}
+
+	var w io.Writer = os.Stdout
+	if b.Out != "stdout" {
+		f, err := os.Create(b.Out)
+		if err != nil {
+			return fmt.Errorf("creating output file: %w", err)
+		}
+		defer f.Close()
+		w = f
+	}
+
+	return writeReport(w, collections, b.ExpectedInterval)
+}
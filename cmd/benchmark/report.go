@@ -0,0 +1,120 @@
+package benchmark
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jonny-burkholder/swarm/internal/models"
+	"github.com/jonny-burkholder/swarm/internal/results"
+)
+
+// mean is the mean of total over the requests in a that got a response
+func mean(a models.Aggregate, total time.Duration) time.Duration {
+	timed := a.Count - a.Errors
+	if timed == 0 {
+		return 0
+	}
+	return (total / time.Duration(timed)).Round(time.Microsecond)
+}
+
+// writeReport writes a plain text summary of each collection's runs to w. interval is the expected
+// interval to correct latencies for coordinated omission with, if there is one
+func writeReport(w io.Writer, collections []*models.Collection, interval time.Duration) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	for _, collection := range collections {
+		aggs := collection.Aggregates
+		if aggs == nil {
+			// the runner didn't aggregate, so work it out from whatever runs it kept
+			aggs = models.NewAggregates(collection.Requests)
+			for _, run := range collection.Runs {
+				aggs.Add(run)
+			}
+		}
+
+		fmt.Fprintf(tw, "Collection: %s\n", collection.Name)
+		fmt.Fprintf(tw, "Runs: %d (%d incomplete)\n", aggs.Runs, aggs.Incomplete)
+		if collection.Dropped > 0 || collection.Late > 0 {
+			fmt.Fprintf(tw, "Dropped: %d, late: %d (due when every worker was busy, or started late)\n", collection.Dropped, collection.Late)
+		}
+		fmt.Fprintln(tw)
+		fmt.Fprintln(tw, "REQUEST\tSENT\tERRORS\tFAILED ASSERTIONS\tBYTES\tSTATUS CODES")
+		for _, a := range aggs.Requests {
+			fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%d\t%s\n", a.Name, a.Count, a.Errors, a.Failed, a.Bytes, formatStatuses(a.StatusCodes))
+		}
+		fmt.Fprintln(tw)
+
+		for _, a := range aggs.Requests {
+			for _, key := range slices.Sorted(maps.Keys(a.Failures)) {
+				f := a.Failures[key]
+				fmt.Fprintf(tw, "%s: %s failed %d times, e.g. %s\n", a.Name, key, f.Count, f.Example)
+			}
+		}
+		fmt.Fprintln(tw)
+
+		fmt.Fprintln(tw, "REQUEST (MEAN)\tTOTAL\tDNS\tCONNECT\tTLS\tTTFB\tTRANSFER\tREUSED CONNS")
+		for _, a := range aggs.Requests {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%d/%d\n", a.Name,
+				round(time.Duration(a.Latency.Mean())), mean(a, a.Phases.DNS), mean(a, a.Phases.Connect), mean(a, a.Phases.TLS),
+				mean(a, a.Phases.TTFB), mean(a, a.Phases.Transfer), a.Reused, a.Count-a.Errors)
+		}
+		fmt.Fprintln(tw)
+
+		writeLatencies(tw, aggs, collection.End.Sub(collection.Start), interval)
+	}
+
+	return tw.Flush()
+}
+
+// writeLatencies writes the latency percentiles of each request, and of the collection as a whole.
+// If there are latencies corrected for coordinated omission, they're written out too
+func writeLatencies(w io.Writer, aggs *models.Aggregates, elapsed, interval time.Duration) {
+	all := []results.Aggregate{}
+	for _, a := range append(slices.Clone(aggs.Requests), aggs.Total) {
+		all = append(all, results.NewAggregate(a, elapsed, interval))
+	}
+
+	fmt.Fprintln(w, "REQUEST (LATENCY)\tMIN\tMEAN\tSTDDEV\tP50\tP90\tP95\tP99\tP99.9\tMAX\tREQ/S")
+	for _, agg := range all {
+		fmt.Fprintf(w, "%s\t%s\t%.1f\n", agg.Name, formatLatencies(agg.Latencies), agg.Throughput)
+	}
+	fmt.Fprintln(w)
+
+	if !slices.ContainsFunc(all, func(agg results.Aggregate) bool { return agg.Corrected != nil }) {
+		return
+	}
+	fmt.Fprintln(w, "REQUEST (CORRECTED)\tMIN\tMEAN\tSTDDEV\tP50\tP90\tP95\tP99\tP99.9\tMAX")
+	for _, agg := range all {
+		if agg.Corrected == nil {
+			// none of its requests had to wait, so there's nothing to correct
+			fmt.Fprintf(w, "%s\t-\t-\t-\t-\t-\t-\t-\t-\t-\n", agg.Name)
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%s\n", agg.Name, formatLatencies(*agg.Corrected))
+	}
+	fmt.Fprintln(w, "Corrected latencies count from when each request was due to be sent, and fill in the ones a slow response held up")
+	fmt.Fprintln(w)
+}
+
+func formatLatencies(l results.Latencies) string {
+	return fmt.Sprintf("%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s", round(l.Min), round(l.Mean), round(l.StdDev),
+		round(l.P50), round(l.P90), round(l.P95), round(l.P99), round(l.P999), round(l.Max))
+}
+
+func round(d time.Duration) time.Duration {
+	return d.Round(time.Microsecond)
+}
+
+// formatStatuses formats status code counts like "200x9 500x1"
+func formatStatuses(statuses map[int]int) string {
+	parts := []string{}
+	for _, code := range slices.Sorted(maps.Keys(statuses)) {
+		parts = append(parts, fmt.Sprintf("%dx%d", code, statuses[code]))
+	}
+	return strings.Join(parts, " ")
+}
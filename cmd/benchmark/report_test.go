@@ -0,0 +1,93 @@
+package benchmark
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jonny-burkholder/swarm/internal/models"
+)
+
+func TestWriteReportCorrected(t *testing.T) {
+	requests := []models.Request{{Name: "GET books"}, {Name: "GET shelves"}}
+	result := func(wait time.Duration) models.Result {
+		return models.Result{StatusCode: 200, Duration: 10 * time.Millisecond, Wait: wait}
+	}
+
+	tests := []struct {
+		name          string
+		runs          []models.Run
+		wantCorrected bool
+		wantMissing   []string // requests with no corrected latencies in the table
+	}{
+		{
+			name: "nothing had to wait",
+			runs: []models.Run{{Results: []models.Result{result(0), result(0)}}},
+		},
+		{
+			// the run that had to wait stopped after its first request, so the second has nothing to correct
+			name: "some requests waited",
+			runs: []models.Run{
+				{Results: []models.Result{result(time.Second)}, Error: errors.New("extracting id: no match")},
+				{Results: []models.Result{result(0), result(0)}},
+			},
+			wantCorrected: true,
+			wantMissing:   []string{"GET shelves"},
+		},
+		{
+			name: "every request waited",
+			runs: []models.Run{
+				{Results: []models.Result{result(time.Second), result(time.Second)}},
+				{Results: []models.Result{result(0), result(0)}},
+			},
+			wantCorrected: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			aggs := models.NewAggregates(requests)
+			for _, run := range tt.runs {
+				aggs.Add(run)
+			}
+			start := time.Now()
+			collection := &models.Collection{Name: "library", Requests: requests, Aggregates: aggs, Start: start, End: start.Add(time.Second)}
+
+			var b strings.Builder
+			if err := writeReport(&b, []*models.Collection{collection}, 0); err != nil {
+				t.Fatalf("writeReport() error = %v", err)
+			}
+			report := b.String()
+
+			_, corrected, found := strings.Cut(report, "REQUEST (CORRECTED)")
+			if found != tt.wantCorrected {
+				t.Fatalf("report has corrected latencies = %v, want %v\n%s", found, tt.wantCorrected, report)
+			}
+			if !found {
+				return
+			}
+			for _, name := range []string{"GET books", "GET shelves", "total"} {
+				min, ok := correctedMin(corrected, name)
+				if !ok {
+					t.Errorf("corrected latencies have no line for %s\n%s", name, report)
+					continue
+				}
+				if missing := min == "-"; missing != slices.Contains(tt.wantMissing, name) {
+					t.Errorf("%s corrected min = %s, want missing = %v", name, min, !missing)
+				}
+			}
+		})
+	}
+}
+
+// correctedMin finds the first column after the request's name in the corrected latencies
+func correctedMin(corrected, name string) (string, bool) {
+	for _, line := range strings.Split(corrected, "\n") {
+		if rest, ok := strings.CutPrefix(line, name+"  "); ok {
+			return strings.Fields(rest)[0], true
+		}
+	}
+	return "", false
+}
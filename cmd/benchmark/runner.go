@@ -7,5 +7,5 @@ import (
 )
 
 type Runner interface {
-	Run(ctx *context.Context, collections []*models.Collection) error
+	Run(ctx context.Context, collections []*models.Collection) error
 }
@@ -0,0 +1,46 @@
+package benchmark
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/jonny-burkholder/swarm/internal/models"
+	"github.com/jonny-burkholder/swarm/internal/results"
+	"github.com/jonny-burkholder/swarm/internal/version"
+)
+
+// resultsFile builds the results file for a finished benchmark, run with cfg
+func (b *BenchmarkCommand) resultsFile(cfg models.Config, collections []*models.Collection, start, end time.Time) results.File {
+	host, _ := os.Hostname()
+	meta := results.Meta{
+		Host:           host,
+		GitSHA:         gitSHA(filepath.Dir(b.Collection)),
+		Start:          start,
+		End:            end,
+		SwarmVersion:   version.Version,
+		CollectionFile: b.Collection,
+	}
+
+	return results.New(meta, cfg, collections)
+}
+
+// saveDir is where results are saved: SWARMPATH/results, or ./results without a SWARMPATH
+func saveDir() string {
+	return filepath.Join(os.Getenv("SWARMPATH"), "results")
+}
+
+// gitSHA returns the commit checked out in the repo dir is in, or an empty string if it isn't
+// in one. Collections usually live alongside the api they test, so this tells us which version
+// of the api was benchmarked
+func gitSHA(dir string) string {
+	cmd := exec.Command("git", "rev-parse", "HEAD")
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
@@ -0,0 +1,135 @@
+package compare
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jonny-burkholder/swarm/internal/models"
+)
+
+// Violation is a request in a candidate that went over budget
+type Violation struct {
+	Collection string `json:"collection"`
+	Request    string `json:"request"`
+	Candidate  string `json:"candidate"`
+	Metric     string `json:"metric"`
+	Budget     string `json:"budget"`
+	Message    string `json:"message"`
+}
+
+func (v Violation) String() string {
+	return fmt.Sprintf("%s / %s in %s: %s", v.Collection, v.Request, v.Candidate, v.Message)
+}
+
+// checkBudgets finds every request in every candidate that's over budget. A relative latency
+// budget is only broken if the request is also a significant regression, so that noise
+// doesn't fail a build. A budgeted request that's missing from a candidate is over budget
+// too, and a budget that doesn't match any request is an error, since it's most likely a typo
+func checkBudgets(c Comparison, budgets []models.Budget) ([]Violation, error) {
+	matched := make([]bool, len(budgets))
+	violations := []Violation{}
+	for _, r := range c.Requests {
+		for i, d := range r.Deltas {
+			changes := map[string]Change{}
+			for _, change := range d.Changes {
+				changes[change.Metric] = change
+			}
+
+			for j, b := range budgets {
+				if !b.Matches(r.Collection, r.Request) {
+					continue
+				}
+				matched[j] = true
+
+				// Stats[0] is the baseline, so this delta's candidate is i+1
+				if r.Stats[i+1].Missing {
+					violations = append(violations, Violation{
+						Collection: r.Collection,
+						Request:    r.Request,
+						Candidate:  d.Candidate,
+						Message:    "missing from the candidate, so it can't be checked against its budget",
+					})
+					continue
+				}
+
+				for _, limit := range b.Limits {
+					change, ok := changes[limit.Metric]
+					if !ok {
+						// the request is missing from the baseline, so there's nothing to compare with
+						continue
+					}
+					msg, over := overBudget(limit, change, d.Verdict)
+					if !over {
+						continue
+					}
+					violations = append(violations, Violation{
+						Collection: r.Collection,
+						Request:    r.Request,
+						Candidate:  d.Candidate,
+						Metric:     limit.Metric,
+						Budget:     limit.Raw,
+						Message:    msg,
+					})
+				}
+			}
+		}
+	}
+
+	for i, b := range budgets {
+		if !matched[i] {
+			return nil, fmt.Errorf("%w: collection %s, request %s", ErrUnmatchedBudget, orAny(b.Collection), orAny(b.Request))
+		}
+	}
+
+	return violations, nil
+}
+
+// orAny quotes name, or says any if it's empty
+func orAny(name string) string {
+	if name == "" {
+		return "any"
+	}
+	return "'" + name + "'"
+}
+
+// overBudget checks one change against its limit, and says how it's over if it is
+func overBudget(limit models.Limit, change Change, verdict string) (string, bool) {
+	higherIsBetter, _ := models.BudgetMetric(limit.Metric)
+	from, to := formatValue(change.Baseline, change.Unit), formatValue(change.Candidate, change.Unit)
+
+	if limit.Relative {
+		if change.Unit == "ns" && verdict == NoChange {
+			return "", false
+		}
+		if change.Percent == nil {
+			// the baseline was 0, so any growth at all is more than any percent
+			if !change.Worse {
+				return "", false
+			}
+			return fmt.Sprintf("%s grew from nothing (%s -> %s), budget is %s", limit.Metric, from, to, limit.Raw), true
+		}
+		pct := *change.Percent
+		if (higherIsBetter && pct < limit.Value) || (!higherIsBetter && pct > limit.Value) {
+			return fmt.Sprintf("%s changed %s (%s -> %s), budget is %s", limit.Metric, formatPercent(&pct), from, to, limit.Raw), true
+		}
+		return "", false
+	}
+
+	if (higherIsBetter && change.Candidate < limit.Value) || (!higherIsBetter && change.Candidate > limit.Value) {
+		word := "at most"
+		if higherIsBetter {
+			word = "at least"
+		}
+		return fmt.Sprintf("%s is %s (was %s), budget is %s %s", limit.Metric, to, from, word, limit.Raw), true
+	}
+	return "", false
+}
+
+// summary lists every violation, one per line
+func summary(violations []Violation) string {
+	lines := make([]string, len(violations))
+	for i, v := range violations {
+		lines[i] = "  " + v.String()
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,186 @@
+package compare
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jonny-burkholder/swarm/internal/models"
+	"github.com/jonny-burkholder/swarm/internal/results"
+)
+
+func TestOverBudget(t *testing.T) {
+	percent := func(p float64) *float64 { return &p }
+	ms := func(n float64) float64 { return n * float64(time.Millisecond) }
+
+	tests := []struct {
+		name    string
+		limit   models.Limit
+		change  Change
+		verdict string
+		want    string // empty if it's within budget
+	}{
+		{
+			name:    "latency grew less than its budget",
+			limit:   models.Limit{Metric: "p95", Relative: true, Value: 10, Raw: "+10%"},
+			change:  Change{Metric: "p95", Unit: "ns", Baseline: ms(100), Candidate: ms(105), Percent: percent(5), Worse: true},
+			verdict: Regressed,
+		},
+		{
+			name:    "latency grew more than its budget",
+			limit:   models.Limit{Metric: "p95", Relative: true, Value: 10, Raw: "+10%"},
+			change:  Change{Metric: "p95", Unit: "ns", Baseline: ms(100), Candidate: ms(120), Percent: percent(20), Worse: true},
+			verdict: Regressed,
+			want:    "p95 changed +20.0% (100ms -> 120ms), budget is +10%",
+		},
+		{
+			name:    "latency grew, but not significantly",
+			limit:   models.Limit{Metric: "p95", Relative: true, Value: 10, Raw: "+10%"},
+			change:  Change{Metric: "p95", Unit: "ns", Baseline: ms(100), Candidate: ms(120), Percent: percent(20), Worse: true},
+			verdict: NoChange,
+		},
+		{
+			name:   "throughput dropped more than its budget",
+			limit:  models.Limit{Metric: "throughput", Relative: true, Value: -5, Raw: "-5%"},
+			change: Change{Metric: "throughput", Unit: "req/s", Baseline: 100, Candidate: 90, Percent: percent(-10), Worse: true},
+			want:   "throughput changed -10.0% (100.0 -> 90.0), budget is -5%",
+		},
+		{
+			name:   "throughput grew",
+			limit:  models.Limit{Metric: "throughput", Relative: true, Value: -5, Raw: "-5%"},
+			change: Change{Metric: "throughput", Unit: "req/s", Baseline: 100, Candidate: 150, Percent: percent(50), Better: true},
+		},
+		{
+			name:   "error rate grew from nothing",
+			limit:  models.Limit{Metric: "error_rate", Relative: true, Value: 10, Raw: "+10%"},
+			change: Change{Metric: "error_rate", Unit: "%", Baseline: 0, Candidate: 2, Worse: true},
+			want:   "error_rate grew from nothing (0.00% -> 2.00%), budget is +10%",
+		},
+		{
+			name:   "error rate stayed at nothing",
+			limit:  models.Limit{Metric: "error_rate", Relative: true, Value: 10, Raw: "+10%"},
+			change: Change{Metric: "error_rate", Unit: "%", Percent: percent(0)},
+		},
+		{
+			name:   "absolute error rate",
+			limit:  models.Limit{Metric: "error_rate", Value: 0.5, Raw: "0.5%"},
+			change: Change{Metric: "error_rate", Unit: "%", Baseline: 0.1, Candidate: 1, Percent: percent(900), Worse: true},
+			want:   "error_rate is 1.00% (was 0.10%), budget is at most 0.5%",
+		},
+		{
+			name:    "absolute latency, whatever the verdict",
+			limit:   models.Limit{Metric: "p99", Value: ms(300), Raw: "300ms"},
+			change:  Change{Metric: "p99", Unit: "ns", Baseline: ms(310), Candidate: ms(320), Percent: percent(3.2), Worse: true},
+			verdict: NoChange,
+			want:    "p99 is 320ms (was 310ms), budget is at most 300ms",
+		},
+		{
+			name:   "absolute throughput",
+			limit:  models.Limit{Metric: "throughput", Value: 250, Raw: "250"},
+			change: Change{Metric: "throughput", Unit: "req/s", Baseline: 300, Candidate: 200, Percent: percent(-33.3), Worse: true},
+			want:   "throughput is 200.0 (was 300.0), budget is at least 250",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, over := overBudget(tt.limit, tt.change, tt.verdict)
+			if over != (tt.want != "") {
+				t.Fatalf("overBudget() over = %v (%s), want %v", over, got, tt.want != "")
+			}
+			if got != tt.want {
+				t.Errorf("overBudget() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckBudgets(t *testing.T) {
+	percent := func(p float64) *float64 { return &p }
+	c := Comparison{Requests: []RequestComparison{
+		{
+			Collection: "library",
+			Request:    "GET books",
+			Stats:      []Stats{{}, {}},
+			Deltas: []Delta{{Candidate: "b.json", Changes: []Change{
+				{Metric: "error_rate", Unit: "%", Baseline: 1, Candidate: 2, Percent: percent(100), Worse: true},
+			}}},
+		},
+		{
+			Collection: "library",
+			Request:    "POST books",
+			Stats:      []Stats{{}, {Missing: true}},
+			Deltas:     []Delta{{Candidate: "b.json"}},
+		},
+	}}
+
+	tests := []struct {
+		name    string
+		budgets []models.Budget
+		want    []string
+		wantErr error
+	}{
+		{
+			name:    "over budget",
+			budgets: []models.Budget{{Request: "GET books", Limits: []models.Limit{{Metric: "error_rate", Relative: true, Value: 10, Raw: "+10%"}}}},
+			want:    []string{"library / GET books in b.json: error_rate changed +100.0% (1.00% -> 2.00%), budget is +10%"},
+		},
+		{
+			name:    "within budget",
+			budgets: []models.Budget{{Request: "GET books", Limits: []models.Limit{{Metric: "error_rate", Value: 5, Raw: "5%"}}}},
+		},
+		{
+			name:    "missing from the candidate",
+			budgets: []models.Budget{{Request: "POST books", Limits: []models.Limit{{Metric: "p95", Relative: true, Value: 10, Raw: "+10%"}}}},
+			want:    []string{"library / POST books in b.json: missing from the candidate, so it can't be checked against its budget"},
+		},
+		{
+			name:    "budget that matches nothing",
+			budgets: []models.Budget{{Collection: "libary", Limits: []models.Limit{{Metric: "p95", Relative: true, Value: 10, Raw: "+10%"}}}},
+			wantErr: ErrUnmatchedBudget,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			violations, err := checkBudgets(c, tt.budgets)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("checkBudgets() error = %v, want %v", err, tt.wantErr)
+			}
+			if len(violations) != len(tt.want) {
+				t.Fatalf("checkBudgets() = %v, want %q", violations, tt.want)
+			}
+			for i, v := range violations {
+				if v.String() != tt.want[i] {
+					t.Errorf("violation %d = %q, want %q", i, v.String(), tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestCheckBudgetsServerErrors(t *testing.T) {
+	file := func(ok, serverErrors int) results.File {
+		total := results.Aggregate{
+			Name:         "total",
+			Count:        ok + serverErrors,
+			Unsuccessful: serverErrors,
+			StatusCodes:  map[int]int{200: ok, 500: serverErrors},
+		}
+		return results.File{Collections: []results.Collection{{
+			Name:       "library",
+			Aggregates: results.Aggregates{Total: total},
+		}}}
+	}
+	c := compare([]string{"a.json", "b.json"}, []results.File{file(30, 0), file(20, 10)}, options{alpha: 0.05})
+
+	budgets := []models.Budget{{Limits: []models.Limit{{Metric: "error_rate", Value: 0.5, Raw: "0.5%"}}}}
+	violations, err := checkBudgets(c, budgets)
+	if err != nil {
+		t.Fatalf("checkBudgets() error = %v", err)
+	}
+	want := "library / total in b.json: error_rate is 33.33% (was 0.00%), budget is at most 0.5%"
+	if len(violations) != 1 || violations[0].String() != want {
+		t.Errorf("checkBudgets() = %v, want %q", violations, want)
+	}
+}
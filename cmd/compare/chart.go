@@ -0,0 +1,96 @@
+package compare
+
+import (
+	"fmt"
+	"html/template"
+	"strings"
+	"time"
+)
+
+// color is the i'th file's color in the charts, the baseline first. They're reused if there are more files
+func color(i int) string {
+	palette := [...]string{"#7a869a", "#2f80ed", "#f2994a", "#9b51e0", "#27ae60", "#eb5757"}
+	return palette[i%len(palette)]
+}
+
+// chart dimensions, in svg units
+const (
+	chartWidth  = 640
+	chartHeight = 220
+	marginLeft  = 70
+	marginRight = 10
+	marginTop   = 10
+	marginBot   = 30
+)
+
+// chart draws the latency percentiles of a request in every file as a grouped bar chart. It's
+// plain svg so that the report doesn't need anything but itself to be viewed
+func chart(r RequestComparison) template.HTML {
+	groups := []struct {
+		name  string
+		value func(Stats) time.Duration
+	}{
+		{"p50", func(s Stats) time.Duration { return s.P50 }},
+		{"p90", func(s Stats) time.Duration { return s.P90 }},
+		{"p95", func(s Stats) time.Duration { return s.P95 }},
+		{"p99", func(s Stats) time.Duration { return s.P99 }},
+		{"p99.9", func(s Stats) time.Duration { return s.P999 }},
+	}
+
+	top := time.Duration(0)
+	for _, s := range r.Stats {
+		for _, g := range groups {
+			top = max(top, g.value(s))
+		}
+	}
+	if top == 0 {
+		return ""
+	}
+	top = top * 11 / 10
+
+	plotW := float64(chartWidth - marginLeft - marginRight)
+	plotH := float64(chartHeight - marginTop - marginBot)
+	y := func(d time.Duration) float64 {
+		return marginTop + plotH - float64(d)/float64(top)*plotH
+	}
+
+	b := &strings.Builder{}
+	fmt.Fprintf(b, `<svg class="chart" viewBox="0 0 %d %d" xmlns="http://www.w3.org/2000/svg">`, chartWidth, chartHeight)
+
+	// gridlines, with the latency they're at
+	for i := 0; i <= 4; i++ {
+		d := top * time.Duration(i) / 4
+		fmt.Fprintf(b, `<line x1="%d" x2="%d" y1="%.1f" y2="%.1f" class="grid"/>`, marginLeft, chartWidth-marginRight, y(d), y(d))
+		fmt.Fprintf(b, `<text x="%d" y="%.1f" class="axis" text-anchor="end">%s</text>`, marginLeft-6, y(d)+4, template.HTMLEscapeString(roundDuration(d).String()))
+	}
+
+	groupW := plotW / float64(len(groups))
+	barW := groupW * 0.8 / float64(len(r.Stats))
+	for gi, g := range groups {
+		x0 := marginLeft + groupW*float64(gi) + groupW*0.1
+		for i, s := range r.Stats {
+			if s.Missing {
+				continue
+			}
+			v := g.value(s)
+			fmt.Fprintf(b, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" fill="%s"><title>%s: %s</title></rect>`,
+				x0+barW*float64(i), y(v), barW, marginTop+plotH-y(v), color(i), g.name, template.HTMLEscapeString(roundDuration(v).String()))
+		}
+		fmt.Fprintf(b, `<text x="%.1f" y="%d" class="axis" text-anchor="middle">%s</text>`, x0+groupW*0.4, chartHeight-marginBot+18, g.name)
+	}
+
+	b.WriteString(`</svg>`)
+	return template.HTML(b.String())
+}
+
+// roundDuration rounds to something readable, depending on how big d is
+func roundDuration(d time.Duration) time.Duration {
+	switch {
+	case d >= time.Second:
+		return d.Round(10 * time.Millisecond)
+	case d >= time.Millisecond:
+		return d.Round(10 * time.Microsecond)
+	default:
+		return d.Round(time.Microsecond)
+	}
+}
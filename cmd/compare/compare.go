@@ -3,19 +3,30 @@ package compare
 import (
 	"flag"
 	"fmt"
+	"io"
+	"os"
+
+	"github.com/jonny-burkholder/swarm/internal/loader"
+	"github.com/jonny-burkholder/swarm/internal/models"
+	"github.com/jonny-burkholder/swarm/internal/results"
 )
 
 type CompareCommand struct {
 	// Flag values
-	Out    string
-	Format string
+	Out       string
+	Format    string
+	Alpha     float64
+	Resamples int
+	Budget    string
 }
 
 // NewCompareCommand creates a new compare command with default values
 func NewCompareCommand() *CompareCommand {
 	return &CompareCommand{
-		Out:    "stdout",
-		Format: "html",
+		Out:       "stdout",
+		Format:    "html",
+		Alpha:     0.05,
+		Resamples: 1000,
 	}
 }
 
@@ -26,6 +37,12 @@ func (c *CompareCommand) SetupFlags(fs *flag.FlagSet) {
 
 	fs.StringVar(&c.Format, "format", c.Format, "Output format (html, json, csv)")
 	fs.StringVar(&c.Format, "f", c.Format, "Output format (short)")
+
+	fs.Float64Var(&c.Alpha, "alpha", c.Alpha, "Significance level. Changes with a p value at or above this count as no change")
+	fs.IntVar(&c.Resamples, "resamples", c.Resamples, "Number of bootstrap resamples for percentile confidence intervals. 0 skips them")
+
+	fs.StringVar(&c.Budget, "budget", c.Budget, "File of regression budgets. If any is exceeded, compare exits with an error")
+	fs.StringVar(&c.Budget, "b", c.Budget, "File of regression budgets (short)")
 }
 
 // Validate checks that the provided flags are valid
@@ -40,10 +57,19 @@ func (c *CompareCommand) Validate() error {
 		return fmt.Errorf("invalid format '%s', must be one of: html, json, csv", c.Format)
 	}
 
+	if c.Alpha <= 0 || c.Alpha >= 1 {
+		return fmt.Errorf("alpha must be between 0 and 1")
+	}
+
+	if c.Resamples < 0 {
+		return fmt.Errorf("resamples can't be negative")
+	}
+
 	return nil
 }
 
-// Run executes the compare command
+// Run loads the results files in args, compares each of them to the first, and writes the
+// comparison in the chosen format
 func (c *CompareCommand) Run(args []string) error {
 	if err := c.Validate(); err != nil {
 		return fmt.Errorf("validation failed: %w", err)
@@ -53,11 +79,54 @@ func (c *CompareCommand) Run(args []string) error {
 		return fmt.Errorf("compare requires at least 2 benchmark result files")
 	}
 
-	// TODO: Implement the actual compare logic
-	fmt.Printf("Comparing benchmark results with:\n")
-	fmt.Printf("  Format: %s\n", c.Format)
-	fmt.Printf("  Output: %s\n", c.Out)
-	fmt.Printf("  Files: %v\n", args)
+	budgets := []models.Budget{}
+	if c.Budget != "" {
+		var err error
+		if budgets, err = loader.LoadBudgets(c.Budget); err != nil {
+			return fmt.Errorf("loading budgets: %w", err)
+		}
+	}
+
+	files := make([]results.File, len(args))
+	for i, path := range args {
+		f, err := results.Load(path)
+		if err != nil {
+			return fmt.Errorf("loading results: %w", err)
+		}
+		files[i] = f
+	}
+
+	comparison := compare(args, files, options{alpha: c.Alpha, resamples: c.Resamples})
+	violations, err := checkBudgets(comparison, budgets)
+	if err != nil {
+		return fmt.Errorf("checking budgets: %w", err)
+	}
+	comparison.Violations = violations
+
+	var w io.Writer = os.Stdout
+	if c.Out != "stdout" {
+		f, err := os.Create(c.Out)
+		if err != nil {
+			return fmt.Errorf("creating output file: %w", err)
+		}
+		defer f.Close()
+		w = f
+	}
 
+	switch c.Format {
+	case "json":
+		err = writeJSON(w, comparison)
+	case "csv":
+		err = writeCSV(w, comparison)
+	default:
+		err = writeHTML(w, comparison)
+	}
+	if err != nil {
+		return err
+	}
+
+	if len(comparison.Violations) > 0 {
+		return fmt.Errorf("%w:\n%s", ErrOverBudget, summary(comparison.Violations))
+	}
 	return nil
 }
@@ -0,0 +1,284 @@
+package compare
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/jonny-burkholder/swarm/internal/histogram"
+	"github.com/jonny-burkholder/swarm/internal/results"
+	"github.com/jonny-burkholder/swarm/internal/stats"
+)
+
+// Comparison lines up the requests in a baseline results file with the same requests in
+// one or more candidates. Requests are matched by collection and request name
+type Comparison struct {
+	Files      []FileInfo          `json:"files"` // the first is the baseline
+	Requests   []RequestComparison `json:"requests"`
+	Violations []Violation         `json:"violations,omitempty"` // requests that went over budget
+}
+
+type FileInfo struct {
+	Path         string    `json:"path"`
+	Host         string    `json:"host"`
+	GitSHA       string    `json:"git_sha,omitempty"`
+	Start        time.Time `json:"start"`
+	SwarmVersion string    `json:"swarm_version"`
+}
+
+type RequestComparison struct {
+	Collection string `json:"collection"`
+	Request    string `json:"request"` // "total" for the collection as a whole
+	// one for each file, in the same order as the files. Stats for a file that
+	// doesn't have the request are marked missing
+	Stats []Stats `json:"stats"`
+	// one for each candidate, comparing it to the baseline. Requests that are
+	// missing from either have no changes
+	Deltas []Delta `json:"deltas"`
+
+	// kept for working out more than the raw deltas
+	aggregates []*results.Aggregate
+}
+
+// Stats are the numbers we compare for a request in one file
+type Stats struct {
+	Missing    bool          `json:"missing,omitempty"`
+	Count      int           `json:"count"`
+	Errors     int           `json:"errors"`     // requests that errored, failed, or got a 5xx
+	ErrorRate  float64       `json:"error_rate"` // percent
+	Throughput float64       `json:"throughput"` // requests per second
+	Mean       time.Duration `json:"mean_ns"`
+	P50        time.Duration `json:"p50_ns"`
+	P90        time.Duration `json:"p90_ns"`
+	P95        time.Duration `json:"p95_ns"`
+	P99        time.Duration `json:"p99_ns"`
+	P999       time.Duration `json:"p99_9_ns"`
+}
+
+type Delta struct {
+	Candidate string   `json:"candidate"`
+	Changes   []Change `json:"changes"`
+	// improved, regressed, or no change, going by whether a Mann-Whitney U test finds
+	// the candidate's latencies are significantly different. Empty if the request is missing
+	Verdict    string  `json:"verdict,omitempty"`
+	P          float64 `json:"p_value"`
+	ProbSlower float64 `json:"prob_slower"` // the chance a request in the candidate is slower than one in the baseline
+}
+
+const (
+	Improved  = "improved"
+	Regressed = "regressed"
+	NoChange  = "no change"
+)
+
+// Change is how one metric changed between the baseline and a candidate
+type Change struct {
+	Metric    string  `json:"metric"`
+	Unit      string  `json:"unit"`
+	Baseline  float64 `json:"baseline"`
+	Candidate float64 `json:"candidate"`
+	Diff      float64 `json:"diff"`
+	// nil when the baseline is 0 and the candidate isn't, since the change can't be a percentage of that
+	Percent *float64 `json:"percent"`
+	// whether the change is for the better or worse, going purely by which direction it went
+	Better bool `json:"better"`
+	Worse  bool `json:"worse"`
+	// a bootstrapped confidence interval for Diff, for latency percentiles
+	CI *stats.Interval `json:"ci,omitempty"`
+}
+
+// metric is something we compare, and how to get it out of the stats
+type metric struct {
+	name           string
+	unit           string
+	higherIsBetter bool
+	value          func(Stats) float64
+	quantile       float64 // for percentiles, so they can be bootstrapped
+}
+
+func latency(get func(Stats) time.Duration) func(Stats) float64 {
+	return func(s Stats) float64 {
+		return float64(get(s))
+	}
+}
+
+// metrics are what's compared, in the order they're shown
+func metrics() []metric {
+	return []metric{
+		{name: "throughput", unit: "req/s", higherIsBetter: true, value: func(s Stats) float64 { return s.Throughput }},
+		{name: "error_rate", unit: "%", value: func(s Stats) float64 { return s.ErrorRate }},
+		{name: "mean", unit: "ns", value: latency(func(s Stats) time.Duration { return s.Mean })},
+		{name: "p50", unit: "ns", value: latency(func(s Stats) time.Duration { return s.P50 }), quantile: 0.5},
+		{name: "p90", unit: "ns", value: latency(func(s Stats) time.Duration { return s.P90 }), quantile: 0.9},
+		{name: "p95", unit: "ns", value: latency(func(s Stats) time.Duration { return s.P95 }), quantile: 0.95},
+		{name: "p99", unit: "ns", value: latency(func(s Stats) time.Duration { return s.P99 }), quantile: 0.99},
+		{name: "p99_9", unit: "ns", value: latency(func(s Stats) time.Duration { return s.P999 }), quantile: 0.999},
+	}
+}
+
+func newStats(a results.Aggregate) Stats {
+	s := Stats{
+		Count:      a.Count,
+		Errors:     unsuccessful(a),
+		Throughput: a.Throughput,
+		Mean:       a.Mean,
+		P50:        a.P50,
+		P90:        a.P90,
+		P95:        a.P95,
+		P99:        a.P99,
+		P999:       a.P999,
+	}
+	if a.Count > 0 {
+		s.ErrorRate = float64(s.Errors) / float64(s.Count) * 100
+	}
+	return s
+}
+
+// unsuccessful is how many requests errored, failed, or got a 5xx. Files saved before that
+// was kept only have the parts, and since a request can be more than one of those, the
+// most that can be said for them is it's at least the errors and the larger of the others
+func unsuccessful(a results.Aggregate) int {
+	serverErrors := 0
+	for code, n := range a.StatusCodes {
+		if code >= 500 {
+			serverErrors += n
+		}
+	}
+	return max(a.Unsuccessful, a.Errors+max(a.Failed, serverErrors))
+}
+
+// options are how sure compare needs to be before calling something a change
+type options struct {
+	alpha     float64 // the p value a change has to be under to count
+	resamples int     // for bootstrapping confidence intervals
+}
+
+// compare lines up the requests in files. The first file is the baseline
+func compare(paths []string, files []results.File, opts options) Comparison {
+	c := Comparison{}
+	for i, f := range files {
+		c.Files = append(c.Files, FileInfo{
+			Path:         paths[i],
+			Host:         f.Meta.Host,
+			GitSHA:       f.Meta.GitSHA,
+			Start:        f.Meta.Start,
+			SwarmVersion: f.Meta.SwarmVersion,
+		})
+	}
+
+	// requests are listed in the order they first appear, baseline first. They're matched by
+	// name, and files saved before names had to be unique can use one more than once, so
+	// those are told apart by which time it is the name's been used
+	type key struct {
+		collection, request string
+		n                   int
+	}
+	index := map[key]int{}
+	for i, f := range files {
+		for _, collection := range f.Collections {
+			seen := map[string]int{}
+			aggs := append(slices.Clone(collection.Aggregates.Requests), collection.Aggregates.Total)
+			for _, a := range aggs {
+				k := key{collection.Name, a.Name, seen[a.Name]}
+				seen[a.Name]++
+				idx, ok := index[k]
+				if !ok {
+					name := a.Name
+					if k.n > 0 {
+						name = fmt.Sprintf("%s (%d)", a.Name, k.n+1)
+					}
+					idx = len(c.Requests)
+					index[k] = idx
+					c.Requests = append(c.Requests, RequestComparison{
+						Collection: collection.Name,
+						Request:    name,
+						aggregates: make([]*results.Aggregate, len(files)),
+					})
+				}
+				c.Requests[idx].aggregates[i] = &a
+			}
+		}
+	}
+
+	for i := range c.Requests {
+		r := &c.Requests[i]
+		for _, a := range r.aggregates {
+			if a == nil {
+				r.Stats = append(r.Stats, Stats{Missing: true})
+				continue
+			}
+			r.Stats = append(r.Stats, newStats(*a))
+		}
+
+		for j := 1; j < len(files); j++ {
+			delta := Delta{Candidate: paths[j]}
+			if !r.Stats[0].Missing && !r.Stats[j].Missing {
+				for _, m := range metrics() {
+					delta.Changes = append(delta.Changes, newChange(m, r.Stats[0], r.Stats[j]))
+				}
+				significance(&delta, r.aggregates[0].Histogram, r.aggregates[j].Histogram, opts)
+			}
+			r.Deltas = append(r.Deltas, delta)
+		}
+	}
+
+	return c
+}
+
+// significance tests whether the candidate's latencies really are different to the baseline's,
+// and bootstraps intervals for how much each percentile changed
+func significance(delta *Delta, baseline, candidate *histogram.Histogram, opts options) {
+	if baseline == nil || candidate == nil {
+		return
+	}
+
+	mw := stats.MannWhitneyU(baseline, candidate)
+	delta.P, delta.ProbSlower = mw.P, mw.ProbSlower
+	switch {
+	case mw.P >= opts.alpha:
+		delta.Verdict = NoChange
+	case mw.ProbSlower > 0.5:
+		delta.Verdict = Regressed
+	default:
+		delta.Verdict = Improved
+	}
+
+	quantiles, changes := []float64{}, []*Change{}
+	for i, m := range metrics() {
+		if m.quantile > 0 {
+			quantiles = append(quantiles, m.quantile)
+			changes = append(changes, &delta.Changes[i])
+		}
+	}
+	// there are no intervals if resampling was turned off, or there was nothing to resample
+	b := stats.Bootstrap{Resamples: opts.resamples, Confidence: 1 - opts.alpha, Seed: 1}
+	for i, ci := range b.QuantileDiffs(baseline, candidate, quantiles...) {
+		changes[i].CI = &ci
+	}
+}
+
+func newChange(m metric, baseline, candidate Stats) Change {
+	c := Change{
+		Metric:    m.name,
+		Unit:      m.unit,
+		Baseline:  m.value(baseline),
+		Candidate: m.value(candidate),
+	}
+	c.Diff = c.Candidate - c.Baseline
+	switch {
+	case c.Diff == 0:
+		c.Percent = new(float64)
+	case c.Baseline != 0:
+		pct := c.Diff / c.Baseline * 100
+		c.Percent = &pct
+	}
+
+	up, down := c.Diff > 0, c.Diff < 0
+	if m.higherIsBetter {
+		c.Better, c.Worse = up, down
+	} else {
+		c.Better, c.Worse = down, up
+	}
+
+	return c
+}
@@ -0,0 +1,175 @@
+package compare
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/jonny-burkholder/swarm/internal/results"
+)
+
+func TestNewStatsErrorRate(t *testing.T) {
+	tests := []struct {
+		name      string
+		aggregate results.Aggregate
+		want      float64
+	}{
+		{
+			name:      "no errors",
+			aggregate: results.Aggregate{Count: 10, StatusCodes: map[int]int{200: 10}},
+		},
+		{
+			name:      "transport errors",
+			aggregate: results.Aggregate{Count: 10, Errors: 1, Unsuccessful: 1, StatusCodes: map[int]int{200: 9}},
+			want:      10,
+		},
+		{
+			name:      "5xx responses",
+			aggregate: results.Aggregate{Count: 40, Unsuccessful: 10, StatusCodes: map[int]int{200: 30, 500: 10}},
+			want:      25,
+		},
+		{
+			name:      "failed assertions",
+			aggregate: results.Aggregate{Count: 10, Failed: 5, Unsuccessful: 5, StatusCodes: map[int]int{200: 10}},
+			want:      50,
+		},
+		{
+			name:      "4xx responses aren't errors on their own",
+			aggregate: results.Aggregate{Count: 10, StatusCodes: map[int]int{200: 5, 404: 5}},
+		},
+		{
+			name:      "5xx responses in a file saved before unsuccessful was kept",
+			aggregate: results.Aggregate{Count: 40, StatusCodes: map[int]int{200: 30, 500: 10}},
+			want:      25,
+		},
+		{
+			name:      "failures and 5xx in an old file might be the same requests",
+			aggregate: results.Aggregate{Count: 20, Errors: 2, Failed: 3, StatusCodes: map[int]int{200: 14, 503: 4}},
+			want:      30,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := newStats(tt.aggregate).ErrorRate; got != tt.want {
+				t.Errorf("newStats().ErrorRate = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompareAligns(t *testing.T) {
+	file := func(requests ...results.Aggregate) results.File {
+		return results.File{Collections: []results.Collection{{
+			Name:       "library",
+			Aggregates: results.Aggregates{Requests: requests, Total: results.Aggregate{Name: "total", Count: 100}},
+		}}}
+	}
+	agg := func(name string, count int) results.Aggregate {
+		return results.Aggregate{Name: name, Count: count}
+	}
+	baseline := file(agg("GET books", 1), agg("GET books", 2), agg("POST books", 3))
+	candidate := file(agg("GET books", 11), agg("DELETE books", 14), agg("GET books", 12))
+
+	c := compare([]string{"a.json", "b.json"}, []results.File{baseline, candidate}, options{alpha: 0.05})
+
+	type want struct {
+		request string
+		counts  []int // -1 if it's missing from the file
+	}
+	wants := []want{
+		{"GET books", []int{1, 11}},
+		{"GET books (2)", []int{2, 12}},
+		{"POST books", []int{3, -1}},
+		{"total", []int{100, 100}},
+		{"DELETE books", []int{-1, 14}},
+	}
+	if len(c.Requests) != len(wants) {
+		t.Fatalf("compare() has %d requests, want %d", len(c.Requests), len(wants))
+	}
+	for i, w := range wants {
+		r := c.Requests[i]
+		counts := []int{}
+		for _, s := range r.Stats {
+			if s.Missing {
+				counts = append(counts, -1)
+				continue
+			}
+			counts = append(counts, s.Count)
+		}
+		if r.Request != w.request || !slices.Equal(counts, w.counts) {
+			t.Errorf("request %d = %s with counts %v, want %s with %v", i, r.Request, counts, w.request, w.counts)
+		}
+		// only requests in both files have changes
+		inBoth := !slices.Contains(w.counts, -1)
+		if hasChanges := len(r.Deltas[0].Changes) > 0; hasChanges != inBoth {
+			t.Errorf("%s has changes = %v, want %v", r.Request, hasChanges, inBoth)
+		}
+	}
+}
+
+func TestNewChange(t *testing.T) {
+	throughput := metrics()[0]
+	errorRate := metrics()[1]
+
+	tests := []struct {
+		name        string
+		metric      metric
+		baseline    Stats
+		candidate   Stats
+		wantPercent *float64
+		wantBetter  bool
+		wantWorse   bool
+	}{
+		{
+			name:        "no change",
+			metric:      throughput,
+			baseline:    Stats{Throughput: 100},
+			candidate:   Stats{Throughput: 100},
+			wantPercent: new(float64),
+		},
+		{
+			name:        "higher is better",
+			metric:      throughput,
+			baseline:    Stats{Throughput: 100},
+			candidate:   Stats{Throughput: 150},
+			wantPercent: ptr(50),
+			wantBetter:  true,
+		},
+		{
+			name:        "lower is better",
+			metric:      errorRate,
+			baseline:    Stats{ErrorRate: 4},
+			candidate:   Stats{ErrorRate: 1},
+			wantPercent: ptr(-75),
+			wantBetter:  true,
+		},
+		{
+			name:        "both 0",
+			metric:      errorRate,
+			wantPercent: new(float64),
+		},
+		{
+			// any growth from 0 is infinitely many percent
+			name:      "zero baseline",
+			metric:    errorRate,
+			candidate: Stats{ErrorRate: 2},
+			wantWorse: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newChange(tt.metric, tt.baseline, tt.candidate)
+			if (c.Percent == nil) != (tt.wantPercent == nil) || (c.Percent != nil && *c.Percent != *tt.wantPercent) {
+				t.Errorf("Percent = %s, want %s", formatPercent(c.Percent), formatPercent(tt.wantPercent))
+			}
+			if c.Better != tt.wantBetter || c.Worse != tt.wantWorse {
+				t.Errorf("Better, Worse = %v, %v, want %v, %v", c.Better, c.Worse, tt.wantBetter, tt.wantWorse)
+			}
+		})
+	}
+}
+
+func ptr(f float64) *float64 {
+	return &f
+}
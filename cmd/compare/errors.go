@@ -0,0 +1,8 @@
+package compare
+
+import "errors"
+
+var (
+	ErrOverBudget      = errors.New("regression budget exceeded")
+	ErrUnmatchedBudget = errors.New("budget doesn't match any request")
+)
@@ -0,0 +1,193 @@
+package compare
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/jonny-burkholder/swarm/resources"
+)
+
+func writeJSON(w io.Writer, c Comparison) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(c)
+}
+
+// writeCSV writes a row for every metric of every request, for each candidate
+func writeCSV(w io.Writer, c Comparison) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"collection", "request", "baseline", "candidate", "verdict", "p_value", "metric", "unit",
+		"baseline_value", "candidate_value", "diff", "percent", "ci_low", "ci_high"})
+
+	for _, r := range c.Requests {
+		for _, d := range r.Deltas {
+			for _, change := range d.Changes {
+				pct, low, high := "", "", ""
+				if change.Percent != nil {
+					pct = formatFloat(*change.Percent)
+				}
+				if change.CI != nil {
+					low, high = formatFloat(change.CI.Low), formatFloat(change.CI.High)
+				}
+				cw.Write([]string{
+					r.Collection, r.Request, c.Files[0].Path, d.Candidate, d.Verdict, formatFloat(d.P), change.Metric, change.Unit,
+					formatFloat(change.Baseline), formatFloat(change.Candidate), formatFloat(change.Diff), pct, low, high,
+				})
+			}
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// collectionView groups requests by collection, for the html report
+type collectionView struct {
+	Name     string
+	Requests []requestView
+}
+
+type requestView struct {
+	RequestComparison
+	Rows []metricRow
+	// a verdict for each file, to go under its heading. The baseline's is always empty
+	Verdicts []verdict
+}
+
+type verdict struct {
+	Label string
+	P     string
+	Class string
+}
+
+// metricRow is a row of the table for a request, with a cell for each file
+type metricRow struct {
+	Metric string
+	Cells  []cell
+}
+
+type cell struct {
+	Value   string
+	Change  string // compared to the baseline, empty for the baseline itself
+	CI      string // the confidence interval for the change, if there is one
+	Class   string // better or worse
+	Missing bool
+}
+
+type htmlView struct {
+	Comparison
+	Collections []collectionView
+}
+
+func newRequestView(r RequestComparison) requestView {
+	view := requestView{RequestComparison: r, Verdicts: []verdict{{}}}
+	for _, d := range r.Deltas {
+		v := verdict{Label: d.Verdict}
+		if d.Verdict != "" {
+			v.P = fmt.Sprintf("p=%.3g", d.P)
+		}
+		switch d.Verdict {
+		case Improved:
+			v.Class = "better"
+		case Regressed:
+			v.Class = "worse"
+		}
+		view.Verdicts = append(view.Verdicts, v)
+	}
+	for mi, m := range metrics() {
+		row := metricRow{Metric: m.name}
+		for i, s := range r.Stats {
+			if s.Missing {
+				row.Cells = append(row.Cells, cell{Missing: true})
+				continue
+			}
+			c := cell{Value: formatValue(m.value(s), m.unit)}
+			if i > 0 && len(r.Deltas[i-1].Changes) > 0 {
+				change := r.Deltas[i-1].Changes[mi]
+				c.Change = formatPercent(change.Percent)
+				if change.CI != nil {
+					c.CI = fmt.Sprintf("change %s to %s", formatSigned(change.CI.Low, m.unit), formatSigned(change.CI.High, m.unit))
+				}
+				switch {
+				case change.Better:
+					c.Class = "better"
+				case change.Worse:
+					c.Class = "worse"
+				}
+			}
+			row.Cells = append(row.Cells, c)
+		}
+		view.Rows = append(view.Rows, row)
+	}
+	return view
+}
+
+func writeHTML(w io.Writer, c Comparison) error {
+	tmpl, err := template.New("compare").Funcs(template.FuncMap{
+		"chart": chart,
+		"color": color,
+		"short": short,
+	}).Parse(resources.CompareHTML)
+	if err != nil {
+		return fmt.Errorf("parsing html template: %w", err)
+	}
+
+	view := htmlView{Comparison: c}
+	// group requests by collection, in the order the collections first turn up
+	index := map[string]int{}
+	for _, r := range c.Requests {
+		i, ok := index[r.Collection]
+		if !ok {
+			i = len(view.Collections)
+			index[r.Collection] = i
+			view.Collections = append(view.Collections, collectionView{Name: r.Collection})
+		}
+		view.Collections[i].Requests = append(view.Collections[i].Requests, newRequestView(r))
+	}
+
+	return tmpl.Execute(w, view)
+}
+
+// formatValue formats the value of a metric in its unit
+func formatValue(v float64, unit string) string {
+	switch unit {
+	case "ns":
+		return time.Duration(v).Round(time.Microsecond).String()
+	case "%":
+		return strconv.FormatFloat(v, 'f', 2, 64) + "%"
+	default:
+		return strconv.FormatFloat(v, 'f', 1, 64)
+	}
+}
+
+// formatSigned formats a change in a metric, with a sign
+func formatSigned(v float64, unit string) string {
+	if v < 0 {
+		return "-" + formatValue(-v, unit)
+	}
+	return "+" + formatValue(v, unit)
+}
+
+func formatPercent(p *float64) string {
+	if p == nil {
+		return "n/a"
+	}
+	return fmt.Sprintf("%+.1f%%", *p)
+}
+
+// short shortens a git sha
+func short(sha string) string {
+	if len(sha) > 8 {
+		return sha[:8]
+	}
+	return sha
+}
@@ -0,0 +1,62 @@
+package compare
+
+import (
+	"encoding/csv"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/jonny-burkholder/swarm/internal/stats"
+)
+
+func TestWriteCSV(t *testing.T) {
+	c := Comparison{
+		Files: []FileInfo{{Path: "a.json"}, {Path: "b.json"}},
+		Requests: []RequestComparison{
+			{
+				Collection: "library",
+				Request:    "GET books",
+				Deltas: []Delta{{
+					Candidate: "b.json",
+					Verdict:   Regressed,
+					P:         0.01,
+					Changes: []Change{
+						{Metric: "error_rate", Unit: "%", Candidate: 2.5, Diff: 2.5},
+						{Metric: "p95", Unit: "ns", Baseline: 100, Candidate: 150, Diff: 50, Percent: ptr(50), CI: &stats.Interval{Low: 20, High: 80}},
+					},
+				}},
+			},
+			{
+				// missing from the candidate, so there's nothing to write
+				Collection: "library",
+				Request:    "POST books",
+				Deltas:     []Delta{{Candidate: "b.json"}},
+			},
+		},
+	}
+
+	b := &strings.Builder{}
+	if err := writeCSV(b, c); err != nil {
+		t.Fatalf("writeCSV() error = %v", err)
+	}
+	rows, err := csv.NewReader(strings.NewReader(b.String())).ReadAll()
+	if err != nil {
+		t.Fatalf("reading the csv back: %v", err)
+	}
+
+	want := [][]string{
+		{"collection", "request", "baseline", "candidate", "verdict", "p_value", "metric", "unit",
+			"baseline_value", "candidate_value", "diff", "percent", "ci_low", "ci_high"},
+		// no percent when the baseline was 0, and no interval when there wasn't one
+		{"library", "GET books", "a.json", "b.json", "regressed", "0.01", "error_rate", "%", "0", "2.5", "2.5", "", "", ""},
+		{"library", "GET books", "a.json", "b.json", "regressed", "0.01", "p95", "ns", "100", "150", "50", "50", "20", "80"},
+	}
+	if len(rows) != len(want) {
+		t.Fatalf("writeCSV() wrote %d rows, want %d:\n%s", len(rows), len(want), b)
+	}
+	for i := range want {
+		if !slices.Equal(rows[i], want[i]) {
+			t.Errorf("row %d = %q, want %q", i, rows[i], want[i])
+		}
+	}
+}
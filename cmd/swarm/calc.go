@@ -1,7 +0,0 @@
-package swarm
-
-// calc is for functions that calculate how a swarm swells.
-// For instance, you may want to scale linearly to get a
-// precise idea of where an applications breaks. Or, you
-// may be trying to break as quickly as possible, and want
-// a logarithmic function
@@ -0,0 +1,321 @@
+package swarm
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jonny-burkholder/swarm/internal/loader"
+	"github.com/jonny-burkholder/swarm/internal/logger"
+	"github.com/jonny-burkholder/swarm/internal/models"
+	"github.com/jonny-burkholder/swarm/internal/ramp"
+)
+
+type SwarmCommand struct {
+	Logger logger.Logger
+
+	// Flag values
+	Collection   string
+	Name         string
+	Config       string
+	LogLevel     string
+	Start        int
+	Step         int
+	MaxWorkers   int
+	StepDuration time.Duration
+	Threshold    string
+	Limit        float64
+	Window       time.Duration
+	Timeout      time.Duration
+	Async        bool
+	MaxInFlight  int
+	Ramp         string
+	RampShape    string
+	RampSteps    int
+
+	// stages is the ramp, from --ramp or the config file
+	stages []models.Stage
+
+	// flags is kept so we know which values were set explicitly,
+	// and shouldn't be overridden by the config file
+	flags *flag.FlagSet
+}
+
+// NewSwarmCommand creates a new swarm command with default values
+func NewSwarmCommand() *SwarmCommand {
+	return &SwarmCommand{
+		LogLevel:     "info",
+		Start:        1,
+		Step:         5,
+		MaxWorkers:   500,
+		StepDuration: 10 * time.Second,
+		Threshold:    "err-percent",
+		Limit:        5,
+		Window:       10 * time.Second,
+		Timeout:      30 * time.Second,
+		RampShape:    models.RampLinear,
+	}
+}
+
+// SetupFlags configures the flag set for the swarm command
+func (s *SwarmCommand) SetupFlags(fs *flag.FlagSet) {
+	s.flags = fs
+
+	fs.StringVar(&s.Collection, "collection", s.Collection, "Collection file to swarm")
+	fs.StringVar(&s.Collection, "c", s.Collection, "Collection file to swarm (short)")
+
+	fs.StringVar(&s.Name, "name", s.Name, "Collection in the file to swarm. Defaults to the first one")
+
+	fs.StringVar(&s.Config, "config", s.Config, "Configuration file, for things like timeouts")
+	fs.StringVar(&s.Config, "f", s.Config, "Configuration file (short)")
+
+	// Load flags
+	fs.IntVar(&s.Start, "start", s.Start, "Number of workers to start with")
+	fs.IntVar(&s.Step, "step", s.Step, "Number of workers to add each step")
+	fs.IntVar(&s.MaxWorkers, "max-workers", s.MaxWorkers, "Stop if the api hasn't broken by this many workers. 0 means no limit")
+	fs.DurationVar(&s.StepDuration, "step-duration", s.StepDuration, "How long to run each step for. When ramping, how often to report")
+
+	// Ramp flags
+	fs.StringVar(&s.Ramp, "ramp", s.Ramp, "Stages of workers to ramp through instead of stepping, like 100:5m or 10:1m:constant,200:4m:exponential")
+	fs.StringVar(&s.RampShape, "ramp-shape", s.RampShape, "Shape of ramp stages that don't set their own: constant, linear, step, exponential, or logarithmic")
+	fs.IntVar(&s.RampSteps, "ramp-steps", s.RampSteps, "How many steps step stages take. 0 means the default")
+
+	// Threshold flags
+	fs.StringVar(&s.Threshold, "threshold", s.Threshold, "What breaks the api: max-err, err-percent, or err-rate")
+	fs.Float64Var(&s.Limit, "limit", s.Limit, "The threshold's limit: total errors for max-err, a percent for err-percent, or errors per window for err-rate")
+	fs.DurationVar(&s.Window, "window", s.Window, "Sliding window for err-rate")
+
+	fs.DurationVar(&s.Timeout, "timeout", s.Timeout, "Timeout for each request. 0 means no timeout")
+	fs.DurationVar(&s.Timeout, "t", s.Timeout, "Timeout for each request (short)")
+
+	fs.BoolVar(&s.Async, "async", s.Async, "Run requests asynchronously within each worker")
+	fs.BoolVar(&s.Async, "a", s.Async, "Run requests asynchronously within each worker (short)")
+
+	fs.IntVar(&s.MaxInFlight, "max-in-flight", s.MaxInFlight, "Maximum requests each async worker sends at once. 0 means no limit")
+
+	fs.StringVar(&s.LogLevel, "log-level", s.LogLevel, "Log level (debug, info, warn, error)")
+	fs.StringVar(&s.LogLevel, "l", s.LogLevel, "Log level (short)")
+}
+
+// Validate checks that the provided flags are valid
+func (s *SwarmCommand) Validate() error {
+	if s.Collection == "" {
+		return fmt.Errorf("collection file is required (use -c or --collection)")
+	}
+
+	if s.Start <= 0 || s.Step <= 0 {
+		return fmt.Errorf("start and step must be greater than 0")
+	}
+
+	if s.MaxWorkers < 0 {
+		return fmt.Errorf("max workers can't be negative")
+	}
+
+	if s.Timeout < 0 {
+		return fmt.Errorf("timeout can't be negative")
+	}
+
+	if s.MaxInFlight < 0 {
+		return fmt.Errorf("max in-flight requests can't be negative")
+	}
+
+	if s.StepDuration <= 0 {
+		return fmt.Errorf("step duration must be greater than 0")
+	}
+
+	typ, err := ParseThresholdType(s.Threshold)
+	if err != nil {
+		return err
+	}
+
+	if s.Limit <= 0 {
+		return fmt.Errorf("limit must be greater than 0")
+	}
+
+	if typ == TypeErrRate && s.Window <= 0 {
+		return fmt.Errorf("window must be greater than 0 for err-rate")
+	}
+
+	if _, err := logger.ParseLevel(s.LogLevel); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Run swarms the collection and writes a report of each step
+func (s *SwarmCommand) Run() error {
+	s.defaults()
+	if s.Ramp != "" {
+		stages, err := ramp.ParseStages(s.Ramp, s.RampShape, s.RampSteps)
+		if err != nil {
+			return fmt.Errorf("validation failed: %w", err)
+		}
+		s.stages = stages
+	}
+	cfg, err := s.loadConfig()
+	if err != nil {
+		return fmt.Errorf("loading config: %w", err)
+	}
+	if err := s.Validate(); err != nil {
+		return fmt.Errorf("validation failed: %w", err)
+	}
+
+	if s.Logger == nil {
+		lvl, _ := logger.ParseLevel(s.LogLevel) // already validated
+		s.Logger = logger.DefaultLogger(lvl)
+	}
+
+	collection, err := s.collection()
+	if err != nil {
+		return err
+	}
+
+	cfg.Timeout, cfg.Async, cfg.MaxInFlight = s.Timeout, s.Async, s.MaxInFlight
+	cfg.Concurrent = s.Start
+	cfg.Rate = 0 // the swarm adds workers, so runs start whenever one is free
+	// the swarm mostly only needs to know how requests did, not what came back,
+	// so bodies are only kept if something needs to look at them
+	cfg.DiscardBody = !slices.ContainsFunc(collection.Requests, models.Request.NeedsBody)
+
+	typ, _ := ParseThresholdType(s.Threshold) // already validated
+	sw := New(cfg, NewThreshold(typ, s.Limit, s.Window), s.Step, s.MaxWorkers, s.StepDuration)
+	sw.Logger = s.Logger
+	if len(s.stages) > 0 {
+		if sw.Profile, err = ramp.NewProfile(s.stages); err != nil {
+			return fmt.Errorf("ramp: %w", err)
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	// deferred after stop so that it runs first, and finishing normally doesn't look like an interrupt
+	defer context.AfterFunc(ctx, func() {
+		stop()
+		s.Logger.Warn("swarm interrupted, waiting for in-flight requests to finish")
+	})()
+
+	report, err := sw.Run(ctx, collection)
+	return errors.Join(err, writeReport(os.Stdout, report, sw))
+}
+
+// defaults looks in SWARMPATH for a collection and config, if they weren't passed in
+func (s *SwarmCommand) defaults() {
+	swarmpath := os.Getenv("SWARMPATH")
+	if swarmpath == "" {
+		return
+	}
+
+	if s.Collection == "" {
+		path := filepath.Join(swarmpath, "collection.yml")
+		if _, err := os.Stat(path); err == nil {
+			s.Collection = path
+		}
+	}
+
+	if s.Config == "" {
+		path := filepath.Join(swarmpath, "config.yml")
+		if _, err := os.Stat(path); err == nil {
+			s.Config = path
+		}
+	}
+}
+
+// loadConfig reads the config file, if there is one. Only the request settings and the
+// ramp are used, since the swarm decides how many workers to run and for how long. Flags
+// that were passed explicitly take precedence
+func (s *SwarmCommand) loadConfig() (models.Config, error) {
+	cfg := models.Config{
+		Timeout:     s.Timeout,
+		Async:       s.Async,
+		MaxInFlight: s.MaxInFlight,
+	}
+	if s.Config == "" {
+		return cfg, nil
+	}
+
+	cfg, err := loader.LoadConfig(s.Config, cfg)
+	if err != nil {
+		return cfg, err
+	}
+
+	set := map[string]bool{}
+	if s.flags != nil {
+		s.flags.Visit(func(f *flag.Flag) {
+			set[f.Name] = true
+		})
+	}
+
+	if !set["timeout"] && !set["t"] {
+		s.Timeout = cfg.Timeout
+	}
+	if !set["async"] && !set["a"] {
+		s.Async = cfg.Async
+	}
+	if !set["max-in-flight"] {
+		s.MaxInFlight = cfg.MaxInFlight
+	}
+	if !set["ramp"] {
+		s.stages = cfg.Ramp
+	}
+
+	return cfg, nil
+}
+
+// collection loads the collection to swarm
+func (s *SwarmCommand) collection() (*models.Collection, error) {
+	collections, err := loader.LoadCollections(s.Collection)
+	if err != nil {
+		return nil, fmt.Errorf("loading collection: %w", err)
+	}
+	if s.Name == "" {
+		return &collections[0], nil
+	}
+	for i := range collections {
+		if collections[i].Name == s.Name {
+			return &collections[i], nil
+		}
+	}
+	return nil, fmt.Errorf("no collection named '%s' in %s", s.Name, s.Collection)
+}
+
+func writeReport(w io.Writer, report Report, sw Swarm) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintln(tw, "WORKERS\tREQUESTS\tERRORS\tERR %\tREQ/S\tP95\tELAPSED")
+	for _, step := range report.Steps {
+		pct := 0.0
+		if step.Requests > 0 {
+			pct = float64(step.Errors) / float64(step.Requests) * 100
+		}
+		fmt.Fprintf(tw, "%d\t%d\t%d\t%.1f\t%.1f\t%s\t%s\n", step.Workers, step.Requests, step.Errors, pct,
+			step.Throughput, step.P95.Round(time.Microsecond), step.Elapsed.Round(time.Millisecond))
+	}
+	fmt.Fprintln(tw)
+
+	if bp, ok := report.BreakPoint(); ok {
+		fmt.Fprintf(tw, "Broke at %d workers, %.1f req/s: %s\n", bp.Workers, bp.Throughput, report.Reason)
+		if len(report.Steps) > 1 {
+			last := report.Steps[len(report.Steps)-2]
+			fmt.Fprintf(tw, "Last good step: %d workers, %.1f req/s\n", last.Workers, last.Throughput)
+		}
+	} else if len(report.Steps) > 0 {
+		// a ramp may have come back down by the end, so this is the most there ever were
+		most := 0
+		for _, step := range report.Steps {
+			most = max(most, step.Workers)
+		}
+		fmt.Fprintf(tw, "Didn't break (%s) up to %d workers\n", sw.typ, most)
+	}
+
+	return tw.Flush()
+}
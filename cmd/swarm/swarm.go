@@ -1,6 +1,17 @@
 package swarm
 
-import "github.com/jonny-burkholder/swarm/internal/models"
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/jonny-burkholder/swarm/internal/logger"
+	"github.com/jonny-burkholder/swarm/internal/models"
+	"github.com/jonny-burkholder/swarm/internal/ramp"
+	defaulthttp "github.com/jonny-burkholder/swarm/internal/runners/default/http"
+)
 
 type thresholdType int
 
@@ -10,17 +21,202 @@ const (
 	TypeErrRate                         // run until a certain number of errors are reached for a sliding time window
 )
 
-func New() Swarm {
+// ParseThresholdType converts a threshold name, as passed on the command line, to a thresholdType
+func ParseThresholdType(name string) (thresholdType, error) {
+	switch name {
+	case "max-err":
+		return TypeMaxErr, nil
+	case "err-percent":
+		return TypeErrPercent, nil
+	case "err-rate":
+		return TypeErrRate, nil
+	}
+	return TypeMaxErr, fmt.Errorf("invalid threshold '%s', must be one of: max-err, err-percent, err-rate", name)
+}
+
+func (t thresholdType) String() string {
+	switch t {
+	case TypeErrPercent:
+		return "err-percent"
+	case TypeErrRate:
+		return "err-rate"
+	default:
+		return "max-err"
+	}
+}
+
+// New creates a swarm that starts with cfg.Concurrent workers, and adds step more every
+// stepDuration until the threshold trips or there would be more than maxWorkers
+func New(cfg models.Config, t threshold, step, maxWorkers int, stepDuration time.Duration) Swarm {
 	return Swarm{
-		threshold: threshold{},
+		Config:       cfg,
+		threshold:    t,
+		Step:         step,
+		MaxWorkers:   maxWorkers,
+		StepDuration: stepDuration,
 	}
 }
 
+// Swarm keeps adding load until the api breaks, to find out how much it can take
 type Swarm struct {
 	models.Config
 	threshold
+	Step         int
+	MaxWorkers   int
+	StepDuration time.Duration
+	// Profile, if set, is followed instead of adding Step workers at a time. It's one long
+	// run, which is reported on in steps of StepDuration
+	Profile *ramp.Profile
+	Logger  logger.Logger
+}
+
+// NewThreshold creates a threshold. What limit means depends on the type: a number of errors
+// for TypeMaxErr, a percent for TypeErrPercent, and a number of errors within window for TypeErrRate
+func NewThreshold(typ thresholdType, limit float64, window time.Duration) threshold {
+	return threshold{
+		typ:    typ,
+		limit:  limit,
+		window: window,
+	}
 }
 
 type threshold struct {
-	// typ thresholdType
+	typ    thresholdType
+	limit  float64
+	window time.Duration // only for TypeErrRate
+}
+
+// minSample is how many requests a step needs before TypeErrPercent can trip partway
+// through, so that one unlucky request at the start of a step doesn't count as 100%.
+// Once the step is over it's checked whatever the sample
+const minSample = 20
+
+// Report is what happened at each step of a swarm, and whether the api broke
+type Report struct {
+	Steps  []Step
+	Broke  bool
+	Reason string // why the threshold tripped
+}
+
+// Step is how the api did with a number of workers
+type Step struct {
+	Workers    int
+	Requests   int
+	Errors     int
+	Throughput float64 // requests per second
+	P95        time.Duration
+	Elapsed    time.Duration
+}
+
+// BreakPoint is the step the api broke at, if it did
+func (r Report) BreakPoint() (Step, bool) {
+	if !r.Broke || len(r.Steps) == 0 {
+		return Step{}, false
+	}
+	return r.Steps[len(r.Steps)-1], true
+}
+
+// Run swarms the collection until it breaks, the max number of workers has
+// been tried, or ctx is cancelled
+func (s Swarm) Run(ctx context.Context, collection *models.Collection) (Report, error) {
+	if s.Profile != nil {
+		return s.ramp(ctx, collection)
+	}
+	if s.Concurrent <= 0 || s.Step <= 0 || s.StepDuration <= 0 {
+		return Report{}, fmt.Errorf("the starting workers, step, and step duration must all be greater than 0")
+	}
+
+	report := Report{}
+	w := &watcher{threshold: s.threshold}
+
+	for workers := s.Concurrent; s.MaxWorkers <= 0 || workers <= s.MaxWorkers; workers += s.Step {
+		if ctx.Err() != nil {
+			return report, ctx.Err()
+		}
+		if s.Logger != nil {
+			s.Logger.Info("swarming", "collection", collection.Name, "workers", workers)
+		}
+
+		step, err := s.step(ctx, collection, workers, w)
+		report.Steps = append(report.Steps, step)
+		if tripped, reason := w.result(); tripped {
+			report.Broke, report.Reason = true, reason
+			return report, nil
+		}
+		if err != nil {
+			return report, err
+		}
+	}
+
+	return report, nil
+}
+
+// step runs the collection with a number of workers for the step duration, or until the threshold trips
+func (s Swarm) step(ctx context.Context, collection *models.Collection, workers int, w *watcher) (Step, error) {
+	stepCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	w.next(cancel)
+
+	c := copyCollection(collection)
+	cfg := s.Config
+	cfg.Concurrent = workers
+	cfg.Duration = s.StepDuration
+	cfg.Runs = 0
+	runner := defaulthttp.New(cfg)
+	runner.Sink = w
+
+	err := runner.Run(stepCtx, []*models.Collection{c})
+	w.end()
+	if tripped, _ := w.result(); tripped {
+		// the runner reports being cancelled when the threshold trips, which isn't a problem
+		err = nil
+	}
+
+	return w.step(workers, c.End.Sub(c.Start)), err
+}
+
+// ramp follows the profile until the threshold trips or the profile ends. The break
+// point is the step it tripped in
+func (s Swarm) ramp(ctx context.Context, collection *models.Collection) (Report, error) {
+	if s.StepDuration <= 0 {
+		return Report{}, fmt.Errorf("the step duration must be greater than 0")
+	}
+
+	rampCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	w := &watcher{threshold: s.threshold}
+	w.ramp(cancel, s.Profile, s.StepDuration)
+
+	cfg := s.Config
+	cfg.Concurrent = max(int(math.Ceil(s.Profile.Peak())), 1)
+	cfg.Duration = s.Profile.Duration()
+	cfg.Runs = 0
+	runner := defaulthttp.New(cfg)
+	runner.Sink = w
+	runner.Ramp = s.Profile.At
+
+	if s.Logger != nil {
+		s.Logger.Info("swarming", "collection", collection.Name, "peak", cfg.Concurrent, "duration", cfg.Duration.String())
+	}
+	err := runner.Run(rampCtx, []*models.Collection{copyCollection(collection)})
+
+	report := Report{Steps: w.steps}
+	if tripped, reason := w.result(); tripped {
+		report.Broke, report.Reason = true, reason
+		return report, nil
+	}
+
+	return report, err
+}
+
+// copyCollection copies the parts of a collection needed to run it, so that
+// every run gets its own runs and aggregates
+func copyCollection(collection *models.Collection) *models.Collection {
+	return &models.Collection{
+		Name:     collection.Name,
+		BaseUrl:  collection.BaseUrl,
+		Requests: collection.Requests,
+		Data:     collection.Data,
+		Mu:       &sync.Mutex{},
+	}
 }
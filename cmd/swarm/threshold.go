@@ -0,0 +1,180 @@
+package swarm
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/jonny-burkholder/swarm/internal/histogram"
+	"github.com/jonny-burkholder/swarm/internal/models"
+	"github.com/jonny-burkholder/swarm/internal/ramp"
+)
+
+// watcher is a sink that counts errors as runs come in, and stops the current step
+// as soon as the threshold trips. A request counts as an error if it couldn't be sent,
+// got a 5xx, or failed an assertion.
+//
+// When ramping there's only one run, so the watcher splits it up into steps itself,
+// every interval
+type watcher struct {
+	threshold
+	cancel context.CancelFunc
+
+	errors     int // over the whole swarm
+	stepTotal  int
+	stepErrors int
+	latency    *histogram.Histogram // for the current step
+	recent     []time.Time          // when recent errors came in, for TypeErrRate
+
+	// only when ramping
+	profile   *ramp.Profile
+	interval  time.Duration
+	start     time.Time
+	stepStart time.Time
+	steps     []Step
+
+	tripped bool
+	reason  string
+}
+
+// next starts counting for a new step. cancel stops the step
+func (w *watcher) next(cancel context.CancelFunc) {
+	w.cancel = cancel
+	w.stepTotal, w.stepErrors = 0, 0
+	w.latency = histogram.New()
+	w.recent = w.recent[:0]
+}
+
+// ramp starts a ramped swarm, which is split into steps every interval
+func (w *watcher) ramp(cancel context.CancelFunc, profile *ramp.Profile, interval time.Duration) {
+	w.next(cancel)
+	w.profile, w.interval = profile, interval
+	w.start = time.Now()
+	w.stepStart = w.start
+}
+
+// step is how the current step went, with a number of workers over elapsed
+func (w *watcher) step(workers int, elapsed time.Duration) Step {
+	step := Step{
+		Workers:  workers,
+		Requests: w.stepTotal,
+		Errors:   w.stepErrors,
+		P95:      time.Duration(w.latency.Quantile(0.95)),
+		Elapsed:  elapsed,
+	}
+	if elapsed > 0 {
+		step.Throughput = float64(step.Requests) / elapsed.Seconds()
+	}
+	return step
+}
+
+// sample closes out the steps of a ramp that have ended by now. A step's workers
+// are however many the ramp wanted by the end of it
+func (w *watcher) sample(now time.Time, final bool) {
+	for end := w.stepStart.Add(w.interval); !now.Before(end) || final; end = w.stepStart.Add(w.interval) {
+		if final {
+			end = now
+		}
+		workers := int(math.Round(w.profile.At(end.Sub(w.start))))
+		w.end()
+		w.steps = append(w.steps, w.step(workers, end.Sub(w.stepStart)))
+		w.stepTotal, w.stepErrors = 0, 0
+		w.latency = histogram.New()
+		w.stepStart = end
+		if final || w.tripped {
+			if w.tripped && w.cancel != nil {
+				w.cancel()
+			}
+			return
+		}
+	}
+}
+
+func (w *watcher) result() (bool, string) {
+	return w.tripped, w.reason
+}
+
+func (w *watcher) Write(collection *models.Collection, run models.Run) error {
+	now := time.Now()
+	// once it's tripped the step it tripped in is the last one, however long
+	// the runs that were still going take to come back
+	if w.profile != nil && !w.tripped {
+		w.sample(now, false)
+	}
+	for _, result := range run.Results {
+		w.stepTotal++
+		w.latency.Record(int64(result.Duration))
+		if result.Error == nil && result.StatusCode < 500 && result.Passed() {
+			continue
+		}
+		w.errors++
+		w.stepErrors++
+		if w.typ == TypeErrRate {
+			w.recent = append(w.recent, now)
+		}
+	}
+
+	if !w.tripped {
+		w.check(now)
+		if w.tripped && w.profile != nil {
+			// a ramp's step ends where it tripped
+			w.sample(now, true)
+		}
+		if w.tripped && w.cancel != nil {
+			w.cancel()
+		}
+	}
+	return nil
+}
+
+func (w *watcher) check(now time.Time) {
+	switch w.typ {
+	case TypeMaxErr:
+		if float64(w.errors) >= w.limit {
+			w.tripped = true
+			w.reason = fmt.Sprintf("%d errors, the limit is %v", w.errors, w.limit)
+		}
+	case TypeErrPercent:
+		if w.stepTotal >= minSample {
+			w.checkPercent()
+		}
+	case TypeErrRate:
+		// drop errors that have slid out of the window
+		cutoff := now.Add(-w.window)
+		i := 0
+		for i < len(w.recent) && w.recent[i].Before(cutoff) {
+			i++
+		}
+		w.recent = w.recent[i:]
+		if float64(len(w.recent)) >= w.limit {
+			w.tripped = true
+			w.reason = fmt.Sprintf("%d errors within %s, the limit is %v", len(w.recent), w.window, w.limit)
+		}
+	}
+}
+
+// end checks the threshold once a step is over. Partway through a step err-percent waits for
+// minSample requests, but once it's over what came back is all there is. An api that hangs
+// might only answer a handful of requests a step, and every one of them an error
+func (w *watcher) end() {
+	if w.tripped || w.typ != TypeErrPercent || w.stepTotal == 0 {
+		return
+	}
+	w.checkPercent()
+}
+
+func (w *watcher) checkPercent() {
+	pct := float64(w.stepErrors) / float64(w.stepTotal) * 100
+	if pct >= w.limit {
+		w.tripped = true
+		w.reason = fmt.Sprintf("%.1f%% of requests were errors, the limit is %v%%", pct, w.limit)
+	}
+}
+
+func (w *watcher) Close() error {
+	if w.profile != nil && w.stepTotal > 0 && !w.tripped {
+		w.sample(time.Now(), true)
+	}
+	return nil
+}
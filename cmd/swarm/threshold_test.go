@@ -0,0 +1,252 @@
+package swarm
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/jonny-burkholder/swarm/internal/models"
+	"github.com/jonny-burkholder/swarm/internal/ramp"
+)
+
+// results makes n results with the status code, or an error if status is 0
+func results(n, status int) []models.Result {
+	res := make([]models.Result, n)
+	for i := range res {
+		if status == 0 {
+			res[i].Error = errors.New("connection refused")
+			continue
+		}
+		res[i].StatusCode = status
+	}
+	return res
+}
+
+func TestWatcher(t *testing.T) {
+	failed := models.Result{StatusCode: 200, Assertions: []models.Assertion{{Field: "status_code", Value: 201}}}
+
+	tests := []struct {
+		name        string
+		threshold   threshold
+		results     []models.Result // each is written as a run of its own
+		wantTripped bool            // partway through the step
+		wantEnd     bool            // once the step is over
+	}{
+		{
+			name:      "max-err under its limit",
+			threshold: NewThreshold(TypeMaxErr, 3, 0),
+			results:   append(results(10, 200), results(2, 0)...),
+		},
+		{
+			name:        "max-err at its limit",
+			threshold:   NewThreshold(TypeMaxErr, 3, 0),
+			results:     append(results(10, 200), results(3, 0)...),
+			wantTripped: true,
+			wantEnd:     true,
+		},
+		{
+			name:        "5xx and failed assertions are errors",
+			threshold:   NewThreshold(TypeMaxErr, 3, 0),
+			results:     append(results(2, 503), failed),
+			wantTripped: true,
+			wantEnd:     true,
+		},
+		{
+			name:      "4xx aren't errors",
+			threshold: NewThreshold(TypeMaxErr, 3, 0),
+			results:   results(10, 404),
+		},
+		{
+			name:        "err-percent over its limit",
+			threshold:   NewThreshold(TypeErrPercent, 50, 0),
+			results:     append(results(10, 200), results(10, 500)...),
+			wantTripped: true,
+			wantEnd:     true,
+		},
+		{
+			name:      "err-percent under its limit",
+			threshold: NewThreshold(TypeErrPercent, 50, 0),
+			results:   append(results(30, 200), results(10, 500)...),
+		},
+		{
+			// too few requests to go on partway through, but that's all the step got
+			name:      "err-percent under the min sample",
+			threshold: NewThreshold(TypeErrPercent, 50, 0),
+			results:   results(minSample-1, 0),
+			wantEnd:   true,
+		},
+		{
+			name:      "err-percent with nothing back",
+			threshold: NewThreshold(TypeErrPercent, 50, 0),
+		},
+		{
+			name:        "err-rate within its window",
+			threshold:   NewThreshold(TypeErrRate, 3, time.Minute),
+			results:     results(3, 0),
+			wantTripped: true,
+			wantEnd:     true,
+		},
+		{
+			name:      "err-rate under its limit",
+			threshold: NewThreshold(TypeErrRate, 3, time.Minute),
+			results:   append(results(2, 0), results(10, 200)...),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := &watcher{threshold: tt.threshold}
+			cancelled := false
+			w.next(func() { cancelled = true })
+
+			for _, result := range tt.results {
+				w.Write(nil, models.Run{Results: []models.Result{result}})
+			}
+			if tripped, reason := w.result(); tripped != tt.wantTripped {
+				t.Fatalf("tripped partway = %v (%s), want %v", tripped, reason, tt.wantTripped)
+			}
+			if cancelled != tt.wantTripped {
+				t.Errorf("step cancelled = %v, want %v", cancelled, tt.wantTripped)
+			}
+
+			w.end()
+			if tripped, reason := w.result(); tripped != tt.wantEnd {
+				t.Errorf("tripped at the end = %v (%s), want %v", tripped, reason, tt.wantEnd)
+			}
+		})
+	}
+}
+
+func TestWatcherNextStep(t *testing.T) {
+	w := &watcher{threshold: NewThreshold(TypeErrPercent, 50, 0)}
+	w.next(func() {})
+	for _, result := range results(15, 500) {
+		w.Write(nil, models.Run{Results: []models.Result{result}})
+	}
+
+	// a new step starts counting again, so the errors in the last one don't count toward it
+	w.next(func() {})
+	for _, result := range results(15, 200) {
+		w.Write(nil, models.Run{Results: []models.Result{result}})
+	}
+	w.end()
+	if tripped, reason := w.result(); tripped {
+		t.Errorf("tripped = true (%s), want the second step to be counted on its own", reason)
+	}
+}
+
+func TestWatcherErrRateWindow(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name        string
+		ago         []time.Duration // when each error came in
+		wantTripped bool
+		wantRecent  int // errors still in the window
+	}{
+		{
+			name:        "every error in the window",
+			ago:         []time.Duration{3 * time.Second, 2 * time.Second, time.Second},
+			wantTripped: true,
+			wantRecent:  3,
+		},
+		{
+			name:       "one has slid out",
+			ago:        []time.Duration{15 * time.Second, 2 * time.Second, time.Second},
+			wantRecent: 2,
+		},
+		{
+			name: "every one has slid out",
+			ago:  []time.Duration{30 * time.Second, 20 * time.Second, 11 * time.Second},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := &watcher{threshold: NewThreshold(TypeErrRate, 3, 10*time.Second)}
+			for _, ago := range tt.ago {
+				w.recent = append(w.recent, now.Add(-ago))
+			}
+
+			w.check(now)
+			if tripped, reason := w.result(); tripped != tt.wantTripped {
+				t.Errorf("tripped = %v (%s), want %v", tripped, reason, tt.wantTripped)
+			}
+			if len(w.recent) != tt.wantRecent {
+				t.Errorf("%d errors in the window, want %d", len(w.recent), tt.wantRecent)
+			}
+		})
+	}
+}
+
+func TestWatcherRamp(t *testing.T) {
+	profile, err := ramp.NewProfile([]models.Stage{{Target: 10, Duration: 10 * time.Second, Shape: "linear"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		threshold threshold
+		// a run is written with these results once each elapsed time has passed
+		elapsed     []time.Duration
+		results     [][]models.Result
+		wantWorkers []int // for each step that was closed out
+		wantErrors  []int
+	}{
+		{
+			name:        "split into steps",
+			threshold:   NewThreshold(TypeMaxErr, 100, 0),
+			elapsed:     []time.Duration{500 * time.Millisecond, 1500 * time.Millisecond, 3500 * time.Millisecond},
+			results:     [][]models.Result{results(1, 200), results(1, 200), results(1, 0)},
+			wantWorkers: []int{1, 2, 3, 4}, // the last is cut short where the run ended, at 3.5 workers
+			wantErrors:  []int{0, 0, 0, 1},
+		},
+		{
+			// the step it trips in is the last, and the runs that were still going don't add more
+			name:        "stops once it trips",
+			threshold:   NewThreshold(TypeMaxErr, 1, 0),
+			elapsed:     []time.Duration{500 * time.Millisecond, 1500 * time.Millisecond, 5 * time.Second},
+			results:     [][]models.Result{results(1, 200), results(1, 0), results(1, 0)},
+			wantWorkers: []int{1, 2},
+			wantErrors:  []int{0, 1},
+		},
+		{
+			// too few to trip partway through, but every one of them was an error
+			name:        "err-percent checked at the end of a step",
+			threshold:   NewThreshold(TypeErrPercent, 50, 0),
+			elapsed:     []time.Duration{500 * time.Millisecond, 1500 * time.Millisecond, 2500 * time.Millisecond},
+			results:     [][]models.Result{results(5, 200), results(5, 0), results(5, 0)},
+			wantWorkers: []int{1, 2},
+			wantErrors:  []int{0, 5},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := &watcher{threshold: tt.threshold}
+			cancelled := false
+			w.ramp(func() { cancelled = true }, profile, time.Second)
+
+			// Write goes by the time now, so move the start back instead of waiting
+			for i, elapsed := range tt.elapsed {
+				shift := time.Since(w.start) - elapsed
+				w.start, w.stepStart = w.start.Add(shift), w.stepStart.Add(shift)
+				w.Write(nil, models.Run{Results: tt.results[i]})
+			}
+			w.Close()
+
+			workers, errs := []int{}, []int{}
+			for _, step := range w.steps {
+				workers = append(workers, step.Workers)
+				errs = append(errs, step.Errors)
+			}
+			if !slices.Equal(workers, tt.wantWorkers) || !slices.Equal(errs, tt.wantErrors) {
+				t.Errorf("steps had workers %v and errors %v, want %v and %v", workers, errs, tt.wantWorkers, tt.wantErrors)
+			}
+			if tripped, _ := w.result(); cancelled != tripped {
+				t.Errorf("cancelled = %v, want %v since tripped is %v", cancelled, tripped, tripped)
+			}
+		})
+	}
+}
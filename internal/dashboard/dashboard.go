@@ -0,0 +1,168 @@
+/*
+dashboard shows how a benchmark is going while it's still running, so that on a long soak a
+problem can be spotted as it happens rather than in the report at the end.
+
+On a terminal it redraws a small view every second. Otherwise, like in CI, it writes a summary
+line every so often instead
+*/
+package dashboard
+
+import (
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/jonny-burkholder/swarm/internal/histogram"
+	"github.com/jonny-burkholder/swarm/internal/models"
+)
+
+const (
+	refresh    = time.Second      // how often the view is redrawn on a terminal
+	plainEvery = 10 * time.Second // how often a line is written when it's not a terminal
+	history    = 60               // seconds of throughput kept for the sparkline
+	recent     = 10               // seconds the percentiles are worked out over
+)
+
+type Options struct {
+	// TTY redraws the view in place. Otherwise a line is written every Every
+	TTY      bool
+	Every    time.Duration // 0 means every 10s
+	Duration time.Duration // how long the benchmark will take, if that's known
+	Workers  func() int    // how many workers are busy, if that's known
+}
+
+// Dashboard is a sink that keeps rolling stats on the runs that come in, and shows them
+type Dashboard struct {
+	opts Options
+	out  io.Writer
+
+	mu         sync.Mutex
+	start      time.Time
+	collection string
+	runs       int
+	totals     counts
+	slots      [history]slot // one for each second, going round and round
+	lines      int           // how many lines were drawn last time, to go back over
+
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// counts tallies responses by class. classes[0] is requests that got no response
+type counts struct {
+	requests int
+	classes  [6]int
+	failed   int // got a response, but an assertion or the schema failed
+}
+
+func (c *counts) add(result models.Result) {
+	c.requests++
+	if result.Error != nil || result.StatusCode < 100 {
+		c.classes[0]++
+		return
+	}
+	c.classes[min(result.StatusCode/100, 5)]++
+	if !result.Passed() {
+		c.failed++
+	}
+}
+
+type slot struct {
+	second  int // since the start, so that slots left over from last time round can be spotted
+	counts  counts
+	latency *histogram.Histogram
+}
+
+// New starts showing the dashboard on out. Close stops it
+func New(out io.Writer, opts Options) *Dashboard {
+	if opts.Every <= 0 {
+		opts.Every = plainEvery
+	}
+	d := &Dashboard{
+		opts:  opts,
+		out:   out,
+		start: time.Now(),
+		stop:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+	go d.loop()
+	return d
+}
+
+// IsTerminal is true if f is a terminal rather than a file or a pipe. /dev/null is
+// a character device too, but there's no point drawing anything on it
+func IsTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return false
+	}
+	null, err := os.Stat(os.DevNull)
+	return err != nil || !os.SameFile(info, null)
+}
+
+func (d *Dashboard) Write(collection *models.Collection, run models.Run) error {
+	now := time.Now()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.collection = collection.Name
+	d.runs++
+	s := d.slot(now)
+	for _, result := range run.Results {
+		d.totals.add(result)
+		s.counts.add(result)
+		if result.Error == nil {
+			s.latency.Record(int64(result.Duration))
+		}
+	}
+
+	return nil
+}
+
+// slot is the slot for now, emptied out if it was last used a full lap ago
+func (d *Dashboard) slot(now time.Time) *slot {
+	second := int(now.Sub(d.start) / time.Second)
+	s := &d.slots[second%history]
+	if s.second != second || s.latency == nil {
+		*s = slot{second: second, latency: histogram.New()}
+	}
+	return s
+}
+
+// Live is true if the view is redrawn in place, rather than written a line at a time
+func (d *Dashboard) Live() bool {
+	return d.opts.TTY
+}
+
+// Close draws the dashboard one last time, and stops it
+func (d *Dashboard) Close() error {
+	d.closeOnce.Do(func() {
+		close(d.stop)
+		<-d.done
+	})
+	return nil
+}
+
+func (d *Dashboard) loop() {
+	defer close(d.done)
+
+	every := d.opts.Every
+	if d.opts.TTY {
+		every = refresh
+	}
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			d.draw()
+		case <-d.stop:
+			d.draw()
+			return
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package dashboard
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jonny-burkholder/swarm/internal/histogram"
+)
+
+// snapshot is everything that's shown, worked out in one go so the lock isn't held while drawing
+type snapshot struct {
+	elapsed       time.Duration
+	collection    string
+	runs          int
+	workers       int // -1 if it isn't known
+	totals        counts
+	rate          float64 // requests per second over the last full second
+	avg           float64 // requests per second since the start
+	p50, p95, p99 time.Duration
+	spark         []float64 // requests per second for each second, oldest first
+}
+
+func (d *Dashboard) snapshot() snapshot {
+	now := time.Now()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	s := snapshot{
+		elapsed:    now.Sub(d.start),
+		collection: d.collection,
+		runs:       d.runs,
+		workers:    -1,
+		totals:     d.totals,
+	}
+	if d.opts.Workers != nil {
+		s.workers = d.opts.Workers()
+	}
+	if s.elapsed > 0 {
+		s.avg = float64(d.totals.requests) / s.elapsed.Seconds()
+	}
+
+	current := int(s.elapsed / time.Second)
+	latency := histogram.New()
+	for second := max(current-history+1, 0); second <= current; second++ {
+		slot := d.slots[second%history]
+		count := 0
+		if slot.second == second && slot.latency != nil {
+			count = slot.counts.requests
+			if second > current-recent {
+				latency.Merge(slot.latency)
+			}
+		}
+		// the current second isn't over yet, so it would look like a dip
+		if second < current {
+			s.spark = append(s.spark, float64(count))
+		}
+		if second == current-1 {
+			s.rate = float64(count)
+		}
+	}
+	s.p50 = time.Duration(latency.Quantile(0.5))
+	s.p95 = time.Duration(latency.Quantile(0.95))
+	s.p99 = time.Duration(latency.Quantile(0.99))
+
+	return s
+}
+
+func (d *Dashboard) draw() {
+	s := d.snapshot()
+	if !d.opts.TTY {
+		fmt.Fprintln(d.out, d.line(s))
+		return
+	}
+
+	lines := d.frame(s)
+	if d.lines > 0 {
+		// go back up over the last frame, and clear it
+		fmt.Fprintf(d.out, "\x1b[%dA\x1b[J", d.lines)
+	}
+	fmt.Fprint(d.out, strings.Join(lines, "\n")+"\n")
+	d.lines = len(lines)
+}
+
+// frame is the view drawn on a terminal
+func (d *Dashboard) frame(s snapshot) []string {
+	elapsed := s.elapsed.Truncate(time.Second).String()
+	if d.opts.Duration > 0 {
+		elapsed += " / " + d.opts.Duration.String()
+	}
+	workers := ""
+	if s.workers >= 0 {
+		workers = fmt.Sprintf("%d busy", s.workers)
+	}
+
+	return []string{
+		fmt.Sprintf("swarm ─ %s ─ %s", s.collection, elapsed),
+		fmt.Sprintf("workers    %-12s runs %-10d requests %d", workers, s.runs, s.totals.requests),
+		fmt.Sprintf("req/s      %-12s avg %.1f", fmt.Sprintf("%.1f", s.rate), s.avg),
+		fmt.Sprintf("latency    p50 %-10s p95 %-10s p99 %-10s (last %ds)", round(s.p50), round(s.p95), round(s.p99), recent),
+		"responses  " + formatCounts(s.totals),
+		fmt.Sprintf("req/s      %s (last %ds)", sparkline(s.spark), history),
+	}
+}
+
+// line is a one line summary, for when it's not a terminal
+func (d *Dashboard) line(s snapshot) string {
+	parts := []string{fmt.Sprintf("[%s] %s:", s.elapsed.Truncate(time.Second), s.collection)}
+	if s.workers >= 0 {
+		parts = append(parts, fmt.Sprintf("%d workers,", s.workers))
+	}
+	parts = append(parts,
+		fmt.Sprintf("%.1f req/s (%.1f avg),", s.rate, s.avg),
+		fmt.Sprintf("p50 %s p95 %s p99 %s,", round(s.p50), round(s.p95), round(s.p99)),
+		formatCounts(s.totals),
+	)
+	return strings.Join(parts, " ")
+}
+
+// formatCounts formats responses by class, like "2xx 5600  4xx 12  no response 2".
+// 2xx is always there, the rest only once they've happened
+func formatCounts(c counts) string {
+	parts := []string{}
+	for class := 1; class < len(c.classes); class++ {
+		if c.classes[class] > 0 || class == 2 {
+			parts = append(parts, fmt.Sprintf("%dxx %d", class, c.classes[class]))
+		}
+	}
+	if c.classes[0] > 0 {
+		parts = append(parts, fmt.Sprintf("no response %d", c.classes[0]))
+	}
+	if c.failed > 0 {
+		parts = append(parts, fmt.Sprintf("failed %d", c.failed))
+	}
+	return strings.Join(parts, "  ")
+}
+
+// sparkline draws values as bars, scaled so the biggest is a full bar
+func sparkline(values []float64) string {
+	bars := [...]rune{'▁', '▂', '▃', '▄', '▅', '▆', '▇', '█'}
+	most := 0.0
+	for _, v := range values {
+		most = max(most, v)
+	}
+	var b strings.Builder
+	for _, v := range values {
+		i := 0
+		if most > 0 {
+			i = int(v / most * float64(len(bars)-1))
+		}
+		b.WriteRune(bars[i])
+	}
+	return b.String()
+}
+
+func round(d time.Duration) time.Duration {
+	if d >= time.Second {
+		return d.Round(time.Millisecond)
+	}
+	return d.Round(time.Microsecond)
+}
@@ -0,0 +1,24 @@
+package dashboard
+
+import "testing"
+
+func TestSparkline(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []float64
+		want   string
+	}{
+		{name: "empty", values: nil, want: ""},
+		{name: "all zero", values: []float64{0, 0, 0}, want: "▁▁▁"},
+		{name: "scaled to the biggest", values: []float64{0, 50, 100}, want: "▁▄█"},
+		{name: "one value", values: []float64{3}, want: "█"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sparkline(tt.values); got != tt.want {
+				t.Errorf("sparkline(%v) = %q, want %q", tt.values, got, tt.want)
+			}
+		})
+	}
+}
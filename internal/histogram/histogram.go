@@ -0,0 +1,268 @@
+/*
+histogram is an HDR style histogram, for keeping track of latencies without keeping every one.
+
+Values are counted in buckets whose width grows with the value, so that every value is recorded
+to within about 0.1% of what it really was, whether it's 50ns or 50s. Memory depends only on the
+largest value recorded, never on how many values there are, and two histograms can be merged
+without losing anything, so results from different workers, runs, or files can be added together
+*/
+package histogram
+
+import (
+	"encoding/json"
+	"fmt"
+	"iter"
+	"math"
+	"math/bits"
+	"slices"
+)
+
+const (
+	// every power of 2 is split into subBuckets/2 buckets, which is what sets the precision
+	subBucketBits = 11
+	subBuckets    = 1 << subBucketBits
+	halfBuckets   = subBuckets / 2
+)
+
+type Histogram struct {
+	counts []uint64 // grown as larger values come in
+	count  uint64
+	min    int64
+	max    int64
+
+	// the mean and the sum of squared differences from it, kept
+	// with welford's algorithm so the stddev doesn't lose precision
+	mean float64
+	m2   float64
+}
+
+func New() *Histogram {
+	return &Histogram{}
+}
+
+// index finds the bucket v goes in. Values below subBuckets get a bucket each, after
+// that each power of 2 gets halfBuckets buckets
+func index(v int64) int {
+	if v < subBuckets {
+		return int(v)
+	}
+	shift := bits.Len64(uint64(v)) - subBucketBits
+	top := int(v >> shift) // always between halfBuckets and subBuckets-1
+	return subBuckets + (shift-1)*halfBuckets + top - halfBuckets
+}
+
+// highest is the largest value that would go in bucket i
+func highest(i int) int64 {
+	if i < subBuckets {
+		return int64(i)
+	}
+	shift := (i-subBuckets)/halfBuckets + 1
+	top := int64((i-subBuckets)%halfBuckets + halfBuckets)
+	return (top+1)<<shift - 1
+}
+
+// Record adds a value. Negative values are counted as 0
+func (h *Histogram) Record(v int64) {
+	v = max(v, 0)
+	i := index(v)
+	if i >= len(h.counts) {
+		h.counts = append(h.counts, make([]uint64, i+1-len(h.counts))...)
+	}
+	h.counts[i]++
+
+	if h.count == 0 || v < h.min {
+		h.min = v
+	}
+	h.max = max(h.max, v)
+
+	h.count++
+	delta := float64(v) - h.mean
+	h.mean += delta / float64(h.count)
+	h.m2 += delta * (float64(v) - h.mean)
+}
+
+// Merge adds every value in o to h
+func (h *Histogram) Merge(o *Histogram) {
+	if o == nil || o.count == 0 {
+		return
+	}
+	if len(o.counts) > len(h.counts) {
+		h.counts = append(h.counts, make([]uint64, len(o.counts)-len(h.counts))...)
+	}
+	for i, c := range o.counts {
+		h.counts[i] += c
+	}
+
+	if h.count == 0 || o.min < h.min {
+		h.min = o.min
+	}
+	h.max = max(h.max, o.max)
+
+	// chan et al's way of combining two lots of welford
+	n := float64(h.count + o.count)
+	delta := o.mean - h.mean
+	h.m2 += o.m2 + delta*delta*float64(h.count)*float64(o.count)/n
+	h.mean += delta * float64(o.count) / n
+	h.count += o.count
+}
+
+// Clone returns a copy of h
+func (h *Histogram) Clone() *Histogram {
+	c := *h
+	c.counts = slices.Clone(h.counts)
+	return &c
+}
+
+// Corrected returns a copy of h corrected for coordinated omission, the way HdrHistogram's
+// expected interval correction does it. interval is how often values were meant to be
+// recorded. A value of v means the ones due at v-interval, v-2*interval and so on up to
+// interval never got recorded, because whatever recorded them was stuck waiting, so those
+// are added in. It's only as accurate as the buckets are, so values are to within about 0.1%
+func (h *Histogram) Corrected(interval int64) *Histogram {
+	c := h.Clone()
+	if interval <= 0 {
+		return c
+	}
+
+	for v, n := range h.Buckets() {
+		for m := v - interval; m >= interval; m -= interval {
+			c.recordN(m, n)
+		}
+	}
+
+	return c
+}
+
+// recordN adds n of the same value
+func (h *Histogram) recordN(v int64, n uint64) {
+	i := index(v)
+	if i >= len(h.counts) {
+		h.counts = append(h.counts, make([]uint64, i+1-len(h.counts))...)
+	}
+	h.counts[i] += n
+
+	if h.count == 0 || v < h.min {
+		h.min = v
+	}
+	h.max = max(h.max, v)
+
+	// the same as merging, where the other lot has a mean of v and nothing deviates from it
+	total := float64(h.count + n)
+	delta := float64(v) - h.mean
+	h.m2 += delta * delta * float64(h.count) * float64(n) / total
+	h.mean += delta * float64(n) / total
+	h.count += n
+}
+
+func (h *Histogram) Count() uint64 {
+	return h.count
+}
+
+func (h *Histogram) Min() int64 {
+	return h.min
+}
+
+func (h *Histogram) Max() int64 {
+	return h.max
+}
+
+func (h *Histogram) Mean() float64 {
+	return h.mean
+}
+
+// StdDev is the population standard deviation
+func (h *Histogram) StdDev() float64 {
+	if h.count == 0 {
+		return 0
+	}
+	return math.Sqrt(h.m2 / float64(h.count))
+}
+
+// Quantile returns the value that q of all values are at or below, e.g. 0.99 for p99.
+// It's the top of the bucket the value is in, so it errs on the slow side
+func (h *Histogram) Quantile(q float64) int64 {
+	if h.count == 0 {
+		return 0
+	}
+	q = min(max(q, 0), 1)
+
+	want := uint64(math.Ceil(q * float64(h.count)))
+	want = max(want, 1)
+	seen := uint64(0)
+	for i, c := range h.counts {
+		seen += c
+		if seen >= want {
+			return min(max(highest(i), h.min), h.max)
+		}
+	}
+	return h.max
+}
+
+// Buckets yields the value and count of every bucket with something in it, smallest
+// first. Values are the top of each bucket, the same as Quantile gives
+func (h *Histogram) Buckets() iter.Seq2[int64, uint64] {
+	return func(yield func(int64, uint64) bool) {
+		for i, c := range h.counts {
+			if c == 0 {
+				continue
+			}
+			if !yield(min(max(highest(i), h.min), h.max), c) {
+				return
+			}
+		}
+	}
+}
+
+// histogramJSON is how a histogram is saved. Only buckets with something
+// in them are kept, as [bucket, count] pairs
+type histogramJSON struct {
+	Count   uint64      `json:"count"`
+	Min     int64       `json:"min"`
+	Max     int64       `json:"max"`
+	Mean    float64     `json:"mean"`
+	M2      float64     `json:"m2"`
+	Buckets [][2]uint64 `json:"buckets"`
+}
+
+func (h *Histogram) MarshalJSON() ([]byte, error) {
+	out := histogramJSON{
+		Count:   h.count,
+		Min:     h.min,
+		Max:     h.max,
+		Mean:    h.mean,
+		M2:      h.m2,
+		Buckets: [][2]uint64{},
+	}
+	for i, c := range h.counts {
+		if c > 0 {
+			out.Buckets = append(out.Buckets, [2]uint64{uint64(i), c})
+		}
+	}
+	return json.Marshal(out)
+}
+
+func (h *Histogram) UnmarshalJSON(data []byte) error {
+	in := histogramJSON{}
+	if err := json.Unmarshal(data, &in); err != nil {
+		return err
+	}
+
+	*h = Histogram{
+		count: in.Count,
+		min:   in.Min,
+		max:   in.Max,
+		mean:  in.Mean,
+		m2:    in.M2,
+	}
+	for _, b := range in.Buckets {
+		i := int(b[0])
+		if i < 0 || i > index(math.MaxInt64) {
+			return fmt.Errorf("histogram bucket %d is out of range", b[0])
+		}
+		if i >= len(h.counts) {
+			h.counts = append(h.counts, make([]uint64, i+1-len(h.counts))...)
+		}
+		h.counts[i] += b[1]
+	}
+	return nil
+}
@@ -0,0 +1,256 @@
+package histogram
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+)
+
+// within is true if got is within 0.1% of want
+func within(got, want int64) bool {
+	return math.Abs(float64(got-want)) <= math.Max(float64(want)*0.001, 1)
+}
+
+func TestRecordQuantile(t *testing.T) {
+	tests := []struct {
+		name      string
+		values    []int64
+		quantiles map[float64]int64
+		wantMin   int64
+		wantMax   int64
+		wantMean  float64
+	}{
+		{
+			name:      "empty",
+			quantiles: map[float64]int64{0.5: 0, 0.99: 0},
+		},
+		{
+			name:      "one value",
+			values:    []int64{42},
+			quantiles: map[float64]int64{0: 42, 0.5: 42, 1: 42},
+			wantMin:   42,
+			wantMax:   42,
+			wantMean:  42,
+		},
+		{
+			name:      "small values are exact",
+			values:    []int64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10},
+			quantiles: map[float64]int64{0.1: 1, 0.5: 5, 0.9: 9, 0.99: 10, 1: 10},
+			wantMin:   1,
+			wantMax:   10,
+			wantMean:  5.5,
+		},
+		{
+			name:      "large values are within 0.1%",
+			values:    []int64{1_000_000, 2_000_000, 3_000_000, 4_000_000},
+			quantiles: map[float64]int64{0.25: 1_000_000, 0.5: 2_000_000, 0.75: 3_000_000, 1: 4_000_000},
+			wantMin:   1_000_000,
+			wantMax:   4_000_000,
+			wantMean:  2_500_000,
+		},
+		{
+			name:      "negative values count as 0",
+			values:    []int64{-5, 10},
+			quantiles: map[float64]int64{0.5: 0, 1: 10},
+			wantMin:   0,
+			wantMax:   10,
+			wantMean:  5,
+		},
+		{
+			name:      "quantiles outside 0 to 1 are clamped",
+			values:    []int64{3, 7},
+			quantiles: map[float64]int64{-1: 3, 2: 7},
+			wantMin:   3,
+			wantMax:   7,
+			wantMean:  5,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := New()
+			for _, v := range tt.values {
+				h.Record(v)
+			}
+
+			if h.Count() != uint64(len(tt.values)) {
+				t.Errorf("Count() = %d, want %d", h.Count(), len(tt.values))
+			}
+			if h.Min() != tt.wantMin || h.Max() != tt.wantMax {
+				t.Errorf("Min(), Max() = %d, %d, want %d, %d", h.Min(), h.Max(), tt.wantMin, tt.wantMax)
+			}
+			if h.Mean() != tt.wantMean {
+				t.Errorf("Mean() = %v, want %v", h.Mean(), tt.wantMean)
+			}
+			for q, want := range tt.quantiles {
+				if got := h.Quantile(q); !within(got, want) {
+					t.Errorf("Quantile(%v) = %d, want %d", q, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestStdDev(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []int64
+		want   float64
+	}{
+		{name: "empty", want: 0},
+		{name: "all the same", values: []int64{5, 5, 5}, want: 0},
+		{name: "spread out", values: []int64{2, 4, 4, 4, 5, 5, 7, 9}, want: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := New()
+			for _, v := range tt.values {
+				h.Record(v)
+			}
+			if got := h.StdDev(); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("StdDev() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMerge(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b []int64
+	}{
+		{name: "both empty"},
+		{name: "into empty", b: []int64{1, 2, 3}},
+		{name: "empty into", a: []int64{1, 2, 3}},
+		{name: "overlapping", a: []int64{1, 5, 9}, b: []int64{2, 5, 8}},
+		{name: "different sizes", a: []int64{10}, b: []int64{5_000_000, 1_000_000_000}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b, all := New(), New(), New()
+			for _, v := range tt.a {
+				a.Record(v)
+				all.Record(v)
+			}
+			for _, v := range tt.b {
+				b.Record(v)
+				all.Record(v)
+			}
+			a.Merge(b)
+
+			if a.Count() != all.Count() || a.Min() != all.Min() || a.Max() != all.Max() {
+				t.Errorf("merged count, min, max = %d, %d, %d, want %d, %d, %d",
+					a.Count(), a.Min(), a.Max(), all.Count(), all.Min(), all.Max())
+			}
+			if math.Abs(a.Mean()-all.Mean()) > 1e-6 || math.Abs(a.StdDev()-all.StdDev()) > 1e-6 {
+				t.Errorf("merged mean, stddev = %v, %v, want %v, %v", a.Mean(), a.StdDev(), all.Mean(), all.StdDev())
+			}
+			for _, q := range []float64{0.5, 0.9, 0.99} {
+				if a.Quantile(q) != all.Quantile(q) {
+					t.Errorf("merged Quantile(%v) = %d, want %d", q, a.Quantile(q), all.Quantile(q))
+				}
+			}
+		})
+	}
+}
+
+func TestCorrected(t *testing.T) {
+	tests := []struct {
+		name      string
+		values    []int64
+		interval  int64
+		wantCount uint64
+		wantMin   int64
+	}{
+		{
+			name:      "no interval",
+			values:    []int64{100},
+			interval:  0,
+			wantCount: 1,
+			wantMin:   100,
+		},
+		{
+			name:      "nothing held up",
+			values:    []int64{5, 8},
+			interval:  10,
+			wantCount: 2,
+			wantMin:   5,
+		},
+		{
+			name:      "one slow value fills in the ones it held up",
+			values:    []int64{100},
+			interval:  10,
+			wantCount: 10, // 100, and 90 down to 10
+			wantMin:   10,
+		},
+		{
+			name:      "each value is filled in for",
+			values:    []int64{30, 30, 5},
+			interval:  10,
+			wantCount: 7, // each 30 adds a 20 and a 10
+			wantMin:   5,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := New()
+			for _, v := range tt.values {
+				h.Record(v)
+			}
+			c := h.Corrected(tt.interval)
+
+			if c.Count() != tt.wantCount {
+				t.Errorf("Count() = %d, want %d", c.Count(), tt.wantCount)
+			}
+			if c.Min() != tt.wantMin {
+				t.Errorf("Min() = %d, want %d", c.Min(), tt.wantMin)
+			}
+			if c.Max() != h.Max() {
+				t.Errorf("Max() = %d, want %d", c.Max(), h.Max())
+			}
+			if h.Count() != uint64(len(tt.values)) {
+				t.Errorf("the original was changed, Count() = %d, want %d", h.Count(), len(tt.values))
+			}
+		})
+	}
+}
+
+func TestJSON(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []int64
+	}{
+		{name: "empty"},
+		{name: "values", values: []int64{1, 500, 250_000, 3_000_000_000}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := New()
+			for _, v := range tt.values {
+				h.Record(v)
+			}
+			b, err := json.Marshal(h)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := New()
+			if err := json.Unmarshal(b, got); err != nil {
+				t.Fatal(err)
+			}
+
+			if got.Count() != h.Count() || got.Min() != h.Min() || got.Max() != h.Max() {
+				t.Errorf("count, min, max = %d, %d, %d, want %d, %d, %d",
+					got.Count(), got.Min(), got.Max(), h.Count(), h.Min(), h.Max())
+			}
+			for _, q := range []float64{0.5, 0.99} {
+				if got.Quantile(q) != h.Quantile(q) {
+					t.Errorf("Quantile(%v) = %d, want %d", q, got.Quantile(q), h.Quantile(q))
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,108 @@
+/*
+	jsonpath finds values in decoded json documents.
+
+Paths are a simple dotted syntax, e.g. "data.items[0].id". A leading "$" is allowed but
+not required, and negative indexes count back from the end of an array
+*/
+package jsonpath
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Path is a parsed path into a json document
+type Path []segment
+
+// segment is either a key in an object or an index in an array
+type segment struct {
+	key     string
+	index   int
+	isIndex bool
+}
+
+// Parse parses a path. An empty path (or just "$") refers to the whole document
+func Parse(path string) (Path, error) {
+	path = strings.TrimPrefix(path, "$")
+	res := Path{}
+
+	for i := 0; i < len(path); {
+		switch path[i] {
+		case '.':
+			i++
+			end := i
+			for end < len(path) && path[end] != '.' && path[end] != '[' {
+				end++
+			}
+			if end == i {
+				return nil, fmt.Errorf("invalid path '%s': empty key at position %d", path, i)
+			}
+			res = append(res, segment{key: path[i:end]})
+			i = end
+		case '[':
+			end := strings.IndexByte(path[i:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("invalid path '%s': missing ']'", path)
+			}
+			raw := path[i+1 : i+end]
+			idx, err := strconv.Atoi(raw)
+			if err != nil {
+				return nil, fmt.Errorf("invalid path '%s': '%s' is not an array index", path, raw)
+			}
+			res = append(res, segment{index: idx, isIndex: true})
+			i += end + 1
+		default:
+			// the first key doesn't need a leading dot
+			if i != 0 {
+				return nil, fmt.Errorf("invalid path '%s': unexpected '%c' at position %d", path, path[i], i)
+			}
+			path = "." + path
+		}
+	}
+
+	return res, nil
+}
+
+// Get returns the value at the path in doc, and whether it was there at all.
+// doc should be the result of decoding json into an any
+func (p Path) Get(doc any) (any, bool) {
+	cur := doc
+	for _, seg := range p {
+		if seg.isIndex {
+			arr, ok := cur.([]any)
+			if !ok {
+				return nil, false
+			}
+			idx := seg.index
+			if idx < 0 {
+				idx += len(arr)
+			}
+			if idx < 0 || idx >= len(arr) {
+				return nil, false
+			}
+			cur = arr[idx]
+			continue
+		}
+
+		obj, ok := cur.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		cur, ok = obj[seg.key]
+		if !ok {
+			return nil, false
+		}
+	}
+	return cur, true
+}
+
+// Get parses path and returns the value at it in doc
+func Get(doc any, path string) (any, bool, error) {
+	p, err := Parse(path)
+	if err != nil {
+		return nil, false, err
+	}
+	v, ok := p.Get(doc)
+	return v, ok, nil
+}
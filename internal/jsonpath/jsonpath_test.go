@@ -0,0 +1,63 @@
+package jsonpath
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+)
+
+func TestGet(t *testing.T) {
+	var doc any
+	if err := json.Unmarshal([]byte(`{
+		"data": {
+			"items": [{"id": 1}, {"id": 2}, {"id": 3}],
+			"name": "books",
+			"empty": null
+		},
+		"a.b": true
+	}`), &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		path      string
+		want      any
+		wantFound bool
+		wantErr   bool
+	}{
+		{name: "key", path: "data.name", want: "books", wantFound: true},
+		{name: "leading dot", path: ".data.name", want: "books", wantFound: true},
+		{name: "leading $", path: "$.data.name", want: "books", wantFound: true},
+		{name: "index", path: "data.items[1].id", want: float64(2), wantFound: true},
+		{name: "negative index", path: "data.items[-1].id", want: float64(3), wantFound: true},
+		{name: "null is found", path: "data.empty", want: nil, wantFound: true},
+		{name: "whole document", path: "$", want: doc, wantFound: true},
+		{name: "missing key", path: "data.missing", wantFound: false},
+		{name: "index out of range", path: "data.items[3]", wantFound: false},
+		{name: "negative index out of range", path: "data.items[-4]", wantFound: false},
+		{name: "index into an object", path: "data[0]", wantFound: false},
+		{name: "key of an array", path: "data.items.id", wantFound: false},
+		{name: "key of a string", path: "data.name.id", wantFound: false},
+		{name: "empty key", path: "data..name", wantErr: true},
+		{name: "missing ]", path: "data.items[0", wantErr: true},
+		{name: "index that isn't a number", path: "data.items[first]", wantErr: true},
+		{name: "garbage after an index", path: "data.items[0]id", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, found, err := Get(doc, tt.path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Get(%s) error = %v, want error %v", tt.path, err, tt.wantErr)
+			}
+			if found != tt.wantFound {
+				t.Fatalf("Get(%s) found = %v, want %v", tt.path, found, tt.wantFound)
+			}
+			// the whole document is a map, which can't be compared directly
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("Get(%s) = %v, want %v", tt.path, got, tt.want)
+			}
+		})
+	}
+}
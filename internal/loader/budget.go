@@ -0,0 +1,130 @@
+package loader
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jonny-burkholder/swarm/internal/models"
+	"gopkg.in/yaml.v3"
+)
+
+// LoadBudgets reads the regression budgets in the file at path
+func LoadBudgets(path string) ([]models.Budget, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ParseBudgets(path, f)
+}
+
+// ParseBudgets parses regression budgets from r. The file is a list of budgets, like this:
+//
+//	# every request, in every collection
+//	- p95: +10%
+//	  error_rate: 0.5%
+//	# one collection as a whole
+//	- collection: library
+//	  request: total
+//	  p99: 300ms
+//	  throughput: -5%
+//
+// Limits with a sign and a % are relative to the baseline. Anything else is an absolute
+// limit: a duration for latencies, a percent for the error rate, or requests per second
+func ParseBudgets(name string, r io.Reader) ([]models.Budget, error) {
+	var doc yaml.Node
+	if err := yaml.NewDecoder(r).Decode(&doc); err != nil {
+		if errors.Is(err, io.EOF) {
+			return []models.Budget{}, nil
+		}
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	if len(doc.Content) == 0 {
+		return []models.Budget{}, nil
+	}
+
+	p := parser{file: name}
+	budgets := []models.Budget{}
+	for _, item := range p.items(doc.Content[0], "budgets") {
+		budgets = append(budgets, p.budget(item))
+	}
+	if len(p.errs) > 0 {
+		return nil, errors.Join(p.errs...)
+	}
+
+	return budgets, nil
+}
+
+func (p *parser) budget(node *yaml.Node) models.Budget {
+	b := models.Budget{}
+	limits := 0
+	for _, kv := range p.pairs(node, "budget") {
+		key, val := kv[0], kv[1]
+		switch key.Value {
+		case "collection":
+			b.Collection = p.str(val, key.Value)
+		case "request":
+			b.Request = p.str(val, key.Value)
+		default:
+			if _, ok := models.BudgetMetric(key.Value); !ok {
+				p.errorf(key, "unknown budget key '%s'", key.Value)
+				continue
+			}
+			limits++
+			if limit, ok := p.limit(key.Value, val); ok {
+				b.Limits = append(b.Limits, limit)
+			}
+		}
+	}
+	if limits == 0 {
+		p.errorf(node, "budget doesn't limit anything")
+	}
+	return b
+}
+
+func (p *parser) limit(metric string, node *yaml.Node) (models.Limit, bool) {
+	raw := strings.TrimSpace(p.str(node, metric))
+	limit := models.Limit{Metric: metric, Raw: raw}
+
+	if (strings.HasPrefix(raw, "+") || strings.HasPrefix(raw, "-")) && strings.HasSuffix(raw, "%") {
+		pct, err := strconv.ParseFloat(strings.TrimSuffix(raw, "%"), 64)
+		if err != nil {
+			p.errorf(node, "invalid %s budget '%s', expected a change like +10%%", metric, raw)
+			return limit, false
+		}
+		limit.Relative, limit.Value = true, pct
+		return limit, true
+	}
+
+	switch metric {
+	case "error_rate":
+		pct, err := strconv.ParseFloat(strings.TrimSuffix(raw, "%"), 64)
+		if err != nil || pct < 0 {
+			p.errorf(node, "invalid error_rate budget '%s', expected a percent like 0.5%%, or a change like +10%%", raw)
+			return limit, false
+		}
+		limit.Value = pct
+	case "throughput":
+		rps, err := strconv.ParseFloat(raw, 64)
+		if err != nil || rps < 0 {
+			p.errorf(node, "invalid throughput budget '%s', expected requests per second, or a change like -5%%", raw)
+			return limit, false
+		}
+		limit.Value = rps
+	default:
+		d, err := time.ParseDuration(raw)
+		if err != nil || d < 0 {
+			p.errorf(node, "invalid %s budget '%s', expected a duration like 250ms, or a change like +10%%", metric, raw)
+			return limit, false
+		}
+		limit.Value = float64(d)
+	}
+
+	return limit, true
+}
@@ -0,0 +1,114 @@
+package loader
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jonny-burkholder/swarm/internal/models"
+)
+
+func TestParseBudgets(t *testing.T) {
+	tests := []struct {
+		name    string
+		yaml    string
+		want    []models.Budget
+		wantErr []string
+	}{
+		{
+			name: "relative and absolute limits",
+			yaml: `
+- p95: +10%
+  error_rate: 0.5%
+- collection: library
+  request: total
+  p99: 300ms
+  throughput: -5%
+`,
+			want: []models.Budget{
+				{Limits: []models.Limit{
+					{Metric: "p95", Relative: true, Value: 10, Raw: "+10%"},
+					{Metric: "error_rate", Value: 0.5, Raw: "0.5%"},
+				}},
+				{Collection: "library", Request: "total", Limits: []models.Limit{
+					{Metric: "p99", Value: float64(300 * time.Millisecond), Raw: "300ms"},
+					{Metric: "throughput", Relative: true, Value: -5, Raw: "-5%"},
+				}},
+			},
+		},
+		{
+			name: "absolute throughput",
+			yaml: "- throughput: 250\n",
+			want: []models.Budget{
+				{Limits: []models.Limit{{Metric: "throughput", Value: 250, Raw: "250"}}},
+			},
+		},
+		{
+			name: "empty file",
+			yaml: "",
+		},
+		{
+			name: "unknown metric",
+			yaml: "- p95: +10%\n  p75: +10%\n",
+			wantErr: []string{
+				"test.yml:2: unknown budget key 'p75'",
+			},
+		},
+		{
+			name: "nothing limited",
+			yaml: "- collection: library\n",
+			wantErr: []string{
+				"test.yml:1: budget doesn't limit anything",
+			},
+		},
+		{
+			name: "bad limits",
+			yaml: `
+- p95: 10
+  error_rate: lots
+  throughput: fast
+  mean: +ten%
+`,
+			wantErr: []string{
+				"test.yml:2: invalid p95 budget '10', expected a duration like 250ms, or a change like +10%",
+				"test.yml:3: invalid error_rate budget 'lots', expected a percent like 0.5%, or a change like +10%",
+				"test.yml:4: invalid throughput budget 'fast', expected requests per second, or a change like -5%",
+				"test.yml:5: invalid mean budget '+ten%', expected a change like +10%",
+			},
+		},
+		{
+			name: "not a list",
+			yaml: "p95: +10%\n",
+			wantErr: []string{
+				"test.yml:1: budgets must be a list",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseBudgets("test.yml", strings.NewReader(tt.yaml))
+			if tt.wantErr != nil {
+				if err == nil {
+					t.Fatalf("ParseBudgets() error = nil, want %q", tt.wantErr)
+				}
+				if lines := strings.Split(err.Error(), "\n"); !slices.Equal(lines, tt.wantErr) {
+					t.Errorf("ParseBudgets() error =\n%s\nwant\n%s", strings.Join(lines, "\n"), strings.Join(tt.wantErr, "\n"))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseBudgets() error = %v", err)
+			}
+			if got == nil {
+				t.Fatal("ParseBudgets() = nil, want an empty slice when there are no budgets")
+			}
+			if !slices.EqualFunc(got, tt.want, func(a, b models.Budget) bool {
+				return a.Collection == b.Collection && a.Request == b.Request && slices.Equal(a.Limits, b.Limits)
+			}) {
+				t.Errorf("ParseBudgets() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
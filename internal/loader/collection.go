@@ -0,0 +1,550 @@
+/*
+	loader turns collection files into the models the runners understand.
+
+A collection file is yaml, and looks like this:
+
+	collection: library
+	baseUrl: https://fakelibrary.com/api/v1
+	auth:
+	  type: bearer
+	  token: ${LIBRARY_TOKEN}
+	headers:
+	  Accept: application/json
+	endpoints:
+	  - books:
+	      - get:
+	          params:
+	            author: "Steven Erikson"
+	          assert:
+	            status_code: 200
+
+A single file may hold several collections, separated by yaml document markers (---)
+*/
+package loader
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/jonny-burkholder/swarm/internal/models"
+	"github.com/jonny-burkholder/swarm/internal/schema"
+	"github.com/jonny-burkholder/swarm/internal/templating"
+	"gopkg.in/yaml.v3"
+)
+
+// isMethod is true for the http methods a request can use, in lower case
+func isMethod(method string) bool {
+	switch method {
+	case "get", "head", "post", "put", "patch", "delete", "options":
+		return true
+	}
+	return false
+}
+
+// LoadCollections reads the collection file at path
+func LoadCollections(path string) ([]models.Collection, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ParseCollections(path, f)
+}
+
+// ParseCollections parses collections from r. The name is only used to make error
+// messages more helpful. Every problem found in the file is reported, not just the first
+func ParseCollections(name string, r io.Reader) ([]models.Collection, error) {
+	p := parser{file: name}
+	collections := []models.Collection{}
+
+	dec := yaml.NewDecoder(r)
+	for {
+		var doc yaml.Node
+		err := dec.Decode(&doc)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		if len(doc.Content) == 0 {
+			continue
+		}
+		collections = append(collections, p.collection(doc.Content[0]))
+	}
+
+	if len(p.errs) > 0 {
+		return nil, errors.Join(p.errs...)
+	}
+	if len(collections) == 0 {
+		return nil, fmt.Errorf("%s: %w", name, ErrNoCollections)
+	}
+
+	return collections, nil
+}
+
+// parser walks the yaml nodes of a collection file, collecting
+// errors as it goes rather than stopping at the first one
+type parser struct {
+	file string
+	errs []error
+}
+
+func (p *parser) errorf(node *yaml.Node, format string, args ...any) {
+	p.errs = append(p.errs, ParseError{
+		File: p.file,
+		Line: node.Line,
+		Msg:  fmt.Sprintf(format, args...),
+	})
+}
+
+// pairs returns the key/value pairs of a mapping node
+func (p *parser) pairs(node *yaml.Node, what string) [][2]*yaml.Node {
+	if node.Kind == yaml.ScalarNode && node.Tag == "!!null" {
+		return nil
+	}
+	if node.Kind != yaml.MappingNode {
+		p.errorf(node, "%s must be a mapping", what)
+		return nil
+	}
+	res := make([][2]*yaml.Node, 0, len(node.Content)/2)
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		res = append(res, [2]*yaml.Node{node.Content[i], node.Content[i+1]})
+	}
+	return res
+}
+
+// single returns the only key/value pair of a mapping node, for list items like "- get:"
+func (p *parser) single(node *yaml.Node, what string) (*yaml.Node, *yaml.Node, bool) {
+	pairs := p.pairs(node, what)
+	if len(pairs) != 1 {
+		if node.Kind == yaml.MappingNode || node.Tag == "!!null" {
+			p.errorf(node, "%s must have exactly one key, found %d", what, len(pairs))
+		}
+		return nil, nil, false
+	}
+	return pairs[0][0], pairs[0][1], true
+}
+
+func (p *parser) items(node *yaml.Node, what string) []*yaml.Node {
+	if node.Kind == yaml.ScalarNode && node.Tag == "!!null" {
+		return nil
+	}
+	if node.Kind != yaml.SequenceNode {
+		p.errorf(node, "%s must be a list", what)
+		return nil
+	}
+	return node.Content
+}
+
+func (p *parser) str(node *yaml.Node, what string) string {
+	if node.Kind != yaml.ScalarNode {
+		p.errorf(node, "%s must be a string", what)
+		return ""
+	}
+	return node.Value
+}
+
+func (p *parser) collection(node *yaml.Node) models.Collection {
+	c := models.Collection{
+		Mu: &sync.Mutex{},
+	}
+
+	var auth models.Auth = &models.DefaultAuth{Kind: models.NoAuth}
+	headers := map[string]string{}
+	params := map[string][]string{}
+	var endpoints *yaml.Node
+
+	for _, kv := range p.pairs(node, "collection") {
+		key, val := kv[0], kv[1]
+		switch key.Value {
+		case "collection", "name":
+			c.Name = p.str(val, key.Value)
+		case "baseUrl":
+			c.BaseUrl = p.baseUrl(val)
+		case "kind":
+			if kind := p.str(val, "kind"); kind != "http" {
+				p.errorf(val, "unsupported collection kind '%s', must be http", kind)
+			}
+		case "auth":
+			auth = p.auth(val)
+		case "headers":
+			headers = p.headers(val)
+		case "params":
+			params = p.params(val)
+		case "endpoints":
+			endpoints = val
+		case "data":
+			c.Data = p.data(val)
+		default:
+			p.errorf(key, "unknown collection key '%s'", key.Value)
+		}
+	}
+
+	if c.Name == "" {
+		p.errorf(node, "collection is missing a name")
+	}
+	if endpoints == nil {
+		p.errorf(node, "collection '%s' has no endpoints", c.Name)
+		return c
+	}
+
+	// requests are told apart by name in reports, comparisons and metrics, so
+	// names have to be unique. This is where each was first used
+	names := map[string]int{}
+	for _, endpoint := range p.items(endpoints, "endpoints") {
+		pathNode, reqs, ok := p.single(endpoint, "endpoint")
+		if !ok {
+			continue
+		}
+		path := joinPath(c.BaseUrl, pathNode.Value)
+
+		for _, item := range p.items(reqs, "endpoint "+pathNode.Value) {
+			methodNode, reqNode, ok := p.single(item, "request")
+			if !ok {
+				continue
+			}
+			method := strings.ToLower(methodNode.Value)
+			if !isMethod(method) {
+				p.errorf(methodNode, "unknown http method '%s'", methodNode.Value)
+				continue
+			}
+
+			req := models.Request{
+				Name:        strings.ToUpper(method) + " " + pathNode.Value,
+				Method:      strings.ToUpper(method),
+				Path:        path,
+				Auth:        auth,
+				Headers:     maps.Clone(headers),
+				QueryParams: copyParams(params),
+			}
+			p.request(reqNode, &req)
+			p.templates(methodNode, req)
+			if req.Name == models.TotalName {
+				p.errorf(methodNode, "request name '%s' is used for the collection as a whole, give the request another name", req.Name)
+			} else if line, ok := names[req.Name]; ok {
+				p.errorf(methodNode, "request name '%s' is already used on line %d, give one of them a name", req.Name, line)
+			} else {
+				names[req.Name] = methodNode.Line
+			}
+			c.Requests = append(c.Requests, req)
+		}
+	}
+
+	return c
+}
+
+func (p *parser) request(node *yaml.Node, req *models.Request) {
+	for _, kv := range p.pairs(node, "request") {
+		key, val := kv[0], kv[1]
+		switch key.Value {
+		case "name":
+			req.Name = p.str(val, "name")
+		case "headers":
+			for k, v := range p.headers(val) {
+				req.Headers[k] = v
+			}
+		case "params":
+			for k, v := range p.params(val) {
+				req.QueryParams[k] = v
+			}
+		case "body":
+			req.Body = p.body(val, req)
+		case "auth":
+			req.Auth = p.auth(val)
+		case "assert":
+			req.Assert = p.assertions(val)
+		case "schema":
+			req.Schema = p.schema(val, req.Name)
+		case "extract":
+			req.Extract = p.extractions(val)
+		default:
+			p.errorf(key, "unknown request key '%s'", key.Value)
+		}
+	}
+}
+
+func (p *parser) baseUrl(node *yaml.Node) string {
+	raw := p.str(node, "baseUrl")
+	if raw == "" {
+		return ""
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		p.errorf(node, "invalid baseUrl: %v", err)
+		return raw
+	}
+	if u.Scheme == "" || u.Host == "" {
+		p.errorf(node, "baseUrl '%s' must include a scheme and host, e.g. https://%s", raw, raw)
+	}
+	return raw
+}
+
+func (p *parser) auth(node *yaml.Node) models.Auth {
+	auth := &models.DefaultAuth{Kind: models.NoAuth}
+	typ := ""
+
+	for _, kv := range p.pairs(node, "auth") {
+		key, val := kv[0], kv[1]
+		switch key.Value {
+		case "type":
+			typ = p.str(val, "auth type")
+			switch typ {
+			case "basic":
+				auth.Kind = models.BasicAuth
+			case "bearer":
+				auth.Kind = models.BearerToken
+			case "none":
+				auth.Kind = models.NoAuth
+			default:
+				p.errorf(val, "unknown auth type '%s', must be one of: basic, bearer, none", typ)
+			}
+		// credentials are usually secrets, so let them come from the environment
+		case "username":
+			auth.Userame = os.ExpandEnv(p.str(val, "username"))
+		case "password":
+			auth.Password = os.ExpandEnv(p.str(val, "password"))
+		case "token":
+			auth.Token = os.ExpandEnv(p.str(val, "token"))
+		default:
+			p.errorf(key, "unknown auth key '%s'", key.Value)
+		}
+	}
+
+	if typ == "" && node.Kind == yaml.MappingNode {
+		p.errorf(node, "auth is missing a type")
+	}
+
+	return auth
+}
+
+func (p *parser) headers(node *yaml.Node) map[string]string {
+	headers := map[string]string{}
+	for _, kv := range p.pairs(node, "headers") {
+		// canonical, so that the same header written two ways is only sent once
+		headers[http.CanonicalHeaderKey(kv[0].Value)] = p.str(kv[1], "header "+kv[0].Value)
+	}
+	return headers
+}
+
+// params accepts either a single value or a list of values for each key
+func (p *parser) params(node *yaml.Node) map[string][]string {
+	params := map[string][]string{}
+	for _, kv := range p.pairs(node, "params") {
+		key, val := kv[0], kv[1]
+		if val.Kind == yaml.SequenceNode {
+			for _, v := range val.Content {
+				params[key.Value] = append(params[key.Value], p.str(v, "param "+key.Value))
+			}
+			continue
+		}
+		params[key.Value] = []string{p.str(val, "param "+key.Value)}
+	}
+	return params
+}
+
+// body is sent as-is if it's a string. Anything else is encoded as json
+func (p *parser) body(node *yaml.Node, req *models.Request) []byte {
+	if node.Kind == yaml.ScalarNode {
+		return []byte(node.Value)
+	}
+
+	var v any
+	if err := node.Decode(&v); err != nil {
+		p.errorf(node, "invalid body: %v", err)
+		return nil
+	}
+	// don't escape html characters, it makes templates harder to read
+	buf := &bytes.Buffer{}
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		p.errorf(node, "body can't be encoded as json: %v", err)
+		return nil
+	}
+	if _, ok := req.Headers["Content-Type"]; !ok {
+		req.Headers["Content-Type"] = "application/json"
+	}
+	return unescapeTemplates(bytes.TrimSuffix(buf.Bytes(), []byte("\n")))
+}
+
+// unescapeTemplates undoes the json escaping inside template actions, e.g.
+// {{env \"USER\"}}, so that they still work once they're in a json body
+func unescapeTemplates(body []byte) []byte {
+	out := make([]byte, 0, len(body))
+	for {
+		start := bytes.Index(body, []byte("{{"))
+		if start < 0 {
+			break
+		}
+		end := bytes.Index(body[start:], []byte("}}"))
+		if end < 0 {
+			break
+		}
+		end += start + len("}}")
+
+		action := body[start:end]
+		var s string
+		if err := json.Unmarshal(append(append([]byte{'"'}, action...), '"'), &s); err == nil {
+			action = []byte(s)
+		}
+		out = append(append(out, body[:start]...), action...)
+		body = body[end:]
+	}
+	return append(out, body...)
+}
+
+// templates checks that any variables in the request are written properly,
+// so mistakes show up now instead of on every request
+func (p *parser) templates(node *yaml.Node, req models.Request) {
+	check := func(what, s string) {
+		if err := templating.Check(s); err != nil {
+			p.errorf(node, "invalid template in %s of %s: %v", what, req.Name, err)
+		}
+	}
+
+	check("path", req.Path)
+	for k, v := range req.Headers {
+		check("header "+k, v)
+	}
+	for k, values := range req.QueryParams {
+		for _, v := range values {
+			check("param "+k, v)
+		}
+	}
+	check("body", string(req.Body))
+}
+
+// extractions are either "name: json.<path>" or "name: header.<name>",
+// or "name: {regex: <pattern>}", which keeps the first capture group
+func (p *parser) extractions(node *yaml.Node) []models.Extraction {
+	res := []models.Extraction{}
+	for _, kv := range p.pairs(node, "extract") {
+		name, val := kv[0], kv[1]
+
+		var source, expr string
+		if val.Kind == yaml.MappingNode {
+			src, exprNode, ok := p.single(val, "extraction "+name.Value)
+			if !ok {
+				continue
+			}
+			source, expr = src.Value, p.str(exprNode, "extraction "+name.Value)
+		} else {
+			raw := p.str(val, "extraction "+name.Value)
+			source, expr, _ = strings.Cut(raw, ".")
+			if source == models.ExtractHeader && expr == "" {
+				p.errorf(val, "%s: header extraction needs a header name, e.g. header.Location", name.Value)
+				continue
+			}
+		}
+
+		e, err := models.NewExtraction(name.Value, source, expr)
+		if err != nil {
+			p.errorf(val, "%v", err)
+			continue
+		}
+		res = append(res, e)
+	}
+	return res
+}
+
+// assertions are either "field: value", which checks for equality, or "field: {operator: value}"
+// for anything else. Fields are status_code, response_time, body_size, body, header.<name> and
+// json.<path>. Operators are equal, not_equal, less_than(_or_equal), greater_than(_or_equal),
+// contains, matches, exists, in and type, or their symbols where they have one
+func (p *parser) assertions(node *yaml.Node) []models.Assertion {
+	res := []models.Assertion{}
+	for _, kv := range p.pairs(node, "assert") {
+		field, val := kv[0], kv[1]
+
+		if val.Kind != yaml.MappingNode {
+			p.assertion(field, &res, models.Assertion{
+				Field: field.Value,
+				Value: p.value(val),
+			})
+			continue
+		}
+
+		for _, opkv := range p.pairs(val, "assertion "+field.Value) {
+			op, err := models.ParseOperator(opkv[0].Value)
+			if err != nil {
+				p.errorf(opkv[0], "%v", err)
+				continue
+			}
+			p.assertion(opkv[1], &res, models.Assertion{
+				Field:    field.Value,
+				Value:    p.value(opkv[1]),
+				Operator: op,
+			})
+		}
+	}
+	return res
+}
+
+// assertion adds a to res if it's valid
+func (p *parser) assertion(node *yaml.Node, res *[]models.Assertion, a models.Assertion) {
+	a, err := a.Compile()
+	if err != nil {
+		p.errorf(node, "%v", err)
+		return
+	}
+	*res = append(*res, a)
+}
+
+// schema is either a path to a json or yaml schema file, relative
+// to the collection file, or a schema written inline
+func (p *parser) schema(node *yaml.Node, name string) models.Schema {
+	if node.Kind == yaml.ScalarNode {
+		path := node.Value
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(filepath.Dir(p.file), path)
+		}
+		sch, err := schema.Load(path)
+		if err != nil {
+			p.errorf(node, "invalid schema: %v", err)
+			return nil
+		}
+		return sch
+	}
+
+	sch, err := schema.Compile(name, p.value(node))
+	if err != nil {
+		p.errorf(node, "invalid schema: %v", err)
+		return nil
+	}
+	return sch
+}
+
+func (p *parser) value(node *yaml.Node) any {
+	var v any
+	if err := node.Decode(&v); err != nil {
+		p.errorf(node, "invalid value: %v", err)
+	}
+	return v
+}
+
+func joinPath(baseUrl, path string) string {
+	if baseUrl == "" {
+		return path
+	}
+	return strings.TrimSuffix(baseUrl, "/") + "/" + strings.TrimPrefix(path, "/")
+}
+
+func copyParams(p map[string][]string) map[string][]string {
+	res := make(map[string][]string, len(p))
+	for k, v := range p {
+		res[k] = append([]string{}, v...)
+	}
+	return res
+}
@@ -0,0 +1,252 @@
+package loader
+
+import (
+	"errors"
+	"maps"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseCollections(t *testing.T) {
+	tests := []struct {
+		name         string
+		yaml         string
+		wantRequests []string // names of the first collection's requests
+		wantErr      []string // every error, in order
+	}{
+		{
+			name: "valid",
+			yaml: `
+collection: library
+baseUrl: https://example.com/api
+endpoints:
+  - books:
+      - get:
+      - get:
+          name: GET books by author
+      - post:
+          assert:
+            status_code: 201
+`,
+			wantRequests: []string{"GET books", "GET books by author", "POST books"},
+		},
+		{
+			name: "unknown keys and methods",
+			yaml: `
+collection: library
+timeout: 5s
+endpoints:
+  - books:
+      - fetch:
+`,
+			wantErr: []string{
+				"test.yml:3: unknown collection key 'timeout'",
+				"test.yml:6: unknown http method 'fetch'",
+			},
+		},
+		{
+			name: "missing name and endpoints",
+			yaml: `
+baseUrl: https://example.com
+`,
+			wantErr: []string{
+				"test.yml:2: collection is missing a name",
+				"test.yml:2: collection '' has no endpoints",
+			},
+		},
+		{
+			name: "duplicate request names",
+			yaml: `
+collection: library
+endpoints:
+  - books:
+      - get:
+      - get:
+`,
+			wantErr: []string{
+				"test.yml:6: request name 'GET books' is already used on line 5, give one of them a name",
+			},
+		},
+		{
+			name: "request named total",
+			yaml: `
+collection: library
+endpoints:
+  - books:
+      - get:
+          name: total
+`,
+			wantErr: []string{
+				"test.yml:5: request name 'total' is used for the collection as a whole, give the request another name",
+			},
+		},
+		{
+			name: "wrong kinds of node",
+			yaml: `
+collection: library
+endpoints:
+  books:
+`,
+			wantErr: []string{
+				"test.yml:4: endpoints must be a list",
+			},
+		},
+		{
+			name: "unsupported kind",
+			yaml: `
+collection: library
+kind: grpc
+endpoints:
+  - books:
+      - get:
+`,
+			wantErr: []string{
+				"test.yml:3: unsupported collection kind 'grpc', must be http",
+			},
+		},
+		{
+			name:    "empty",
+			yaml:    "",
+			wantErr: []string{"test.yml: no collections defined"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			collections, err := ParseCollections("test.yml", strings.NewReader(tt.yaml))
+			if tt.wantErr != nil {
+				if err == nil {
+					t.Fatalf("ParseCollections() error = nil, want %q", tt.wantErr)
+				}
+				if got := strings.Split(err.Error(), "\n"); !slices.Equal(got, tt.wantErr) {
+					t.Errorf("ParseCollections() error =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(tt.wantErr, "\n"))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseCollections() error = %v", err)
+			}
+
+			var got []string
+			for _, r := range collections[0].Requests {
+				got = append(got, r.Name)
+			}
+			if !slices.Equal(got, tt.wantRequests) {
+				t.Errorf("requests = %q, want %q", got, tt.wantRequests)
+			}
+		})
+	}
+}
+
+func TestParseCollectionsErrorLine(t *testing.T) {
+	tests := []struct {
+		name     string
+		yaml     string
+		wantLine int
+	}{
+		{
+			name:     "top level",
+			yaml:     "collection: library\nbogus: true\nendpoints:\n  - books:\n      - get:\n",
+			wantLine: 2,
+		},
+		{
+			name:     "in a request",
+			yaml:     "collection: library\nendpoints:\n  - books:\n      - get:\n          bogus: true\n",
+			wantLine: 5,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseCollections("test.yml", strings.NewReader(tt.yaml))
+			var parseErr ParseError
+			if !errors.As(err, &parseErr) {
+				t.Fatalf("ParseCollections() error = %v, want a ParseError", err)
+			}
+			if parseErr.Line != tt.wantLine {
+				t.Errorf("line = %d, want %d (%v)", parseErr.Line, tt.wantLine, err)
+			}
+		})
+	}
+}
+
+func TestUnescapeTemplates(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{
+			name: "no templates",
+			body: `{"name":"swarm"}`,
+			want: `{"name":"swarm"}`,
+		},
+		{
+			name: "quotes in an action",
+			body: `{"user":"{{env \"USER\"}}"}`,
+			want: `{"user":"{{env "USER"}}"}`,
+		},
+		{
+			name: "several actions",
+			body: `{"a":"{{pick \"x\" \"y\"}}","b":"\"quoted\"","c":"{{json .name}}"}`,
+			want: `{"a":"{{pick "x" "y"}}","b":"\"quoted\"","c":"{{json .name}}"}`,
+		},
+		{
+			name: "unclosed action",
+			body: `{"a":"{{env \"USER\""}`,
+			want: `{"a":"{{env \"USER\""}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(unescapeTemplates([]byte(tt.body))); got != tt.want {
+				t.Errorf("unescapeTemplates(%s) = %s, want %s", tt.body, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseCollectionsHeaders(t *testing.T) {
+	yaml := `
+collection: library
+headers:
+  accept: text/plain
+endpoints:
+  - books:
+      - post:
+          headers:
+            Accept: application/json
+            content-type: text/plain
+          body:
+            title: Gardens of the Moon
+      - put:
+          body:
+            title: Deadhouse Gates
+`
+	collections, err := ParseCollections("test.yml", strings.NewReader(yaml))
+	if err != nil {
+		t.Fatalf("ParseCollections() error = %v", err)
+	}
+
+	tests := []struct {
+		request string
+		want    map[string]string
+	}{
+		{
+			request: "POST books",
+			want:    map[string]string{"Accept": "application/json", "Content-Type": "text/plain"},
+		},
+		{
+			request: "PUT books",
+			want:    map[string]string{"Accept": "text/plain", "Content-Type": "application/json"},
+		},
+	}
+	for i, tt := range tests {
+		got := collections[0].Requests[i].Headers
+		if !maps.Equal(got, tt.want) {
+			t.Errorf("%s headers = %v, want %v", tt.request, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package loader
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/jonny-burkholder/swarm/internal/models"
+	"gopkg.in/yaml.v3"
+)
+
+// LoadConfig reads the config file at path. Values that aren't set
+// in the file are taken from cfg
+func LoadConfig(path string, cfg models.Config) (models.Config, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return cfg, err
+	}
+	defer f.Close()
+
+	return ParseConfig(path, f, cfg)
+}
+
+// ParseConfig parses a config from r. Values that aren't set in r are taken from cfg
+func ParseConfig(name string, r io.Reader, cfg models.Config) (models.Config, error) {
+	var doc yaml.Node
+	if err := yaml.NewDecoder(r).Decode(&doc); err != nil {
+		if errors.Is(err, io.EOF) {
+			return cfg, nil
+		}
+		return cfg, fmt.Errorf("%s: %w", name, err)
+	}
+	if len(doc.Content) == 0 {
+		return cfg, nil
+	}
+
+	p := parser{file: name}
+	for _, kv := range p.pairs(doc.Content[0], "config") {
+		key, val := kv[0], kv[1]
+		switch key.Value {
+		case "runs":
+			cfg.Runs = p.int(val, key.Value)
+		case "concurrent":
+			cfg.Concurrent = p.int(val, key.Value)
+		case "async":
+			cfg.Async = p.bool(val, key.Value)
+		case "max_in_flight":
+			cfg.MaxInFlight = p.int(val, key.Value)
+		case "duration":
+			cfg.Duration = p.duration(val, key.Value)
+		case "timeout":
+			cfg.Timeout = p.duration(val, key.Value)
+		case "max_body_size":
+			cfg.MaxBodySize = int64(p.int(val, key.Value))
+		case "discard_body":
+			cfg.DiscardBody = p.bool(val, key.Value)
+		case "keep_failed":
+			cfg.KeepFailed = p.bool(val, key.Value)
+		case "keep_all":
+			cfg.KeepAll = p.bool(val, key.Value)
+		case "ramp":
+			cfg.Ramp = p.ramp(val)
+		case "rate":
+			rate, err := strconv.ParseFloat(p.str(val, key.Value), 64)
+			if err != nil || rate < 0 {
+				p.errorf(val, "rate must be a number of runs per second that isn't negative")
+			}
+			cfg.Rate = rate
+		case "max_workers":
+			cfg.MaxWorkers = p.int(val, key.Value)
+		case "expected_interval":
+			cfg.ExpectedInterval = p.duration(val, key.Value)
+		default:
+			p.errorf(key, "unknown config key '%s'", key.Value)
+		}
+	}
+	if len(p.errs) > 0 {
+		return cfg, errors.Join(p.errs...)
+	}
+
+	return cfg, nil
+}
+
+func (p *parser) int(node *yaml.Node, what string) int {
+	var n int
+	if err := node.Decode(&n); err != nil {
+		p.errorf(node, "%s must be a whole number", what)
+	}
+	return n
+}
+
+func (p *parser) bool(node *yaml.Node, what string) bool {
+	var b bool
+	if err := node.Decode(&b); err != nil {
+		p.errorf(node, "%s must be true or false", what)
+	}
+	return b
+}
+
+func (p *parser) duration(node *yaml.Node, what string) time.Duration {
+	raw := p.str(node, what)
+	d, err := time.ParseDuration(raw)
+	if err != nil {
+		p.errorf(node, "invalid %s '%s', expected something like 30s or 5m", what, raw)
+	}
+	return d
+}
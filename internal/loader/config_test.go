@@ -0,0 +1,100 @@
+package loader
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jonny-burkholder/swarm/internal/models"
+)
+
+func TestParseConfig(t *testing.T) {
+	defaults := models.Config{Runs: 1, Concurrent: 1, Timeout: 30 * time.Second}
+
+	tests := []struct {
+		name    string
+		yaml    string
+		want    models.Config
+		wantErr []string
+	}{
+		{
+			name: "values override the defaults",
+			yaml: `
+runs: 100
+concurrent: 10
+duration: 1m
+async: true
+keep_failed: true
+`,
+			want: models.Config{Runs: 100, Concurrent: 10, Duration: time.Minute, Async: true, KeepFailed: true, Timeout: 30 * time.Second},
+		},
+		{
+			name: "empty file keeps the defaults",
+			yaml: "",
+			want: defaults,
+		},
+		{
+			name: "rate",
+			yaml: "rate: 2.5\nmax_workers: 50\n",
+			want: models.Config{Runs: 1, Concurrent: 1, Timeout: 30 * time.Second, Rate: 2.5, MaxWorkers: 50},
+		},
+		{
+			name: "bad values",
+			yaml: `
+runs: lots
+async: maybe
+timeout: 5 seconds
+`,
+			wantErr: []string{
+				"test.yml:2: runs must be a whole number",
+				"test.yml:3: async must be true or false",
+				"test.yml:4: invalid timeout '5 seconds', expected something like 30s or 5m",
+			},
+		},
+		{
+			name: "unknown key",
+			yaml: "runs: 5\nworkers: 10\n",
+			wantErr: []string{
+				"test.yml:2: unknown config key 'workers'",
+			},
+		},
+		{
+			name: "negative rate",
+			yaml: "rate: -1\n",
+			wantErr: []string{
+				"test.yml:1: rate must be a number of runs per second that isn't negative",
+			},
+		},
+		{
+			name: "not a mapping",
+			yaml: "- runs: 5\n",
+			wantErr: []string{
+				"test.yml:1: config must be a mapping",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := ParseConfig("test.yml", strings.NewReader(tt.yaml), defaults)
+			if tt.wantErr != nil {
+				if err == nil {
+					t.Fatalf("ParseConfig() error = nil, want %q", tt.wantErr)
+				}
+				if got := strings.Split(err.Error(), "\n"); !slices.Equal(got, tt.wantErr) {
+					t.Errorf("ParseConfig() error =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(tt.wantErr, "\n"))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseConfig() error = %v", err)
+			}
+			if cfg.Runs != tt.want.Runs || cfg.Concurrent != tt.want.Concurrent || cfg.Duration != tt.want.Duration ||
+				cfg.Async != tt.want.Async || cfg.KeepFailed != tt.want.KeepFailed || cfg.Timeout != tt.want.Timeout ||
+				cfg.Rate != tt.want.Rate || cfg.MaxWorkers != tt.want.MaxWorkers {
+				t.Errorf("ParseConfig() = %+v, want %+v", cfg, tt.want)
+			}
+		})
+	}
+}
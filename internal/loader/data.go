@@ -0,0 +1,166 @@
+package loader
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jonny-burkholder/swarm/internal/models"
+	"gopkg.in/yaml.v3"
+)
+
+// data parses a collection's data source:
+//
+//	data:
+//	  file: users.csv           # csv with a header row, or jsonl. Relative to the collection file
+//	  mode: sequential          # or random, or partitioned
+//	  on_exhausted: wrap        # or stop
+func (p *parser) data(node *yaml.Node) *models.DataSet {
+	ds := &models.DataSet{
+		Mode: models.DataSequential,
+		Wrap: true,
+	}
+	format := ""
+
+	for _, kv := range p.pairs(node, "data") {
+		key, val := kv[0], kv[1]
+		switch key.Value {
+		case "file":
+			ds.File = p.str(val, "data file")
+		case "format":
+			format = p.str(val, "data format")
+			if format != "csv" && format != "jsonl" {
+				p.errorf(val, "unknown data format '%s', must be csv or jsonl", format)
+			}
+		case "mode":
+			ds.Mode = p.str(val, "data mode")
+			switch ds.Mode {
+			case models.DataSequential, models.DataRandom, models.DataPartitioned:
+			default:
+				p.errorf(val, "unknown data mode '%s', must be one of: sequential, random, partitioned", ds.Mode)
+			}
+		case "on_exhausted":
+			switch v := p.str(val, "on_exhausted"); v {
+			case "wrap":
+				ds.Wrap = true
+			case "stop":
+				ds.Wrap = false
+			default:
+				p.errorf(val, "unknown on_exhausted '%s', must be wrap or stop", v)
+			}
+		default:
+			p.errorf(key, "unknown data key '%s'", key.Value)
+		}
+	}
+
+	if ds.File == "" {
+		p.errorf(node, "data is missing a file")
+		return nil
+	}
+	if !filepath.IsAbs(ds.File) {
+		ds.File = filepath.Join(filepath.Dir(p.file), ds.File)
+	}
+	if format == "" {
+		format = "csv"
+		if ext := strings.ToLower(filepath.Ext(ds.File)); ext == ".jsonl" || ext == ".ndjson" {
+			format = "jsonl"
+		}
+	}
+
+	rows, err := readData(ds.File, format)
+	if err != nil {
+		p.errorf(node, "reading data: %v", err)
+		return nil
+	}
+	if len(rows) == 0 {
+		p.errorf(node, "data file %s has no rows", ds.File)
+		return nil
+	}
+	ds.Rows = rows
+
+	return ds
+}
+
+func readData(path, format string) ([]map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	if format == "jsonl" {
+		return readJSONL(path, f)
+	}
+	return readCSV(path, f)
+}
+
+// readCSV reads a csv file, using the first row as the column names
+func readCSV(path string, r io.Reader) ([]map[string]string, error) {
+	cr := csv.NewReader(r)
+	header, err := cr.Read()
+	if errors.Is(err, io.EOF) {
+		// an empty file has no rows, the same as one with only a header
+		return []map[string]string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rows := []map[string]string{}
+	for {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			return rows, nil
+		}
+		if err != nil {
+			// csv errors already say which line they're on
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+
+		row := make(map[string]string, len(header))
+		for i, col := range header {
+			if i < len(record) {
+				row[col] = record[i]
+			}
+		}
+		rows = append(rows, row)
+	}
+}
+
+// readJSONL reads a file with one json object per line. Blank lines are skipped
+func readJSONL(path string, r io.Reader) ([]map[string]string, error) {
+	rows := []map[string]string{}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<20)
+
+	line := 0
+	for scanner.Scan() {
+		line++
+		b := bytes.TrimSpace(scanner.Bytes())
+		if len(b) == 0 {
+			continue
+		}
+
+		dec := json.NewDecoder(bytes.NewReader(b))
+		dec.UseNumber()
+		var obj map[string]any
+		if err := dec.Decode(&obj); err != nil {
+			return nil, ParseError{File: path, Line: line, Msg: fmt.Sprintf("expected a json object: %v", err)}
+		}
+
+		row := make(map[string]string, len(obj))
+		for k, v := range obj {
+			row[k] = models.Stringify(v)
+		}
+		rows = append(rows, row)
+	}
+
+	return rows, scanner.Err()
+}
@@ -0,0 +1,83 @@
+package loader
+
+import (
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestReadData(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  string
+		file    string
+		want    []map[string]string
+		wantErr bool
+	}{
+		{
+			name:   "csv",
+			format: "csv",
+			file:   "id,title\n1,Gardens of the Moon\n2,Deadhouse Gates\n",
+			want:   []map[string]string{{"id": "1", "title": "Gardens of the Moon"}, {"id": "2", "title": "Deadhouse Gates"}},
+		},
+		{
+			name:   "csv with only a header",
+			format: "csv",
+			file:   "id,title\n",
+			want:   []map[string]string{},
+		},
+		{
+			name:   "empty csv",
+			format: "csv",
+			file:   "",
+			want:   []map[string]string{},
+		},
+		{
+			name:    "csv with a bad quote",
+			format:  "csv",
+			file:    "id,title\n1,\"Memories of Ice\n",
+			wantErr: true,
+		},
+		{
+			name:   "jsonl",
+			format: "jsonl",
+			file:   "{\"id\": 1, \"title\": \"House of Chains\"}\n\n{\"id\": 2}\n",
+			want:   []map[string]string{{"id": "1", "title": "House of Chains"}, {"id": "2"}},
+		},
+		{
+			name:    "jsonl that isn't an object",
+			format:  "jsonl",
+			file:    "[1, 2]\n",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "data."+tt.format)
+			if err := os.WriteFile(path, []byte(tt.file), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			got, err := readData(path, tt.format)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("readData() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				if !strings.Contains(err.Error(), path) {
+					t.Errorf("readData() error = %v, want it to name the file", err)
+				}
+				return
+			}
+			// an empty file has no rows, not a nil slice of them
+			if got == nil {
+				t.Fatal("readData() = nil, want an empty slice")
+			}
+			if !slices.EqualFunc(got, tt.want, maps.Equal) {
+				t.Errorf("readData() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,20 @@
+package loader
+
+import (
+	"errors"
+	"fmt"
+)
+
+var ErrNoCollections = errors.New("no collections defined")
+
+// ParseError describes a problem with a collection file, and where in the
+// file it was found
+type ParseError struct {
+	File string
+	Line int
+	Msg  string
+}
+
+func (e ParseError) Error() string {
+	return fmt.Sprintf("%s:%d: %s", e.File, e.Line, e.Msg)
+}
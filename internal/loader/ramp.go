@@ -0,0 +1,99 @@
+package loader
+
+import (
+	"strconv"
+
+	"github.com/jonny-burkholder/swarm/internal/models"
+	"gopkg.in/yaml.v3"
+)
+
+// ramp parses the stages of a ramp. Shape and steps apply to every stage
+// that doesn't set its own:
+//
+//	ramp:
+//	  shape: linear   # or constant, step, exponential, logarithmic
+//	  steps: 5        # for step
+//	  stages:
+//	    - target: 50  # workers
+//	      duration: 1m
+//	    - target: 50
+//	      duration: 5m
+//	      shape: constant
+//	    - target: 0
+//	      duration: 30s
+func (p *parser) ramp(node *yaml.Node) []models.Stage {
+	shape, steps := models.RampLinear, 0
+	var stageNodes []*yaml.Node
+
+	for _, kv := range p.pairs(node, "ramp") {
+		key, val := kv[0], kv[1]
+		switch key.Value {
+		case "shape":
+			shape = p.rampShape(val)
+		case "steps":
+			steps = p.steps(val)
+		case "stages":
+			stageNodes = p.items(val, "stages")
+		default:
+			p.errorf(key, "unknown ramp key '%s'", key.Value)
+		}
+	}
+
+	if len(stageNodes) == 0 {
+		p.errorf(node, "ramp has no stages")
+		return nil
+	}
+
+	stages := make([]models.Stage, 0, len(stageNodes))
+	for _, item := range stageNodes {
+		stage := models.Stage{Shape: shape, Steps: steps}
+		hasTarget, hasDuration := false, false
+		for _, kv := range p.pairs(item, "stage") {
+			key, val := kv[0], kv[1]
+			switch key.Value {
+			case "target":
+				hasTarget = true
+				t, err := strconv.ParseFloat(p.str(val, key.Value), 64)
+				if err != nil || t < 0 {
+					p.errorf(val, "target must be a number that isn't negative")
+				}
+				stage.Target = t
+			case "duration":
+				hasDuration = true
+				if stage.Duration = p.duration(val, key.Value); stage.Duration < 0 {
+					p.errorf(val, "duration can't be negative")
+				}
+			case "shape":
+				stage.Shape = p.rampShape(val)
+			case "steps":
+				stage.Steps = p.steps(val)
+			default:
+				p.errorf(key, "unknown stage key '%s'", key.Value)
+			}
+		}
+		if !hasTarget || !hasDuration {
+			p.errorf(item, "stage needs a target and a duration")
+		}
+		stages = append(stages, stage)
+	}
+
+	return stages
+}
+
+func (p *parser) rampShape(node *yaml.Node) string {
+	shape := p.str(node, "shape")
+	switch shape {
+	case models.RampConstant, models.RampLinear, models.RampStep, models.RampExponential, models.RampLogarithmic:
+	default:
+		p.errorf(node, "unknown shape '%s', must be one of: constant, linear, step, exponential, logarithmic", shape)
+	}
+	return shape
+}
+
+func (p *parser) steps(node *yaml.Node) int {
+	steps := p.int(node, "steps")
+	if steps < 0 {
+		p.errorf(node, "steps can't be negative")
+	}
+	return steps
+}
@@ -0,0 +1,58 @@
+package logger
+
+import "sync"
+
+// Held is a logger that holds on to everything logged until it's released, for while
+// something else is drawing on the terminal, like the live dashboard
+type Held struct {
+	Logger
+
+	mu       sync.Mutex
+	held     []func()
+	released bool
+}
+
+// Hold starts holding on to what's logged to l
+func Hold(l Logger) *Held {
+	return &Held{Logger: l}
+}
+
+func (h *Held) Debug(msg string, args ...any) {
+	h.hold(func() { h.Logger.Debug(msg, args...) })
+}
+
+func (h *Held) Info(msg string, args ...any) {
+	h.hold(func() { h.Logger.Info(msg, args...) })
+}
+
+func (h *Held) Warn(msg string, args ...any) {
+	h.hold(func() { h.Logger.Warn(msg, args...) })
+}
+
+func (h *Held) Error(msg string, args ...any) {
+	h.hold(func() { h.Logger.Error(msg, args...) })
+}
+
+func (h *Held) hold(log func()) {
+	h.mu.Lock()
+	if !h.released {
+		h.held = append(h.held, log)
+		h.mu.Unlock()
+		return
+	}
+	h.mu.Unlock()
+	log()
+}
+
+// Release logs everything that was held, in order. Anything logged after
+// that goes straight through
+func (h *Held) Release() {
+	h.mu.Lock()
+	held := h.held
+	h.held, h.released = nil, true
+	h.mu.Unlock()
+
+	for _, log := range held {
+		log()
+	}
+}
@@ -0,0 +1,66 @@
+package logger
+
+import (
+	"slices"
+	"testing"
+)
+
+// recorder is a logger that keeps every message it's given
+type recorder struct {
+	msgs []string
+}
+
+func (r *recorder) Debug(msg string, _ ...any) { r.msgs = append(r.msgs, "debug "+msg) }
+func (r *recorder) Info(msg string, _ ...any)  { r.msgs = append(r.msgs, "info "+msg) }
+func (r *recorder) Warn(msg string, _ ...any)  { r.msgs = append(r.msgs, "warn "+msg) }
+func (r *recorder) Error(msg string, _ ...any) { r.msgs = append(r.msgs, "error "+msg) }
+func (r *recorder) SetLevel(LogLevel)          {}
+
+func TestHeld(t *testing.T) {
+	tests := []struct {
+		name        string
+		before      func(l Logger) // logged while held
+		after       func(l Logger) // logged once released
+		wantRelease []string
+	}{
+		{
+			name:        "nothing logged",
+			before:      func(Logger) {},
+			after:       func(Logger) {},
+			wantRelease: nil,
+		},
+		{
+			name: "held until released, in order",
+			before: func(l Logger) {
+				l.Info("starting")
+				l.Warn("slow")
+				l.Error("failed")
+				l.Debug("detail")
+			},
+			after:       func(Logger) {},
+			wantRelease: []string{"info starting", "warn slow", "error failed", "debug detail"},
+		},
+		{
+			name:        "straight through once released",
+			before:      func(l Logger) { l.Info("starting") },
+			after:       func(l Logger) { l.Info("finished") },
+			wantRelease: []string{"info starting", "info finished"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &recorder{}
+			h := Hold(r)
+			tt.before(h)
+			if len(r.msgs) != 0 {
+				t.Fatalf("logged %q while held, want nothing", r.msgs)
+			}
+			h.Release()
+			tt.after(h)
+			if !slices.Equal(r.msgs, tt.wantRelease) {
+				t.Errorf("logged %q, want %q", r.msgs, tt.wantRelease)
+			}
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package logger
 
 import (
+	"fmt"
 	"log/slog"
 	"os"
 )
@@ -26,6 +27,24 @@ type Logger interface {
 type defaultLogger struct {
 	lvl     LogLevel
 	handler slog.Handler
+	log     *slog.Logger
+}
+
+// ParseLevel converts a level name, as passed on the command line, to a LogLevel
+func ParseLevel(level string) (LogLevel, error) {
+	switch level {
+	case "debug":
+		return LevelDebug, nil
+	case "info":
+		return LevelInfo, nil
+	case "warn":
+		return LevelWarn, nil
+	case "error":
+		return LevelError, nil
+	case "silent":
+		return LevelSilent, nil
+	}
+	return LevelInfo, fmt.Errorf("invalid log level '%s', must be one of: debug, info, warn, error, silent", level)
 }
 
 func DefaultLogger(lvl ...LogLevel) Logger {
@@ -35,17 +54,19 @@ func DefaultLogger(lvl ...LogLevel) Logger {
 		logLevel = lvl[0]
 	}
 
-	slogLevel := lvlToSloglvl(lvl[0])
+	slogLevel := lvlToSloglvl(logLevel)
 
 	opts := &slog.HandlerOptions{
 		Level: slogLevel,
 	}
 
-	handler := slog.NewJSONHandler(os.Stdout, opts)
+	// logs go to stderr so they don't get mixed up with reports written to stdout
+	handler := slog.NewJSONHandler(os.Stderr, opts)
 
 	return &defaultLogger{
 		lvl:     logLevel,
 		handler: handler,
+		log:     slog.New(handler),
 	}
 
 }
@@ -67,25 +88,25 @@ func lvlToSloglvl(lvl LogLevel) slog.Level {
 
 func (l *defaultLogger) Debug(msg string, args ...any) {
 	if l.lvl <= LevelDebug {
-		slog.Debug(msg, args...)
+		l.log.Debug(msg, args...)
 	}
 }
 
 func (l *defaultLogger) Info(msg string, args ...any) {
 	if l.lvl <= LevelInfo {
-		slog.Info(msg, args...)
+		l.log.Info(msg, args...)
 	}
 }
 
 func (l *defaultLogger) Warn(msg string, args ...any) {
 	if l.lvl <= LevelWarn {
-		slog.Warn(msg, args...)
+		l.log.Warn(msg, args...)
 	}
 }
 
 func (l *defaultLogger) Error(msg string, args ...any) {
 	if l.lvl <= LevelError {
-		slog.Error(msg, args...)
+		l.log.Error(msg, args...)
 	}
 }
 
@@ -97,6 +118,7 @@ func (l *defaultLogger) SetLevel(level LogLevel) {
 		Level: slogLevel,
 	}
 
-	l.handler = slog.NewJSONHandler(os.Stdout, opts)
-	slog.SetDefault(slog.New(l.handler))
+	l.handler = slog.NewJSONHandler(os.Stderr, opts)
+	l.log = slog.New(l.handler)
+	slog.SetDefault(l.log)
 }
@@ -0,0 +1,120 @@
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// pushTimeout is how long an http push gets before it's given up on
+const pushTimeout = 10 * time.Second
+
+// Influx writes metrics to an influxdb write endpoint in line protocol, like
+// http://localhost:8086/api/v2/write?org=myorg&bucket=swarm, or /write?db=swarm for 1.x.
+// Counters are from the start, and latencies are in milliseconds
+type Influx struct {
+	URL    string
+	Token  string // sent as "Authorization: Token ...", if there is one
+	Client *http.Client
+}
+
+func NewInflux(url, token string) *Influx {
+	return &Influx{URL: url, Token: token, Client: &http.Client{Timeout: pushTimeout}}
+}
+
+func (i *Influx) Export(batch Batch) error {
+	body := bytes.Buffer{}
+	ts := batch.Time.UnixNano()
+	for n, series := range batch.Series {
+		fields := []string{
+			fmt.Sprintf("requests=%di", series.Requests),
+			fmt.Sprintf("response_bytes=%di", series.Bytes),
+		}
+		for _, class := range Classes() {
+			fields = append(fields, fmt.Sprintf("errors_%s=%di", class, series.Errors[class]))
+		}
+		if batch.Deltas[n].Count > 0 {
+			latency := batch.Latency[n]
+			fields = append(fields,
+				"p50_ms="+milliseconds(latency.P50),
+				"p95_ms="+milliseconds(latency.P95),
+				"p99_ms="+milliseconds(latency.P99),
+				"max_ms="+milliseconds(latency.Max),
+			)
+		}
+		fmt.Fprintf(&body, "swarm%s %s %d\n", influxTags("test_id", batch.TestID, "collection", series.Collection, "request", series.Request), strings.Join(fields, ","), ts)
+	}
+
+	gauges := []string{}
+	if batch.Workers >= 0 {
+		gauges = append(gauges, fmt.Sprintf("workers_busy=%di", batch.Workers))
+	}
+	if batch.InFlight >= 0 {
+		gauges = append(gauges, fmt.Sprintf("requests_in_flight=%di", batch.InFlight))
+	}
+	if len(gauges) > 0 {
+		fmt.Fprintf(&body, "swarm_load%s %s %d\n", influxTags("test_id", batch.TestID), strings.Join(gauges, ","), ts)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, i.URL, &body)
+	if err != nil {
+		return fmt.Errorf("pushing to influxdb: %w", err)
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if i.Token != "" {
+		req.Header.Set("Authorization", "Token "+i.Token)
+	}
+
+	return post(i.Client, req, "influxdb")
+}
+
+// influxTags formats name value pairs as line protocol tags, like ,collection=library,request=get.
+// Empty values are left out, since influxdb doesn't allow them
+func influxTags(pairs ...string) string {
+	b := strings.Builder{}
+	for i := 0; i+1 < len(pairs); i += 2 {
+		if pairs[i+1] == "" {
+			continue
+		}
+		b.WriteString("," + pairs[i] + "=" + influxEscape(pairs[i+1]))
+	}
+	return b.String()
+}
+
+// influxEscape escapes commas, equals signs and spaces in a tag value. Newlines
+// can't be escaped, so they become spaces
+func influxEscape(s string) string {
+	b := strings.Builder{}
+	for _, r := range s {
+		switch r {
+		case ',', '=', ' ':
+			b.WriteRune('\\')
+			b.WriteRune(r)
+		case '\n':
+			b.WriteString(`\ `)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// post sends req, and makes sure it worked
+func post(client *http.Client, req *http.Request, to string) error {
+	res, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("pushing to %s: %w", to, err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+		return fmt.Errorf("pushing to %s: %s: %s", to, res.Status, strings.TrimSpace(string(msg)))
+	}
+	io.Copy(io.Discard, res.Body)
+
+	return nil
+}
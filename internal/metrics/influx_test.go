@@ -0,0 +1,100 @@
+package metrics
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestInfluxExport(t *testing.T) {
+	series := `swarm,test_id=t1,collection=library,request=GET\ books requests=3i,response_bytes=120i,errors_request=0i,errors_4xx=0i,errors_5xx=1i,errors_assertion=0i,p50_ms=10,p95_ms=20,p99_ms=20,max_ms=20 100000000000` + "\n"
+
+	tests := []struct {
+		name     string
+		batch    Batch
+		token    string
+		status   int
+		wantBody string
+		wantAuth string
+		wantErr  string
+	}{
+		{
+			name:     "series and gauges",
+			batch:    testBatch(4, 2),
+			status:   http.StatusNoContent,
+			wantBody: series + "swarm_load,test_id=t1 workers_busy=4i,requests_in_flight=2i 100000000000\n",
+		},
+		{
+			name:     "gauges that aren't known are left out",
+			batch:    testBatch(-1, -1),
+			status:   http.StatusNoContent,
+			wantBody: series,
+		},
+		{
+			name:     "token",
+			batch:    testBatch(-1, -1),
+			token:    "secret",
+			status:   http.StatusNoContent,
+			wantBody: series,
+			wantAuth: "Token secret",
+		},
+		{
+			name:     "rejected",
+			batch:    testBatch(-1, -1),
+			status:   http.StatusUnauthorized,
+			wantBody: series,
+			wantErr:  "pushing to influxdb: 401 Unauthorized: nope",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body, auth string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				b, _ := io.ReadAll(r.Body)
+				body, auth = string(b), r.Header.Get("Authorization")
+				w.WriteHeader(tt.status)
+				io.WriteString(w, "nope")
+			}))
+			defer srv.Close()
+
+			err := NewInflux(srv.URL, tt.token).Export(tt.batch)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("Export() error = %v, want %q", err, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Errorf("Export() error = %v", err)
+			}
+			if body != tt.wantBody {
+				t.Errorf("body =\n%s\nwant\n%s", body, tt.wantBody)
+			}
+			if auth != tt.wantAuth {
+				t.Errorf("Authorization = %q, want %q", auth, tt.wantAuth)
+			}
+		})
+	}
+}
+
+func TestInfluxTags(t *testing.T) {
+	tests := []struct {
+		name  string
+		pairs []string
+		want  string
+	}{
+		{name: "plain", pairs: []string{"collection", "library", "request", "list"}, want: ",collection=library,request=list"},
+		{name: "escaped", pairs: []string{"request", "GET /books?a=1,b=2"}, want: `,request=GET\ /books?a\=1\,b\=2`},
+		{name: "newline", pairs: []string{"request", "a\nb"}, want: `,request=a\ b`},
+		{name: "empty values are left out", pairs: []string{"test_id", "", "collection", "library"}, want: ",collection=library"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := influxTags(tt.pairs...); got != tt.want {
+				t.Errorf("influxTags(%q) = %q, want %q", tt.pairs, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,173 @@
+/*
+metrics keeps live counters and latency histograms for a running benchmark, for each collection
+and request, so that they can be watched from somewhere else while it's still going, like scraped
+by prometheus and put on the same dashboard as the api being tested
+*/
+package metrics
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/jonny-burkholder/swarm/internal/models"
+)
+
+// Buckets are the upper bounds of the latency histograms, in seconds
+func Buckets() []float64 {
+	return []float64{0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+}
+
+// Classes errors are counted by. A request can be in more than one, like a 5xx
+// that also failed an assertion
+const (
+	ClassRequest   = "request" // couldn't be sent, or got no response
+	Class4xx       = "4xx"
+	Class5xx       = "5xx"
+	ClassAssertion = "assertion" // a failed assertion or schema violation
+)
+
+// Classes is every error class, in the order they're shown
+func Classes() []string {
+	return []string{ClassRequest, Class4xx, Class5xx, ClassAssertion}
+}
+
+// Series is everything counted for one request in a collection
+type Series struct {
+	Collection string
+	Request    string
+	Requests   uint64
+	Errors     map[string]uint64 // by class
+	Bytes      int64
+
+	// latencies of the requests that got a response. Buckets are cumulative,
+	// so Buckets[i] is how many took Buckets()[i] seconds or less
+	Count   uint64
+	Sum     time.Duration
+	Buckets []uint64
+
+	key key
+}
+
+// key tells series apart by where the request is in its collection, as names
+// don't have to be unique
+type key struct {
+	collection string
+	request    int
+}
+
+// Metrics is a sink that counts every result as it comes in
+type Metrics struct {
+	// gauges, read whenever the metrics are. Either can be nil
+	Workers  func() int // workers partway through a run
+	InFlight func() int // requests waiting on a response
+
+	mu      sync.Mutex
+	series  []*Series // in the order they were first seen
+	index   map[key]*Series
+	buckets []float64
+}
+
+func New() *Metrics {
+	return &Metrics{index: map[key]*Series{}, buckets: Buckets()}
+}
+
+func (m *Metrics) Write(collection *models.Collection, run models.Run) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i, result := range run.Results {
+		m.add(collection, i, result)
+	}
+
+	return nil
+}
+
+func (m *Metrics) add(collection *models.Collection, i int, result models.Result) {
+	k := key{collection.Name, i}
+	s, ok := m.index[k]
+	if !ok {
+		s = &Series{
+			Collection: collection.Name,
+			Request:    requestName(collection, i, result.Request.Name),
+			Errors:     map[string]uint64{},
+			Buckets:    make([]uint64, len(m.buckets)),
+			key:        k,
+		}
+		m.index[k] = s
+		m.series = append(m.series, s)
+	}
+
+	s.Requests++
+	if result.Error != nil {
+		s.Errors[ClassRequest]++
+		return
+	}
+	switch {
+	case result.StatusCode >= 500:
+		s.Errors[Class5xx]++
+	case result.StatusCode >= 400:
+		s.Errors[Class4xx]++
+	}
+	if !result.Passed() {
+		s.Errors[ClassAssertion]++
+	}
+
+	s.Bytes += result.BytesReceived
+	s.Count++
+	s.Sum += result.Duration
+	seconds := result.Duration.Seconds()
+	for i, bound := range m.buckets {
+		if seconds <= bound {
+			s.Buckets[i]++
+		}
+	}
+}
+
+// requestName is what the i'th request's series is called. A request that shares its
+// name with one before it gets a number, the same way compare tells them apart
+func requestName(collection *models.Collection, i int, name string) string {
+	n := 1
+	for _, r := range collection.Requests[:min(i, len(collection.Requests))] {
+		if r.Name == name {
+			n++
+		}
+	}
+	if n > 1 {
+		return fmt.Sprintf("%s (%d)", name, n)
+	}
+	return name
+}
+
+func (m *Metrics) Close() error {
+	return nil
+}
+
+// Snapshot is a copy of every series so far, in the order they were first seen
+func (m *Metrics) Snapshot() []Series {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	out := make([]Series, len(m.series))
+	for i, s := range m.series {
+		out[i] = *s
+		out[i].Errors = maps.Clone(s.Errors)
+		out[i].Buckets = slices.Clone(s.Buckets)
+	}
+	return out
+}
+
+// Gauges are the workers partway through a run and then the requests in flight right now,
+// each -1 if it isn't known
+func (m *Metrics) Gauges() (int, int) {
+	workers, inFlight := -1, -1
+	if m.Workers != nil {
+		workers = m.Workers()
+	}
+	if m.InFlight != nil {
+		inFlight = m.InFlight()
+	}
+	return workers, inFlight
+}
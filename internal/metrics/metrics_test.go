@@ -0,0 +1,127 @@
+package metrics
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/jonny-burkholder/swarm/internal/models"
+)
+
+func TestMetricsSeries(t *testing.T) {
+	tests := []struct {
+		name     string
+		requests []string
+		want     []string
+	}{
+		{
+			name:     "unique names",
+			requests: []string{"login", "list"},
+			want:     []string{"login", "list"},
+		},
+		{
+			name:     "names used more than once",
+			requests: []string{"GET /books", "POST /books", "GET /books", "GET /books"},
+			want:     []string{"GET /books", "POST /books", "GET /books (2)", "GET /books (3)"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			collection := &models.Collection{Name: "test"}
+			run := models.Run{}
+			for _, name := range tt.requests {
+				request := models.Request{Name: name}
+				collection.Requests = append(collection.Requests, request)
+				run.Results = append(run.Results, models.Result{Request: request, StatusCode: 200})
+			}
+
+			m := New()
+			m.Write(collection, run)
+			m.Write(collection, run)
+
+			var got []string
+			for _, s := range m.Snapshot() {
+				got = append(got, s.Request)
+				if s.Requests != 2 {
+					t.Errorf("%s: Requests = %d, want 2", s.Request, s.Requests)
+				}
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("series = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMetricsAdd(t *testing.T) {
+	tests := []struct {
+		name       string
+		result     models.Result
+		wantErrors map[string]uint64
+		wantCount  uint64
+		wantBucket int // the first bucket the latency lands in, or -1 for none
+	}{
+		{
+			name:       "ok",
+			result:     models.Result{StatusCode: 200, Duration: 3 * time.Millisecond},
+			wantErrors: map[string]uint64{},
+			wantCount:  1,
+			wantBucket: 2,
+		},
+		{
+			name:       "couldn't be sent",
+			result:     models.Result{Error: errors.New("refused")},
+			wantErrors: map[string]uint64{ClassRequest: 1},
+			wantBucket: -1,
+		},
+		{
+			name:       "4xx",
+			result:     models.Result{StatusCode: 404, Duration: time.Second},
+			wantErrors: map[string]uint64{Class4xx: 1},
+			wantCount:  1,
+			wantBucket: 9,
+		},
+		{
+			name: "5xx that failed an assertion",
+			result: models.Result{
+				StatusCode: 503,
+				Duration:   time.Minute,
+				Assertions: []models.Assertion{{Result: false}},
+			},
+			wantErrors: map[string]uint64{Class5xx: 1, ClassAssertion: 1},
+			wantCount:  1,
+			wantBucket: -1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := New()
+			m.Write(&models.Collection{Name: "test", Requests: []models.Request{{}}}, models.Run{Results: []models.Result{tt.result}})
+
+			s := m.Snapshot()[0]
+			if len(s.Errors) != len(tt.wantErrors) {
+				t.Errorf("Errors = %v, want %v", s.Errors, tt.wantErrors)
+			}
+			for class, n := range tt.wantErrors {
+				if s.Errors[class] != n {
+					t.Errorf("Errors[%s] = %d, want %d", class, s.Errors[class], n)
+				}
+			}
+			if s.Count != tt.wantCount {
+				t.Errorf("Count = %d, want %d", s.Count, tt.wantCount)
+			}
+			for i, n := range s.Buckets {
+				want := uint64(0)
+				if tt.wantBucket >= 0 && i >= tt.wantBucket {
+					want = 1
+				}
+				if n != want {
+					t.Errorf("Buckets[%d] = %d, want %d", i, n, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,187 @@
+package metrics
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/jonny-burkholder/swarm/internal/version"
+)
+
+// OTLP sends metrics to an opentelemetry collector, as otlp over http with json bodies, like
+// to http://localhost:4318/v1/metrics. Counters and histograms are cumulative from the start
+type OTLP struct {
+	URL    string
+	Client *http.Client
+}
+
+func NewOTLP(url string) *OTLP {
+	return &OTLP{URL: url, Client: &http.Client{Timeout: pushTimeout}}
+}
+
+// the parts of otlp's json encoding we use. 64 bit integers are strings in it
+
+type otlpRequest struct {
+	ResourceMetrics []otlpResourceMetrics `json:"resourceMetrics"`
+}
+
+type otlpResourceMetrics struct {
+	Resource     otlpResource       `json:"resource"`
+	ScopeMetrics []otlpScopeMetrics `json:"scopeMetrics"`
+}
+
+type otlpResource struct {
+	Attributes []otlpAttribute `json:"attributes"`
+}
+
+type otlpScopeMetrics struct {
+	Scope   otlpScope    `json:"scope"`
+	Metrics []otlpMetric `json:"metrics"`
+}
+
+type otlpScope struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+}
+
+type otlpMetric struct {
+	Name      string         `json:"name"`
+	Unit      string         `json:"unit,omitempty"`
+	Sum       *otlpSum       `json:"sum,omitempty"`
+	Gauge     *otlpGauge     `json:"gauge,omitempty"`
+	Histogram *otlpHistogram `json:"histogram,omitempty"`
+}
+
+// otlpCumulative is otlp's AGGREGATION_TEMPORALITY_CUMULATIVE
+const otlpCumulative = 2
+
+type otlpSum struct {
+	AggregationTemporality int             `json:"aggregationTemporality"`
+	IsMonotonic            bool            `json:"isMonotonic"`
+	DataPoints             []otlpDataPoint `json:"dataPoints"`
+}
+
+type otlpGauge struct {
+	DataPoints []otlpDataPoint `json:"dataPoints"`
+}
+
+type otlpHistogram struct {
+	AggregationTemporality int                      `json:"aggregationTemporality"`
+	DataPoints             []otlpHistogramDataPoint `json:"dataPoints"`
+}
+
+type otlpDataPoint struct {
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano,omitempty"`
+	TimeUnixNano      string          `json:"timeUnixNano"`
+	AsInt             string          `json:"asInt"`
+}
+
+type otlpHistogramDataPoint struct {
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	TimeUnixNano      string          `json:"timeUnixNano"`
+	Count             string          `json:"count"`
+	Sum               float64         `json:"sum"`
+	BucketCounts      []string        `json:"bucketCounts"`
+	ExplicitBounds    []float64       `json:"explicitBounds"`
+}
+
+type otlpAttribute struct {
+	Key   string `json:"key"`
+	Value struct {
+		StringValue string `json:"stringValue"`
+	} `json:"value"`
+}
+
+func (o *OTLP) Export(batch Batch) error {
+	start := strconv.FormatInt(batch.Start.UnixNano(), 10)
+	now := strconv.FormatInt(batch.Time.UnixNano(), 10)
+
+	requests := &otlpSum{AggregationTemporality: otlpCumulative, IsMonotonic: true}
+	errs := &otlpSum{AggregationTemporality: otlpCumulative, IsMonotonic: true}
+	bytesReceived := &otlpSum{AggregationTemporality: otlpCumulative, IsMonotonic: true}
+	duration := &otlpHistogram{AggregationTemporality: otlpCumulative}
+	for _, s := range batch.Series {
+		attrs := otlpAttributes("test_id", batch.TestID, "collection", s.Collection, "request", s.Request)
+		point := func(n uint64, attrs []otlpAttribute) otlpDataPoint {
+			return otlpDataPoint{Attributes: attrs, StartTimeUnixNano: start, TimeUnixNano: now, AsInt: strconv.FormatUint(n, 10)}
+		}
+
+		requests.DataPoints = append(requests.DataPoints, point(s.Requests, attrs))
+		bytesReceived.DataPoints = append(bytesReceived.DataPoints, point(uint64(s.Bytes), attrs))
+		for _, class := range Classes() {
+			errs.DataPoints = append(errs.DataPoints, point(s.Errors[class], otlpAttributes("test_id", batch.TestID, "collection", s.Collection, "request", s.Request, "class", class)))
+		}
+
+		// otlp wants how many are in each bucket, with one more on the end for the rest
+		counts := make([]string, len(s.Buckets)+1)
+		below := uint64(0)
+		for i, n := range s.Buckets {
+			counts[i] = strconv.FormatUint(n-below, 10)
+			below = n
+		}
+		counts[len(s.Buckets)] = strconv.FormatUint(s.Count-below, 10)
+		duration.DataPoints = append(duration.DataPoints, otlpHistogramDataPoint{
+			Attributes:        attrs,
+			StartTimeUnixNano: start,
+			TimeUnixNano:      now,
+			Count:             strconv.FormatUint(s.Count, 10),
+			Sum:               s.Sum.Seconds(),
+			BucketCounts:      counts,
+			ExplicitBounds:    Buckets(),
+		})
+	}
+
+	metrics := []otlpMetric{
+		{Name: "swarm.requests", Unit: "{request}", Sum: requests},
+		{Name: "swarm.errors", Unit: "{request}", Sum: errs},
+		{Name: "swarm.response.bytes", Unit: "By", Sum: bytesReceived},
+		{Name: "swarm.request.duration", Unit: "s", Histogram: duration},
+	}
+	gauge := func(name string, n int) otlpMetric {
+		return otlpMetric{Name: name, Gauge: &otlpGauge{DataPoints: []otlpDataPoint{{
+			Attributes:   otlpAttributes("test_id", batch.TestID),
+			TimeUnixNano: now,
+			AsInt:        strconv.Itoa(n),
+		}}}}
+	}
+	if batch.Workers >= 0 {
+		metrics = append(metrics, gauge("swarm.workers.busy", batch.Workers))
+	}
+	if batch.InFlight >= 0 {
+		metrics = append(metrics, gauge("swarm.requests.in_flight", batch.InFlight))
+	}
+
+	body, err := json.Marshal(otlpRequest{ResourceMetrics: []otlpResourceMetrics{{
+		Resource:     otlpResource{Attributes: otlpAttributes("service.name", "swarm")},
+		ScopeMetrics: []otlpScopeMetrics{{Scope: otlpScope{Name: "swarm", Version: version.Version}, Metrics: metrics}},
+	}}})
+	if err != nil {
+		return fmt.Errorf("pushing to otlp: %w", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, o.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("pushing to otlp: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	return post(o.Client, req, "otlp")
+}
+
+// otlpAttributes makes string attributes from name value pairs, leaving out empty values
+func otlpAttributes(pairs ...string) []otlpAttribute {
+	attrs := []otlpAttribute{}
+	for i := 0; i+1 < len(pairs); i += 2 {
+		if pairs[i+1] == "" {
+			continue
+		}
+		attr := otlpAttribute{Key: pairs[i]}
+		attr.Value.StringValue = pairs[i+1]
+		attrs = append(attrs, attr)
+	}
+	return attrs
+}
@@ -0,0 +1,110 @@
+package metrics
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+)
+
+func TestOTLPExport(t *testing.T) {
+	// 10ms and 20ms land in the 0.01 and 0.025 buckets
+	wantCounts := make([]string, len(Buckets())+1)
+	for i := range wantCounts {
+		wantCounts[i] = "0"
+	}
+	wantCounts[slices.Index(Buckets(), 0.01)] = "1"
+	wantCounts[slices.Index(Buckets(), 0.025)] = "1"
+
+	tests := []struct {
+		name        string
+		batch       Batch
+		wantMetrics []string
+	}{
+		{
+			name:        "series and gauges",
+			batch:       testBatch(4, 2),
+			wantMetrics: []string{"swarm.requests", "swarm.errors", "swarm.response.bytes", "swarm.request.duration", "swarm.workers.busy", "swarm.requests.in_flight"},
+		},
+		{
+			name:        "gauges that aren't known are left out",
+			batch:       testBatch(-1, -1),
+			wantMetrics: []string{"swarm.requests", "swarm.errors", "swarm.response.bytes", "swarm.request.duration"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got otlpRequest
+			var contentType string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				contentType = r.Header.Get("Content-Type")
+				if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+					t.Errorf("decoding body: %v", err)
+				}
+			}))
+			defer srv.Close()
+
+			if err := NewOTLP(srv.URL).Export(tt.batch); err != nil {
+				t.Fatalf("Export() error = %v", err)
+			}
+			if contentType != "application/json" {
+				t.Errorf("Content-Type = %q, want application/json", contentType)
+			}
+			if len(got.ResourceMetrics) != 1 || len(got.ResourceMetrics[0].ScopeMetrics) != 1 {
+				t.Fatalf("got %+v, want one resource with one scope", got)
+			}
+
+			metrics := map[string]otlpMetric{}
+			var names []string
+			for _, m := range got.ResourceMetrics[0].ScopeMetrics[0].Metrics {
+				metrics[m.Name] = m
+				names = append(names, m.Name)
+			}
+			if !slices.Equal(names, tt.wantMetrics) {
+				t.Errorf("metrics = %q, want %q", names, tt.wantMetrics)
+			}
+
+			requests := metrics["swarm.requests"].Sum.DataPoints[0]
+			if requests.AsInt != "3" || requests.StartTimeUnixNano != "90000000000" || requests.TimeUnixNano != "100000000000" {
+				t.Errorf("swarm.requests = %+v, want 3 from 90s to 100s", requests)
+			}
+			wantAttrs := []string{"test_id=t1", "collection=library", "request=GET books"}
+			if attrs := attributes(requests.Attributes); !slices.Equal(attrs, wantAttrs) {
+				t.Errorf("swarm.requests attributes = %q, want %q", attrs, wantAttrs)
+			}
+
+			errs := metrics["swarm.errors"].Sum.DataPoints
+			if len(errs) != len(Classes()) {
+				t.Fatalf("%d swarm.errors points, want one for each of %d classes", len(errs), len(Classes()))
+			}
+			for i, class := range Classes() {
+				want := "0"
+				if class == Class5xx {
+					want = "1"
+				}
+				attrs := attributes(errs[i].Attributes)
+				if errs[i].AsInt != want || attrs[len(attrs)-1] != "class="+class {
+					t.Errorf("swarm.errors[%d] = %s %q, want %s for class %s", i, errs[i].AsInt, attrs, want, class)
+				}
+			}
+
+			duration := metrics["swarm.request.duration"].Histogram.DataPoints[0]
+			if duration.Count != "2" || duration.Sum != 0.03 {
+				t.Errorf("swarm.request.duration count %s sum %v, want 2 and 0.03", duration.Count, duration.Sum)
+			}
+			if !slices.Equal(duration.BucketCounts, wantCounts) {
+				t.Errorf("bucket counts = %q, want %q", duration.BucketCounts, wantCounts)
+			}
+		})
+	}
+}
+
+func attributes(attrs []otlpAttribute) []string {
+	out := []string{}
+	for _, a := range attrs {
+		out = append(out, a.Key+"="+a.Value.StringValue)
+	}
+	return out
+}
@@ -0,0 +1,120 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Serve serves the metrics at /metrics on addr, in the background, until the server is closed.
+// The server's Addr is the address it's really listening on, in case addr's port was 0
+func (m *Metrics) Serve(addr string) (*http.Server, error) {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", m.Handler())
+	srv := &http.Server{
+		Addr:              l.Addr().String(),
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+	go srv.Serve(l)
+
+	return srv, nil
+}
+
+// Handler serves the metrics in prometheus' text format
+func (m *Metrics) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		m.WritePrometheus(w)
+	})
+}
+
+// WritePrometheus writes the metrics in prometheus' text format
+func (m *Metrics) WritePrometheus(w io.Writer) error {
+	series := m.Snapshot()
+	workers, inFlight := m.Gauges()
+	b := bufio.NewWriter(w)
+
+	header := func(name, kind, help string) {
+		fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+	}
+
+	header("swarm_requests_total", "counter", "Requests sent")
+	for _, s := range series {
+		fmt.Fprintf(b, "swarm_requests_total%s %d\n", labels(s), s.Requests)
+	}
+
+	header("swarm_request_errors_total", "counter", "Requests that failed, by class: request (no response), 4xx, 5xx, or assertion")
+	for _, s := range series {
+		for _, class := range Classes() {
+			fmt.Fprintf(b, "swarm_request_errors_total%s %d\n", labels(s, "class", class), s.Errors[class])
+		}
+	}
+
+	header("swarm_response_bytes_total", "counter", "Bytes of response bodies received")
+	for _, s := range series {
+		fmt.Fprintf(b, "swarm_response_bytes_total%s %d\n", labels(s), s.Bytes)
+	}
+
+	header("swarm_request_duration_seconds", "histogram", "How long requests that got a response took")
+	for _, s := range series {
+		for i, bound := range Buckets() {
+			fmt.Fprintf(b, "swarm_request_duration_seconds_bucket%s %d\n", labels(s, "le", formatFloat(bound)), s.Buckets[i])
+		}
+		fmt.Fprintf(b, "swarm_request_duration_seconds_bucket%s %d\n", labels(s, "le", "+Inf"), s.Count)
+		fmt.Fprintf(b, "swarm_request_duration_seconds_sum%s %s\n", labels(s), formatFloat(s.Sum.Seconds()))
+		fmt.Fprintf(b, "swarm_request_duration_seconds_count%s %d\n", labels(s), s.Count)
+	}
+
+	if workers >= 0 {
+		header("swarm_workers_busy", "gauge", "Workers partway through a run")
+		fmt.Fprintf(b, "swarm_workers_busy %d\n", workers)
+	}
+	if inFlight >= 0 {
+		header("swarm_requests_in_flight", "gauge", "Requests sent that are waiting on a response")
+		fmt.Fprintf(b, "swarm_requests_in_flight %d\n", inFlight)
+	}
+
+	return b.Flush()
+}
+
+// labels formats a series' collection and request as labels, plus any extra name value pairs
+func labels(s Series, extra ...string) string {
+	pairs := append([]string{"collection", s.Collection, "request", s.Request}, extra...)
+	parts := make([]string, 0, len(pairs)/2)
+	for i := 0; i+1 < len(pairs); i += 2 {
+		parts = append(parts, fmt.Sprintf(`%s="%s"`, pairs[i], escape(pairs[i+1])))
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+// escape escapes backslashes, quotes and newlines in a label value
+func escape(s string) string {
+	b := strings.Builder{}
+	for _, r := range s {
+		switch r {
+		case '\\', '"':
+			b.WriteRune('\\')
+			b.WriteRune(r)
+		case '\n':
+			b.WriteString(`\n`)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
@@ -0,0 +1,84 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jonny-burkholder/swarm/internal/models"
+)
+
+func TestEscape(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{name: "nothing to escape", in: "GET /books", want: "GET /books"},
+		{name: "quotes", in: `say "hi"`, want: `say \"hi\"`},
+		{name: "backslash", in: `C:\temp`, want: `C:\\temp`},
+		{name: "newline", in: "a\nb", want: `a\nb`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := escape(tt.in); got != tt.want {
+				t.Errorf("escape(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWritePrometheus(t *testing.T) {
+	tests := []struct {
+		name      string
+		workers   func() int
+		wantLines []string
+		wantNot   []string
+	}{
+		{
+			name:    "series and gauges",
+			workers: func() int { return 4 },
+			wantLines: []string{
+				`swarm_requests_total{collection="library",request="GET \"books\""} 1`,
+				`swarm_request_errors_total{collection="library",request="GET \"books\"",class="5xx"} 1`,
+				`swarm_request_duration_seconds_bucket{collection="library",request="GET \"books\"",le="0.005"} 0`,
+				`swarm_request_duration_seconds_bucket{collection="library",request="GET \"books\"",le="0.01"} 1`,
+				`swarm_request_duration_seconds_bucket{collection="library",request="GET \"books\"",le="+Inf"} 1`,
+				`swarm_request_duration_seconds_sum{collection="library",request="GET \"books\""} 0.01`,
+				`swarm_workers_busy 4`,
+			},
+			wantNot: []string{"swarm_requests_in_flight"},
+		},
+		{
+			name:    "gauges that aren't known are left out",
+			wantNot: []string{"swarm_workers_busy", "swarm_requests_in_flight"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := models.Request{Name: `GET "books"`}
+			m := New()
+			m.Workers = tt.workers
+			m.Write(&models.Collection{Name: "library", Requests: []models.Request{request}}, models.Run{Results: []models.Result{
+				{Request: request, StatusCode: 503, Duration: 10 * time.Millisecond},
+			}})
+
+			out := &strings.Builder{}
+			if err := m.WritePrometheus(out); err != nil {
+				t.Fatal(err)
+			}
+			for _, want := range tt.wantLines {
+				if !strings.Contains(out.String(), want+"\n") {
+					t.Errorf("missing line %s in\n%s", want, out)
+				}
+			}
+			for _, not := range tt.wantNot {
+				if strings.Contains(out.String(), not) {
+					t.Errorf("%s shouldn't be there in\n%s", not, out)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,188 @@
+package metrics
+
+import (
+	"io"
+	"sync"
+	"time"
+
+	"github.com/jonny-burkholder/swarm/internal/histogram"
+	"github.com/jonny-burkholder/swarm/internal/models"
+)
+
+// Exporter sends a batch of metrics somewhere
+type Exporter interface {
+	Export(batch Batch) error
+}
+
+// Batch is what's pushed every interval
+type Batch struct {
+	TestID string
+	Start  time.Time // when pushing started, which counters count from
+	Time   time.Time
+
+	Series []Series // every series, counted since Start
+	Deltas []Series // what each series in Series counted since the last batch
+	// percentiles of the requests in each series since the last batch, since
+	// percentiles from the start would barely move on a long benchmark
+	Latency []Latency
+
+	Workers  int // -1 if it isn't known
+	InFlight int // -1 if it isn't known
+}
+
+type Latency struct {
+	P50, P95, P99, Max time.Duration
+}
+
+// Pusher is a sink that counts results like Metrics, and pushes what it has to an
+// exporter every interval, and once more when it's closed
+type Pusher struct {
+	*Metrics
+
+	exporter Exporter
+	testID   string
+	interval time.Duration
+	// OnError is told about batches that couldn't be pushed. They aren't tried again,
+	// but as counters are from the start, the next batch makes up for them
+	OnError func(error)
+
+	mu      sync.Mutex
+	start   time.Time
+	last    map[key]Series
+	latency map[key]*histogram.Histogram // since the last batch
+
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewPusher starts pushing to exporter every interval. Close stops it
+func NewPusher(exporter Exporter, testID string, interval time.Duration) *Pusher {
+	p := &Pusher{
+		Metrics:  New(),
+		exporter: exporter,
+		testID:   testID,
+		interval: interval,
+		start:    time.Now(),
+		last:     map[key]Series{},
+		latency:  map[key]*histogram.Histogram{},
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go p.loop()
+	return p
+}
+
+func (p *Pusher) Write(collection *models.Collection, run models.Run) error {
+	p.Metrics.Write(collection, run)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for i, result := range run.Results {
+		if result.Error != nil {
+			continue
+		}
+		k := key{collection.Name, i}
+		h, ok := p.latency[k]
+		if !ok {
+			h = histogram.New()
+			p.latency[k] = h
+		}
+		h.Record(int64(result.Duration))
+	}
+
+	return nil
+}
+
+// Close pushes one last batch, and stops pushing. The exporter is closed too, if it can be
+func (p *Pusher) Close() error {
+	var err error
+	p.closeOnce.Do(func() {
+		close(p.stop)
+		<-p.done
+		if c, ok := p.exporter.(io.Closer); ok {
+			err = c.Close()
+		}
+	})
+	return err
+}
+
+func (p *Pusher) loop() {
+	defer close(p.done)
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			p.push()
+		case <-p.stop:
+			p.push()
+			return
+		}
+	}
+}
+
+func (p *Pusher) push() {
+	if err := p.exporter.Export(p.batch()); err != nil && p.OnError != nil {
+		p.OnError(err)
+	}
+}
+
+// batch takes what's been counted so far, and starts the next interval
+func (p *Pusher) batch() Batch {
+	series := p.Snapshot()
+	workers, inFlight := p.Gauges()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	b := Batch{
+		TestID:   p.testID,
+		Start:    p.start,
+		Time:     time.Now(),
+		Series:   series,
+		Deltas:   make([]Series, len(series)),
+		Latency:  make([]Latency, len(series)),
+		Workers:  workers,
+		InFlight: inFlight,
+	}
+	for i, s := range series {
+		b.Deltas[i] = delta(s, p.last[s.key])
+		p.last[s.key] = s
+
+		if h := p.latency[s.key]; h != nil {
+			b.Latency[i] = Latency{
+				P50: time.Duration(h.Quantile(0.5)),
+				P95: time.Duration(h.Quantile(0.95)),
+				P99: time.Duration(h.Quantile(0.99)),
+				Max: time.Duration(h.Max()),
+			}
+		}
+	}
+	p.latency = map[key]*histogram.Histogram{}
+
+	return b
+}
+
+// delta is what s counted since last
+func delta(s, last Series) Series {
+	d := s
+	d.Requests -= last.Requests
+	d.Bytes -= last.Bytes
+	d.Count -= last.Count
+	d.Sum -= last.Sum
+	d.Errors = map[string]uint64{}
+	for class, n := range s.Errors {
+		d.Errors[class] = n - last.Errors[class]
+	}
+	d.Buckets = make([]uint64, len(s.Buckets))
+	for i, n := range s.Buckets {
+		d.Buckets[i] = n
+		if i < len(last.Buckets) {
+			d.Buckets[i] -= last.Buckets[i]
+		}
+	}
+	return d
+}
@@ -0,0 +1,114 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jonny-burkholder/swarm/internal/models"
+)
+
+// testBatch is a batch with one series, which had 3 requests since the last batch. Two
+// got a response, in 10ms and 20ms, and one was a 5xx
+func testBatch(workers, inFlight int) Batch {
+	buckets := make([]uint64, len(Buckets()))
+	for i, bound := range Buckets() {
+		if bound >= 0.02 {
+			buckets[i] = 2
+		} else if bound >= 0.01 {
+			buckets[i] = 1
+		}
+	}
+	series := Series{
+		Collection: "library",
+		Request:    "GET books",
+		Requests:   3,
+		Errors:     map[string]uint64{Class5xx: 1},
+		Bytes:      120,
+		Count:      2,
+		Sum:        30 * time.Millisecond,
+		Buckets:    buckets,
+	}
+	return Batch{
+		TestID:   "t1",
+		Start:    time.Unix(90, 0),
+		Time:     time.Unix(100, 0),
+		Series:   []Series{series},
+		Deltas:   []Series{series},
+		Latency:  []Latency{{P50: 10 * time.Millisecond, P95: 20 * time.Millisecond, P99: 20 * time.Millisecond, Max: 20 * time.Millisecond}},
+		Workers:  workers,
+		InFlight: inFlight,
+	}
+}
+
+// batches keeps every batch it's given
+type batches []Batch
+
+func (b *batches) Export(batch Batch) error {
+	*b = append(*b, batch)
+	return nil
+}
+
+func TestPusherBatch(t *testing.T) {
+	tests := []struct {
+		name         string
+		requests     []string
+		durations    []time.Duration
+		wantRequests []string
+	}{
+		{
+			name:         "unique names",
+			requests:     []string{"login", "list"},
+			durations:    []time.Duration{time.Millisecond, 50 * time.Millisecond},
+			wantRequests: []string{"login", "list"},
+		},
+		{
+			name:         "names used more than once",
+			requests:     []string{"GET /books", "GET /books"},
+			durations:    []time.Duration{time.Millisecond, 50 * time.Millisecond},
+			wantRequests: []string{"GET /books", "GET /books (2)"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			collection := &models.Collection{Name: "test"}
+			run := models.Run{}
+			for i, name := range tt.requests {
+				request := models.Request{Name: name}
+				collection.Requests = append(collection.Requests, request)
+				run.Results = append(run.Results, models.Result{Request: request, StatusCode: 200, Duration: tt.durations[i]})
+			}
+
+			exported := &batches{}
+			p := NewPusher(exported, "t1", time.Hour)
+			p.Write(collection, run)
+			first := p.batch()
+			p.Write(collection, run)
+			p.Write(collection, run)
+			second := p.batch()
+			p.Close()
+
+			for i, want := range tt.wantRequests {
+				if got := first.Series[i].Request; got != want {
+					t.Errorf("Series[%d].Request = %q, want %q", i, got, want)
+				}
+				if got := first.Deltas[i].Requests; got != 1 {
+					t.Errorf("first batch Deltas[%d].Requests = %d, want 1", i, got)
+				}
+				if got := second.Deltas[i].Requests; got != 2 {
+					t.Errorf("second batch Deltas[%d].Requests = %d, want 2", i, got)
+				}
+				if got := second.Series[i].Requests; got != 3 {
+					t.Errorf("second batch Series[%d].Requests = %d, want 3", i, got)
+				}
+			}
+			// each series' latency is only its own
+			if first.Latency[0].Max >= first.Latency[1].Max {
+				t.Errorf("Latency[0].Max = %s, want less than Latency[1].Max = %s", first.Latency[0].Max, first.Latency[1].Max)
+			}
+			if len(*exported) != 1 {
+				t.Errorf("%d batches exported, want 1 when closed", len(*exported))
+			}
+		})
+	}
+}
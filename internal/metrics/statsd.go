@@ -0,0 +1,109 @@
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// maxPacket keeps statsd packets small enough not to be fragmented on most networks
+const maxPacket = 1432
+
+// StatsD sends metrics to a statsd server over udp, with tags the way dogstatsd does them.
+// Counters are what changed since the last batch, and latencies are in milliseconds
+type StatsD struct {
+	conn net.Conn
+}
+
+func NewStatsD(addr string) (*StatsD, error) {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return &StatsD{conn: conn}, nil
+}
+
+func (s *StatsD) Export(batch Batch) error {
+	lines := []string{}
+	for i, series := range batch.Deltas {
+		tags := statsdTags("test_id", batch.TestID, "collection", series.Collection, "request", series.Request)
+		lines = append(lines,
+			fmt.Sprintf("swarm.requests:%d|c%s", series.Requests, tags),
+			fmt.Sprintf("swarm.response_bytes:%d|c%s", series.Bytes, tags),
+		)
+		for _, class := range Classes() {
+			lines = append(lines, fmt.Sprintf("swarm.errors:%d|c%s,class:%s", series.Errors[class], tags, class))
+		}
+		if series.Count > 0 {
+			latency := batch.Latency[i]
+			lines = append(lines,
+				fmt.Sprintf("swarm.latency.p50:%s|g%s", milliseconds(latency.P50), tags),
+				fmt.Sprintf("swarm.latency.p95:%s|g%s", milliseconds(latency.P95), tags),
+				fmt.Sprintf("swarm.latency.p99:%s|g%s", milliseconds(latency.P99), tags),
+				fmt.Sprintf("swarm.latency.max:%s|g%s", milliseconds(latency.Max), tags),
+			)
+		}
+	}
+	tags := statsdTags("test_id", batch.TestID)
+	if batch.Workers >= 0 {
+		lines = append(lines, fmt.Sprintf("swarm.workers_busy:%d|g%s", batch.Workers, tags))
+	}
+	if batch.InFlight >= 0 {
+		lines = append(lines, fmt.Sprintf("swarm.requests_in_flight:%d|g%s", batch.InFlight, tags))
+	}
+
+	// as many lines as fit go in each packet
+	packet := bytes.Buffer{}
+	for _, line := range lines {
+		if packet.Len() > 0 && packet.Len()+1+len(line) > maxPacket {
+			if _, err := s.conn.Write(packet.Bytes()); err != nil {
+				return fmt.Errorf("pushing to statsd: %w", err)
+			}
+			packet.Reset()
+		}
+		if packet.Len() > 0 {
+			packet.WriteByte('\n')
+		}
+		packet.WriteString(line)
+	}
+	if packet.Len() > 0 {
+		if _, err := s.conn.Write(packet.Bytes()); err != nil {
+			return fmt.Errorf("pushing to statsd: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (s *StatsD) Close() error {
+	return s.conn.Close()
+}
+
+// statsdTags formats name value pairs as dogstatsd tags, like |#collection:library,request:get.
+// Characters that would break the line are replaced
+func statsdTags(pairs ...string) string {
+	parts := []string{}
+	for i := 0; i+1 < len(pairs); i += 2 {
+		if pairs[i+1] == "" {
+			continue
+		}
+		parts = append(parts, pairs[i]+":"+statsdEscape(pairs[i+1]))
+	}
+	return "|#" + strings.Join(parts, ",")
+}
+
+// statsdEscape replaces the characters that would break a tag with underscores
+func statsdEscape(s string) string {
+	return strings.Map(func(r rune) rune {
+		if strings.ContainsRune(",|# \n", r) {
+			return '_'
+		}
+		return r
+	}, s)
+}
+
+func milliseconds(d time.Duration) string {
+	return formatFloat(float64(d) / float64(time.Millisecond))
+}
@@ -0,0 +1,125 @@
+package metrics
+
+import (
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStatsDExport(t *testing.T) {
+	tags := "|#test_id:t1,collection:library,request:GET_books"
+	series := []string{
+		"swarm.requests:3|c" + tags,
+		"swarm.response_bytes:120|c" + tags,
+		"swarm.errors:0|c" + tags + ",class:request",
+		"swarm.errors:0|c" + tags + ",class:4xx",
+		"swarm.errors:1|c" + tags + ",class:5xx",
+		"swarm.errors:0|c" + tags + ",class:assertion",
+		"swarm.latency.p50:10|g" + tags,
+		"swarm.latency.p95:20|g" + tags,
+		"swarm.latency.p99:20|g" + tags,
+		"swarm.latency.max:20|g" + tags,
+	}
+
+	many := testBatch(-1, -1)
+	for i := range 50 {
+		s := many.Deltas[0]
+		s.Request = fmt.Sprintf("request %d", i)
+		many.Deltas = append(many.Deltas, s)
+		many.Latency = append(many.Latency, many.Latency[0])
+	}
+
+	tests := []struct {
+		name      string
+		batch     Batch
+		wantLines []string // nil to only count them
+		wantCount int
+	}{
+		{
+			name:  "series and gauges",
+			batch: testBatch(4, 2),
+			wantLines: append(slices.Clone(series),
+				"swarm.workers_busy:4|g|#test_id:t1",
+				"swarm.requests_in_flight:2|g|#test_id:t1",
+			),
+		},
+		{
+			name:      "gauges that aren't known are left out",
+			batch:     testBatch(-1, -1),
+			wantLines: series,
+		},
+		{
+			name:      "more than fits in a packet",
+			batch:     many,
+			wantCount: 51 * len(series),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+
+			s, err := NewStatsD(conn.LocalAddr().String())
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer s.Close()
+
+			if err := s.Export(tt.batch); err != nil {
+				t.Fatalf("Export() error = %v", err)
+			}
+
+			want := tt.wantCount
+			if tt.wantLines != nil {
+				want = len(tt.wantLines)
+			}
+			var lines []string
+			buf := make([]byte, 64*1024)
+			conn.SetReadDeadline(time.Now().Add(time.Second))
+			for len(lines) < want {
+				n, _, err := conn.ReadFrom(buf)
+				if err != nil {
+					t.Fatalf("got %d lines, want %d: %v", len(lines), want, err)
+				}
+				if n > maxPacket {
+					t.Errorf("packet is %d bytes, want at most %d", n, maxPacket)
+				}
+				lines = append(lines, strings.Split(string(buf[:n]), "\n")...)
+			}
+
+			if tt.wantLines != nil && !slices.Equal(lines, tt.wantLines) {
+				t.Errorf("lines =\n%s\nwant\n%s", strings.Join(lines, "\n"), strings.Join(tt.wantLines, "\n"))
+			}
+			if len(lines) != want {
+				t.Errorf("got %d lines, want %d", len(lines), want)
+			}
+		})
+	}
+}
+
+func TestStatsDTags(t *testing.T) {
+	tests := []struct {
+		name  string
+		pairs []string
+		want  string
+	}{
+		{name: "plain", pairs: []string{"collection", "library", "request", "list"}, want: "|#collection:library,request:list"},
+		{name: "characters that break the line", pairs: []string{"request", "GET /books, #1|2\nnext"}, want: "|#request:GET_/books___1_2_next"},
+		{name: "empty values are left out", pairs: []string{"test_id", "", "collection", "library"}, want: "|#collection:library"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := statsdTags(tt.pairs...); got != tt.want {
+				t.Errorf("statsdTags(%q) = %q, want %q", tt.pairs, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,186 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jonny-burkholder/swarm/internal/histogram"
+)
+
+// Aggregate tallies results as they come in, so that summaries don't
+// need every result to be kept around
+type Aggregate struct {
+	Name   string
+	Count  int
+	Errors int // requests that couldn't be sent, or got no response
+	Failed int // requests with a failed assertion or schema violation
+	// requests that errored, failed, or got a 5xx. A request can be more than one of
+	// those, so this isn't a sum of the others
+	Unsuccessful int
+	Bytes        int64
+	StatusCodes  map[int]int
+	// durations of requests that got a response, in nanoseconds. Errors tend to be
+	// either instant or a timeout, and would throw the numbers off
+	Latency *histogram.Histogram
+	// like Latency, but from when each request was due to be sent rather than when it was, so
+	// that time spent waiting to send it counts. It's only kept once a request has had to
+	// wait, and until then it's the same as Latency
+	Intended *histogram.Histogram
+	Phases   Timing // the total time spent in each phase, for working out means
+	Reused   int    // requests that reused a connection
+	// why requests failed, keyed by the assertion, the part of the schema, or
+	// "request" for errors
+	Failures map[string]*Failure
+}
+
+// Failure counts how often an assertion or part of the schema failed. The messages include
+// the actual value, which is often different every time, so we only keep one as an example
+type Failure struct {
+	Count   int
+	Example string
+}
+
+func NewAggregate(name string) Aggregate {
+	return Aggregate{
+		Name:        name,
+		StatusCodes: map[int]int{},
+		Latency:     histogram.New(),
+		Failures:    map[string]*Failure{},
+	}
+}
+
+func (a *Aggregate) Add(result Result) {
+	a.Count++
+	if result.Error != nil {
+		a.Errors++
+		a.Unsuccessful++
+		a.fail("request", result.Error.Error(), 1)
+		return
+	}
+	if !result.Passed() {
+		a.Failed++
+	}
+	if !result.Passed() || result.StatusCode >= 500 {
+		a.Unsuccessful++
+	}
+	for _, assertion := range result.Assertions {
+		if !assertion.Result {
+			a.fail(fmt.Sprintf("%s %s %v", assertion.Field, Operator(assertion.Operator), assertion.Value), assertion.Message, 1)
+		}
+	}
+	for _, v := range result.Violations {
+		a.fail("schema at "+v.Path, v.Message, 1)
+	}
+
+	a.Bytes += result.BytesReceived
+	a.StatusCodes[result.StatusCode]++
+	if result.Wait > 0 && a.Intended == nil {
+		// every request before this one was sent as soon as it was due
+		a.Intended = a.Latency.Clone()
+	}
+	a.Latency.Record(int64(result.Duration))
+	if a.Intended != nil {
+		a.Intended.Record(int64(result.Duration + result.Wait))
+	}
+	a.Phases.DNS += result.Timing.DNS
+	a.Phases.Connect += result.Timing.Connect
+	a.Phases.TLS += result.Timing.TLS
+	a.Phases.TTFB += result.Timing.TTFB
+	a.Phases.Transfer += result.Timing.Transfer
+	if result.Timing.ConnReused {
+		a.Reused++
+	}
+}
+
+func (a *Aggregate) fail(key, msg string, count int) {
+	f, ok := a.Failures[key]
+	if !ok {
+		f = &Failure{Example: msg}
+		a.Failures[key] = f
+	}
+	f.Count += count
+}
+
+func (a *Aggregate) Merge(o Aggregate) {
+	a.Count += o.Count
+	a.Errors += o.Errors
+	a.Failed += o.Failed
+	a.Unsuccessful += o.Unsuccessful
+	a.Bytes += o.Bytes
+	for code, n := range o.StatusCodes {
+		a.StatusCodes[code] += n
+	}
+	if a.Intended != nil || o.Intended != nil {
+		intended := a.IntendedLatency().Clone()
+		intended.Merge(o.IntendedLatency())
+		a.Intended = intended
+	}
+	a.Latency.Merge(o.Latency)
+	a.Phases.DNS += o.Phases.DNS
+	a.Phases.Connect += o.Phases.Connect
+	a.Phases.TLS += o.Phases.TLS
+	a.Phases.TTFB += o.Phases.TTFB
+	a.Phases.Transfer += o.Phases.Transfer
+	a.Reused += o.Reused
+	for key, f := range o.Failures {
+		a.fail(key, f.Example, f.Count)
+	}
+}
+
+// IntendedLatency is the latency of each request from when it was due to be sent
+func (a Aggregate) IntendedLatency() *histogram.Histogram {
+	if a.Intended != nil {
+		return a.Intended
+	}
+	return a.Latency
+}
+
+// Corrected is the latency of each request from when it was due to be sent, corrected for
+// coordinated omission. If interval is set, requests are taken to have been due that often,
+// and the ones that would have been sent while a slow one held things up are filled in.
+// Requests that had to wait were already measured from when they were due, and filling
+// in after them would count the same hold up twice
+func (a Aggregate) Corrected(interval time.Duration) *histogram.Histogram {
+	h := a.IntendedLatency()
+	if h == nil || interval <= 0 || a.Intended != nil {
+		return h
+	}
+	return h.Corrected(int64(interval))
+}
+
+// TotalName is the name of the aggregate for the collection as a whole, so no request can have it
+const TotalName = "total"
+
+// Aggregates are kept for each request in a collection, and for the collection as a whole
+type Aggregates struct {
+	Runs       int
+	Incomplete int // runs that were cut short
+	Total      Aggregate
+	Requests   []Aggregate // in the same order as the collection's requests
+}
+
+func NewAggregates(requests []Request) *Aggregates {
+	a := &Aggregates{
+		Total:    NewAggregate(TotalName),
+		Requests: make([]Aggregate, len(requests)),
+	}
+	for i, request := range requests {
+		a.Requests[i] = NewAggregate(request.Name)
+	}
+	return a
+}
+
+// Add adds every result in a run. Results are in the same order as the collection's requests
+func (a *Aggregates) Add(run Run) {
+	a.Runs++
+	if run.Error != nil {
+		a.Incomplete++
+	}
+	for i, result := range run.Results {
+		if i >= len(a.Requests) {
+			break
+		}
+		a.Requests[i].Add(result)
+		a.Total.Add(result)
+	}
+}
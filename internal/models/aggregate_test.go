@@ -0,0 +1,108 @@
+package models
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestAggregateAddIntended(t *testing.T) {
+	tests := []struct {
+		name         string
+		waits        []time.Duration
+		wantIntended bool
+	}{
+		{
+			name:         "no request waited",
+			waits:        []time.Duration{0, 0, 0},
+			wantIntended: false,
+		},
+		{
+			name:         "first request waited",
+			waits:        []time.Duration{time.Millisecond, 0, 0},
+			wantIntended: true,
+		},
+		{
+			name:         "a later request waited",
+			waits:        []time.Duration{0, 0, time.Millisecond, 0},
+			wantIntended: true,
+		},
+		{
+			name:         "every request waited",
+			waits:        []time.Duration{time.Millisecond, 2 * time.Millisecond, 3 * time.Millisecond},
+			wantIntended: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := NewAggregate("test")
+			for _, wait := range tt.waits {
+				a.Add(Result{StatusCode: 200, Duration: 10 * time.Millisecond, Wait: wait})
+			}
+
+			if got := a.Intended != nil; got != tt.wantIntended {
+				t.Fatalf("Intended set = %v, want %v", got, tt.wantIntended)
+			}
+			if got, want := a.IntendedLatency().Count(), a.Latency.Count(); got != want {
+				t.Errorf("Intended.Count() = %d, want Latency.Count() = %d", got, want)
+			}
+		})
+	}
+}
+
+func TestAggregateCorrected(t *testing.T) {
+	tests := []struct {
+		name      string
+		wait      time.Duration
+		interval  time.Duration
+		wantCount uint64
+	}{
+		{
+			name:      "no interval",
+			interval:  0,
+			wantCount: 1,
+		},
+		{
+			name:      "interval fills in requests held up",
+			interval:  10 * time.Millisecond,
+			wantCount: 10,
+		},
+		{
+			name:      "requests that waited aren't filled in again",
+			wait:      time.Millisecond,
+			interval:  10 * time.Millisecond,
+			wantCount: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := NewAggregate("test")
+			a.Add(Result{StatusCode: 200, Duration: 100 * time.Millisecond, Wait: tt.wait})
+
+			if got := a.Corrected(tt.interval).Count(); got != tt.wantCount {
+				t.Errorf("Corrected(%s).Count() = %d, want %d", tt.interval, got, tt.wantCount)
+			}
+		})
+	}
+}
+
+func TestAggregateAddUnsuccessful(t *testing.T) {
+	failed := []Assertion{{Field: "status", Value: 200, Result: false}}
+	a := NewAggregate("test")
+	for _, r := range []Result{
+		{StatusCode: 200},
+		{StatusCode: 404},
+		{StatusCode: 500},
+		{StatusCode: 500, Assertions: failed},
+		{StatusCode: 200, Assertions: failed},
+		{Error: errors.New("connection refused")},
+	} {
+		a.Add(r)
+	}
+
+	if a.Errors != 1 || a.Failed != 2 || a.Unsuccessful != 4 {
+		t.Errorf("Errors, Failed, Unsuccessful = %d, %d, %d, want 1, 2, 4", a.Errors, a.Failed, a.Unsuccessful)
+	}
+}
@@ -1,17 +1,58 @@
 package models
 
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"math/big"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jonny-burkholder/swarm/internal/jsonpath"
+)
+
 const (
 	opEqual operator = iota
 	opNotEqual
-	// opLessThan
-	// opGreaterThan
+	opLessThan
+	opLessThanOrEqual
+	opGreaterThan
+	opGreaterThanOrEqual
+	opContains
+	opMatches
+	opExists
+	opIn
+	opType
 )
 
+// The fields an assertion can check. Header and json fields are followed
+// by the header name or the path into the body, e.g. "json.data.id"
+const (
+	FieldStatusCode   = "status_code"
+	FieldResponseTime = "response_time"
+	FieldBodySize     = "body_size"
+	FieldBody         = "body"
+	FieldHeader       = "header."
+	FieldJSON         = "json"
+)
+
+// jsonTypes are the types "type" can check for
+const jsonTypes = "string, number, integer, boolean, array, object, null"
+
 type Assertion struct {
 	Field    string
 	Value    any
 	Operator operator
 	Result   bool
+	Actual   any    // the value that was found in the response
+	Message  string // why the assertion failed, empty if it passed
+	regex    *regexp.Regexp
 }
 
 type operator int
@@ -22,23 +63,537 @@ func Operator(op operator) string {
 		return "="
 	case opNotEqual:
 		return "!="
-		// case opLessThan:
-		// 	return "<"
-		// case opGreaterThan:
-		// 	return ">"
+	case opLessThan:
+		return "<"
+	case opLessThanOrEqual:
+		return "<="
+	case opGreaterThan:
+		return ">"
+	case opGreaterThanOrEqual:
+		return ">="
+	case opContains:
+		return "contains"
+	case opMatches:
+		return "matches"
+	case opExists:
+		return "exists"
+	case opIn:
+		return "in"
+	case opType:
+		return "type"
 	}
 	return ""
 }
 
+// ParseOperator returns the operator for the name used in collection files,
+// e.g. "equal" or "greater_than". The symbols ("=", ">" etc.) work too
+func ParseOperator(name string) (operator, error) {
+	switch name {
+	case "equal", "=", "==":
+		return opEqual, nil
+	case "not_equal", "!=":
+		return opNotEqual, nil
+	case "less_than", "<":
+		return opLessThan, nil
+	case "less_than_or_equal", "<=":
+		return opLessThanOrEqual, nil
+	case "greater_than", ">":
+		return opGreaterThan, nil
+	case "greater_than_or_equal", ">=":
+		return opGreaterThanOrEqual, nil
+	case "contains":
+		return opContains, nil
+	case "matches":
+		return opMatches, nil
+	case "exists":
+		return opExists, nil
+	case "in":
+		return opIn, nil
+	case "type":
+		return opType, nil
+	}
+	return 0, fmt.Errorf("unknown assertion operator '%s'", name)
+}
+
+// Validate checks that the field is one we know how to find,
+// and that the value makes sense for the operator
+func (a Assertion) Validate() error {
+	field := canonicalField(a.Field)
+	switch {
+	case field == FieldStatusCode, field == FieldResponseTime, field == FieldBodySize, field == FieldBody:
+	case strings.HasPrefix(field, FieldHeader) && len(field) > len(FieldHeader):
+	case field == FieldJSON:
+	case strings.HasPrefix(field, FieldJSON+"."), strings.HasPrefix(field, FieldJSON+"["):
+		if _, err := jsonpath.Parse(strings.TrimPrefix(field, FieldJSON)); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown assertion field '%s'", a.Field)
+	}
+
+	switch a.Operator {
+	case opLessThan, opLessThanOrEqual, opGreaterThan, opGreaterThanOrEqual:
+		if _, ok := a.expectedNumber(); !ok {
+			return fmt.Errorf("%s %s needs a number, got %v", a.Field, Operator(a.Operator), a.Value)
+		}
+	case opMatches:
+		pattern, ok := a.Value.(string)
+		if !ok {
+			return fmt.Errorf("%s matches needs a regular expression, got %v", a.Field, a.Value)
+		}
+		if _, err := regexp.Compile(pattern); err != nil {
+			return fmt.Errorf("%s matches has an invalid regular expression: %w", a.Field, err)
+		}
+	case opExists:
+		if _, ok := a.Value.(bool); !ok {
+			return fmt.Errorf("%s exists needs true or false, got %v", a.Field, a.Value)
+		}
+	case opIn:
+		if _, ok := a.Value.([]any); !ok {
+			return fmt.Errorf("%s in needs a list, got %v", a.Field, a.Value)
+		}
+	case opType:
+		if s, ok := a.Value.(string); !ok || !isJSONType(s) {
+			return fmt.Errorf("%s type must be one of: %s", a.Field, jsonTypes)
+		}
+	case opEqual, opNotEqual, opContains:
+	}
+
+	return nil
+}
+
+// Compile validates the assertion, and compiles the regular expression for "matches"
+// so that it isn't compiled again for every response
+func (a Assertion) Compile() (Assertion, error) {
+	if err := a.Validate(); err != nil {
+		return a, err
+	}
+	if a.Operator == opMatches {
+		a.regex = regexp.MustCompile(a.Value.(string)) // Validate made sure it compiles
+	}
+	return a, nil
+}
+
 // Assert evaluates the fields of the assertion to true or false
 // based on the stated operator
 func (a Assertion) Assert(value any) Assertion {
+	return a.assert(value, true)
+}
+
+// assert compares the actual value to the expected one. found is false if the
+// field wasn't in the response at all, which only "exists" is happy about
+func (a Assertion) assert(actual any, found bool) Assertion {
+	a.Actual = actual
+	a.Message = ""
+
+	if a.Operator == opExists {
+		want, _ := a.Value.(bool)
+		a.Result = found == want
+		if !a.Result {
+			if want {
+				a.Message = fmt.Sprintf("expected %s to exist", a.Field)
+			} else {
+				a.Message = fmt.Sprintf("expected %s not to exist, got %v", a.Field, format(actual))
+			}
+		}
+		return a
+	}
+
+	if !found {
+		a.Result = false
+		a.Message = fmt.Sprintf("%s not found in response", a.Field)
+		return a
+	}
+
 	// there's gotta be a better way
 	switch a.Operator {
 	case opEqual:
-		a.Result = (value == a.Value)
+		a.Result = equal(actual, a.expected())
 	case opNotEqual:
-		a.Result = (value != a.Value)
+		a.Result = !equal(actual, a.expected())
+	case opLessThan, opLessThanOrEqual, opGreaterThan, opGreaterThanOrEqual:
+		a.Result = a.compare(actual)
+	case opContains:
+		a.Result = contains(actual, a.Value)
+	case opMatches:
+		a.Result = a.matches(actual)
+	case opIn:
+		list, _ := a.Value.([]any)
+		a.Result = slices.ContainsFunc(list, func(v any) bool {
+			return equal(actual, v)
+		})
+	case opType:
+		a.Result = typeOf(actual) == a.Value || (a.Value == "number" && typeOf(actual) == "integer")
+	case opExists:
+	}
+
+	if !a.Result {
+		want, got := format(a.Value), format(actual)
+		if f, ok := toFloat(actual); ok && canonicalField(a.Field) == FieldResponseTime {
+			want, got = a.Value, time.Duration(f*float64(time.Millisecond))
+		}
+		a.Message = fmt.Sprintf("expected %s %s %v, got %v", a.Field, Operator(a.Operator), want, got)
+		if a.Operator == opType {
+			a.Message = fmt.Sprintf("expected %s to be of type %v, got %s", a.Field, a.Value, typeOf(actual))
+		}
 	}
+
 	return a
 }
+
+// expected returns the value to compare against. Response times
+// are compared in milliseconds, so durations are converted
+func (a Assertion) expected() any {
+	if canonicalField(a.Field) == FieldResponseTime {
+		if n, ok := a.expectedNumber(); ok {
+			return n
+		}
+	}
+	return a.Value
+}
+
+// expectedNumber returns the value as a number. For response_time, durations like
+// "200ms" are allowed, and plain numbers are taken to be milliseconds
+func (a Assertion) expectedNumber() (float64, bool) {
+	if s, ok := a.Value.(string); ok && canonicalField(a.Field) == FieldResponseTime {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return 0, false
+		}
+		return milliseconds(d), true
+	}
+	return toFloat(a.Value)
+}
+
+func (a Assertion) compare(actual any) bool {
+	c, ok := compareNumbers(actual, a.expected())
+	if !ok {
+		return false
+	}
+
+	switch a.Operator {
+	case opLessThan:
+		return c < 0
+	case opLessThanOrEqual:
+		return c <= 0
+	case opGreaterThan:
+		return c > 0
+	case opGreaterThanOrEqual:
+		return c >= 0
+	case opEqual, opNotEqual, opContains, opMatches, opExists, opIn, opType:
+	}
+	return false
+}
+
+// CheckAssertions evaluates every assertion on the request against the response, and
+// stores them in r.Assertions. If the request has a schema, the body is checked against
+// it too, and anything wrong is stored in r.Violations
+func (r *Result) CheckAssertions() {
+	if len(r.Assert) == 0 && r.Schema == nil {
+		return
+	}
+
+	// only decode the body once, and only if something needs it
+	var doc any
+	var docErr error
+	decoded := false
+	decode := func() (any, error) {
+		if !decoded {
+			doc, docErr = decodeJSON(r.Body)
+			decoded = true
+		}
+		return doc, docErr
+	}
+
+	// checking a body we don't have all of would pass or fail for the wrong reasons
+	missing := ""
+	switch {
+	case r.BodyDiscarded:
+		missing = "the response body was discarded (discard_body)"
+	case r.BodyTruncated:
+		missing = "the response body was cut short at max_body_size"
+	}
+
+	r.Assertions = make([]Assertion, len(r.Assert))
+	for i, a := range r.Assert {
+		field := canonicalField(a.Field)
+		if missing != "" && a.OnBody() {
+			a.Result = false
+			a.Message = fmt.Sprintf("%s can't be checked, %s", a.Field, missing)
+			r.Assertions[i] = a
+			continue
+		}
+		switch {
+		case field == FieldStatusCode:
+			r.Assertions[i] = a.assert(r.StatusCode, r.StatusCode != 0)
+		case field == FieldResponseTime:
+			r.Assertions[i] = a.assert(milliseconds(r.Duration), true)
+			r.Assertions[i].Actual = r.Duration
+		case field == FieldBodySize:
+			r.Assertions[i] = a.assert(r.BytesReceived, true)
+		case field == FieldBody:
+			r.Assertions[i] = a.assert(string(r.Body), true)
+		case strings.HasPrefix(field, FieldHeader):
+			values := http.Header(r.Headers).Values(strings.TrimPrefix(field, FieldHeader))
+			r.Assertions[i] = a.assert(strings.Join(values, ", "), len(values) > 0)
+		default:
+			doc, err := decode()
+			if err != nil {
+				a.Result = false
+				a.Message = fmt.Sprintf("%s: response body isn't valid json: %v", a.Field, err)
+				r.Assertions[i] = a
+				continue
+			}
+			v, found, err := jsonpath.Get(doc, strings.TrimPrefix(field, FieldJSON))
+			if err != nil {
+				a.Result = false
+				a.Message = err.Error()
+				r.Assertions[i] = a
+				continue
+			}
+			r.Assertions[i] = a.assert(v, found)
+		}
+	}
+
+	if r.Schema != nil {
+		if missing != "" {
+			r.Violations = []SchemaViolation{{
+				Path:    "/",
+				Message: fmt.Sprintf("the schema can't be checked, %s", missing),
+			}}
+			return
+		}
+		doc, err := decode()
+		if err != nil {
+			r.Violations = []SchemaViolation{{
+				Path:    "/",
+				Message: fmt.Sprintf("response body isn't valid json: %v", err),
+			}}
+			return
+		}
+		r.Violations = r.Schema.Validate(doc)
+	}
+}
+
+// OnBody is true if the assertion is on the body, or on json in it
+func (a Assertion) OnBody() bool {
+	field := canonicalField(a.Field)
+	return field == FieldBody || field == FieldJSON || strings.HasPrefix(field, FieldJSON+".") || strings.HasPrefix(field, FieldJSON+"[")
+}
+
+// OnHeader returns the response header the assertion is on, if it's on one
+func (a Assertion) OnHeader() (string, bool) {
+	return strings.CutPrefix(canonicalField(a.Field), FieldHeader)
+}
+
+func canonicalField(field string) string {
+	// other names people are likely to reach for
+	switch field {
+	case "status":
+		return FieldStatusCode
+	case "response_length":
+		return FieldBodySize
+	case "duration":
+		return FieldResponseTime
+	}
+	if strings.HasPrefix(field, "headers.") {
+		return FieldHeader + strings.TrimPrefix(field, "headers.")
+	}
+	return field
+}
+
+// decodeJSON keeps numbers as json.Number, so that big
+// integers can be compared exactly rather than as floats
+func decodeJSON(body []byte) (any, error) {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var doc any
+	err := dec.Decode(&doc)
+	return doc, err
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// toFloat converts any of the number types we might come
+// across, from json or yaml, to a float64
+func toFloat(v any) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case float64:
+		return n, true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// toInt converts integers, from json or yaml, to a big.Int, so
+// that ones too big for a float64 can still be compared exactly
+func toInt(v any) (*big.Int, bool) {
+	switch n := v.(type) {
+	case int:
+		return big.NewInt(int64(n)), true
+	case int64:
+		return big.NewInt(n), true
+	case uint64:
+		return new(big.Int).SetUint64(n), true
+	case json.Number:
+		return new(big.Int).SetString(n.String(), 10)
+	}
+	return nil, false
+}
+
+// compareNumbers returns -1, 0 or 1 as a is less than, equal to or greater than b.
+// Integers are compared exactly, and anything else as floats. It returns false if
+// either isn't a number
+func compareNumbers(a, b any) (int, bool) {
+	if ai, ok := toInt(a); ok {
+		if bi, ok := toInt(b); ok {
+			return ai.Cmp(bi), true
+		}
+	}
+	af, aok := toFloat(a)
+	bf, bok := toFloat(b)
+	if !aok || !bok {
+		return 0, false
+	}
+	return cmp.Compare(af, bf), true
+}
+
+// equal compares numbers by value, whatever their types,
+// and everything else as it is
+func equal(a, b any) bool {
+	_, aok := toFloat(a)
+	_, bok := toFloat(b)
+	if aok && bok {
+		c, _ := compareNumbers(a, b)
+		return c == 0
+	}
+	if aok != bok {
+		// e.g. a header "3" compared with 3
+		return fmt.Sprint(a) == fmt.Sprint(b)
+	}
+
+	switch av := a.(type) {
+	case nil:
+		return b == nil
+	case string:
+		bv, ok := b.(string)
+		return ok && av == bv
+	case bool:
+		bv, ok := b.(bool)
+		return ok && av == bv
+	case []any:
+		bv, ok := b.([]any)
+		return ok && slices.EqualFunc(av, bv, equal)
+	case map[string]any:
+		bv, ok := b.(map[string]any)
+		return ok && maps.EqualFunc(av, bv, equal)
+	}
+	return false
+}
+
+// contains checks for a substring, an element of an array, or a key of an object
+func contains(actual, want any) bool {
+	switch v := actual.(type) {
+	case string:
+		return strings.Contains(v, fmt.Sprint(want))
+	case []any:
+		return slices.ContainsFunc(v, func(e any) bool {
+			return equal(e, want)
+		})
+	case map[string]any:
+		_, ok := v[fmt.Sprint(want)]
+		return ok
+	}
+	return false
+}
+
+func (a Assertion) matches(actual any) bool {
+	re := a.regex
+	if re == nil {
+		// the assertion wasn't compiled, so it's done every time
+		p, ok := a.Value.(string)
+		if !ok {
+			return false
+		}
+		var err error
+		if re, err = regexp.Compile(p); err != nil {
+			return false
+		}
+	}
+	return re.MatchString(fmt.Sprint(actual))
+}
+
+func isJSONType(s string) bool {
+	switch s {
+	case "string", "number", "integer", "boolean", "array", "object", "null":
+		return true
+	}
+	return false
+}
+
+// typeOf returns the json type of a value
+func typeOf(v any) string {
+	switch n := v.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	case json.Number:
+		if isInteger(n) {
+			return "integer"
+		}
+		return "number"
+	case int, int64, uint64:
+		return "integer"
+	case float64:
+		if n == float64(int64(n)) {
+			return "integer"
+		}
+		return "number"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+// isInteger is true if n has no fractional part, like 1.0 or 2e3, however big it is. It works on
+// the digits rather than parsing n, since the exponent can be big enough to run out of memory
+func isInteger(n json.Number) bool {
+	mantissa, exp, _ := strings.Cut(strings.ToLower(n.String()), "e")
+	e := 0
+	if exp != "" {
+		var err error
+		if e, err = strconv.Atoi(exp); err != nil {
+			return false
+		}
+	}
+	whole, frac, _ := strings.Cut(strings.TrimPrefix(mantissa, "-"), ".")
+	digits := strings.TrimLeft(whole+frac, "0")
+	if digits == "" {
+		return true // zero
+	}
+	// n is digits * 10^(e - len(frac)), and each trailing zero can make up for a place
+	zeros := len(digits) - len(strings.TrimRight(digits, "0"))
+	return e-len(frac)+zeros >= 0
+}
+
+func format(v any) any {
+	if s, ok := v.(string); ok {
+		return strconv.Quote(s)
+	}
+	return v
+}
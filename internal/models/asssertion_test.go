@@ -0,0 +1,221 @@
+package models
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAssertionCompile(t *testing.T) {
+	tests := []struct {
+		name    string
+		field   string
+		op      string
+		value   any
+		wantErr string
+	}{
+		{name: "status code", field: "status_code", op: "equal", value: 200},
+		{name: "alias", field: "status", op: "equal", value: 200},
+		{name: "header", field: "header.Content-Type", op: "contains", value: "json"},
+		{name: "json path", field: "json.data[0].id", op: "exists", value: true},
+		{name: "response time as a duration", field: "response_time", op: "<", value: "200ms"},
+		{name: "unknown field", field: "latency", op: "equal", value: 1, wantErr: "unknown assertion field 'latency'"},
+		{name: "header without a name", field: "header.", op: "equal", value: "x", wantErr: "unknown assertion field"},
+		{name: "bad json path", field: "json.data[", op: "exists", value: true, wantErr: "missing ']'"},
+		{name: "comparison needs a number", field: "body_size", op: ">", value: "big", wantErr: "needs a number"},
+		{name: "bad duration", field: "response_time", op: "<", value: "soon", wantErr: "needs a number"},
+		{name: "bad pattern", field: "body", op: "matches", value: "(", wantErr: "invalid regular expression"},
+		{name: "exists needs a bool", field: "json.id", op: "exists", value: "yes", wantErr: "needs true or false"},
+		{name: "in needs a list", field: "status_code", op: "in", value: 200, wantErr: "needs a list"},
+		{name: "unknown type", field: "json.id", op: "type", value: "float", wantErr: "type must be one of"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			op, err := ParseOperator(tt.op)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = Assertion{Field: tt.field, Operator: op, Value: tt.value}.Compile()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Compile() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Compile() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCheckAssertions(t *testing.T) {
+	response := Result{
+		StatusCode:    201,
+		Duration:      150 * time.Millisecond,
+		BytesReceived: 93,
+		Headers:       http.Header{"Content-Type": {"application/json"}, "X-Count": {"3"}},
+		Body:          []byte(`{"id":12345678901234567890,"name":"Deadhouse Gates","tags":["fantasy","epic"],"price":9.5,"author":{"name":"Erikson"},"sequel":null}`),
+	}
+
+	tests := []struct {
+		name        string
+		field       string
+		op          string
+		value       any
+		result      *Result // response if nil
+		want        bool
+		wantMessage string // part of the message, if it failed
+	}{
+		{name: "status equal", field: "status_code", op: "=", value: 201, want: true},
+		{name: "status not equal", field: "status_code", op: "!=", value: 201, want: false, wantMessage: "expected status_code != 201, got 201"},
+		{name: "status in", field: "status_code", op: "in", value: []any{200, 201}, want: true},
+		{name: "status not in", field: "status_code", op: "in", value: []any{200, 204}, want: false},
+		{name: "response time in ms", field: "response_time", op: "<", value: 200, want: true},
+		{name: "response time as a duration", field: "response_time", op: ">", value: "1s", want: false},
+		{name: "body size", field: "body_size", op: ">=", value: 93, want: true},
+		{name: "body contains", field: "body", op: "contains", value: "Deadhouse", want: true},
+		{name: "body matches", field: "body", op: "matches", value: `"name":"Dead\w+`, want: true},
+		{name: "header contains", field: "header.Content-Type", op: "contains", value: "json", want: true},
+		{name: "header in any case", field: "headers.content-type", op: "=", value: "application/json", want: true},
+		{name: "header number", field: "header.X-Count", op: "=", value: 3, want: true},
+		{name: "missing header", field: "header.X-Missing", op: "=", value: "x", want: false, wantMessage: "not found in response"},
+		{name: "big json number", field: "json.id", op: "=", value: "12345678901234567890", want: true},
+		{name: "big json integer", field: "json.id", op: "=", value: uint64(12345678901234567890), want: true},
+		{name: "big json integer off by one", field: "json.id", op: "=", value: uint64(12345678901234567891), want: false},
+		{name: "big json integer compared exactly", field: "json.id", op: ">", value: uint64(12345678901234567889), want: true},
+		{
+			// 2^53 + 1, which is 2^53 as a float
+			name:   "integer past a float's precision",
+			field:  "json.id",
+			op:     "!=",
+			value:  9007199254740992,
+			result: &Result{StatusCode: 200, Body: []byte(`{"id":9007199254740993}`)},
+			want:   true,
+		},
+		{name: "integer against a float", field: "json.price", op: ">", value: 9, want: true},
+		{name: "json float", field: "json.price", op: "<", value: 10, want: true},
+		{name: "json string", field: "json.name", op: "=", value: "Deadhouse Gates", want: true},
+		{name: "json array contains", field: "json.tags", op: "contains", value: "epic", want: true},
+		{name: "json array equal", field: "json.tags", op: "=", value: []any{"fantasy", "epic"}, want: true},
+		{name: "json array in another order", field: "json.tags", op: "=", value: []any{"epic", "fantasy"}, want: false},
+		{name: "json object equal", field: "json.author", op: "=", value: map[string]any{"name": "Erikson"}, want: true},
+		{name: "json object contains key", field: "json.author", op: "contains", value: "name", want: true},
+		{name: "json null", field: "json.sequel", op: "=", value: nil, want: true},
+		{name: "json exists", field: "json.author.name", op: "exists", value: true, want: true},
+		{name: "json doesn't exist", field: "json.isbn", op: "exists", value: false, want: true},
+		{name: "json missing", field: "json.isbn", op: "=", value: "x", want: false, wantMessage: "json.isbn not found in response"},
+		{name: "json type", field: "json.tags", op: "type", value: "array", want: true},
+		{name: "integer is a number", field: "json.id", op: "type", value: "number", want: true},
+		{name: "float isn't an integer", field: "json.price", op: "type", value: "integer", want: false},
+		{name: "big integer is an integer", field: "json.id", op: "type", value: "integer", want: true},
+		{
+			name:   "integer written with a point",
+			field:  "json.n",
+			op:     "type",
+			value:  "integer",
+			result: &Result{StatusCode: 200, Body: []byte(`{"n": 1.0}`)},
+			want:   true,
+		},
+		{
+			name:   "integer written with an exponent",
+			field:  "json.n",
+			op:     "type",
+			value:  "integer",
+			result: &Result{StatusCode: 200, Body: []byte(`{"n": 1.5e3}`)},
+			want:   true,
+		},
+		{
+			name:   "fraction written with an exponent",
+			field:  "json.n",
+			op:     "type",
+			value:  "integer",
+			result: &Result{StatusCode: 200, Body: []byte(`{"n": 1500e-4}`)},
+			want:   false,
+		},
+		{
+			name:   "fraction past a float's precision",
+			field:  "json.n",
+			op:     "type",
+			value:  "integer",
+			result: &Result{StatusCode: 200, Body: []byte(`{"n": 1.00000000000000000001}`)},
+			want:   false,
+		},
+		{
+			name:   "huge exponent",
+			field:  "json.n",
+			op:     "type",
+			value:  "integer",
+			result: &Result{StatusCode: 200, Body: []byte(`{"n": -2E999999999}`)},
+			want:   true,
+		},
+		{
+			name:        "body that isn't json",
+			field:       "json.id",
+			op:          "exists",
+			value:       true,
+			result:      &Result{StatusCode: 200, Body: []byte("<html>")},
+			want:        false,
+			wantMessage: "response body isn't valid json",
+		},
+		{
+			name:        "discarded body",
+			field:       "body",
+			op:          "contains",
+			value:       "x",
+			result:      &Result{StatusCode: 200, BodyDiscarded: true},
+			want:        false,
+			wantMessage: "body can't be checked, the response body was discarded",
+		},
+		{
+			name:        "truncated body",
+			field:       "json.id",
+			op:          "exists",
+			value:       false,
+			result:      &Result{StatusCode: 200, Body: []byte(`{"na`), BodyTruncated: true},
+			want:        false,
+			wantMessage: "cut short at max_body_size",
+		},
+		{
+			name:   "status with a discarded body",
+			field:  "status_code",
+			op:     "=",
+			value:  200,
+			result: &Result{StatusCode: 200, BodyDiscarded: true},
+			want:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			op, err := ParseOperator(tt.op)
+			if err != nil {
+				t.Fatal(err)
+			}
+			a, err := Assertion{Field: tt.field, Operator: op, Value: tt.value}.Compile()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			r := response
+			if tt.result != nil {
+				r = *tt.result
+			}
+			r.Request = Request{Assert: []Assertion{a}}
+			r.CheckAssertions()
+
+			got := r.Assertions[0]
+			if got.Result != tt.want {
+				t.Errorf("Result = %v, want %v (%s)", got.Result, tt.want, got.Message)
+			}
+			if tt.want && got.Message != "" {
+				t.Errorf("Message = %q, want none for an assertion that passed", got.Message)
+			}
+			if !strings.Contains(got.Message, tt.wantMessage) {
+				t.Errorf("Message = %q, want it to contain %q", got.Message, tt.wantMessage)
+			}
+		})
+	}
+}
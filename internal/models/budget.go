@@ -0,0 +1,36 @@
+package models
+
+// Budget limits how far a candidate can move from the baseline when comparing results
+type Budget struct {
+	Collection string // empty for every collection
+	Request    string // empty for every request, including "total", the collection as a whole
+	Limits     []Limit
+}
+
+// Limit is the budget for one metric
+type Limit struct {
+	Metric string
+	// Relative limits are a percent change from the baseline, e.g. +10 means p95 can grow by
+	// up to 10%, and -5 means throughput can drop by up to 5%. Other limits are the worst the
+	// value itself can be, in the metric's unit: nanoseconds for latencies, percent for the
+	// error rate, and requests per second for throughput
+	Relative bool
+	Value    float64
+	Raw      string // as it was written, for messages
+}
+
+// BudgetMetric says whether higher is better for metric, and whether a budget can limit it at all
+func BudgetMetric(metric string) (bool, bool) {
+	switch metric {
+	case "throughput":
+		return true, true
+	case "error_rate", "mean", "p50", "p90", "p95", "p99", "p99_9":
+		return false, true
+	}
+	return false, false
+}
+
+// Matches is true if the budget applies to the request in the collection
+func (b Budget) Matches(collection, request string) bool {
+	return (b.Collection == "" || b.Collection == collection) && (b.Request == "" || b.Request == request)
+}
@@ -1,10 +1,22 @@
 package models
 
-import "sync"
+import (
+	"sync"
+	"time"
+)
 
 type Collection struct {
 	Name     string
+	BaseUrl  string
 	Requests []Request
+	Data     *DataSet // rows that parameterise each run, if there are any
 	Mu       *sync.Mutex
 	Runs     []Run
+
+	// filled in by the runner as runs come in
+	Aggregates *Aggregates
+	Start      time.Time
+	End        time.Time
+	Dropped    int // runs that were due at a rate, but every worker was busy
+	Late       int // runs that started well after they were due
 }
@@ -1,7 +1,22 @@
 package models
 
+import "time"
+
 type Config struct {
-	Runs       int
-	Concurrent int
-	Async      bool
+	Runs        int
+	Concurrent  int
+	Async       bool
+	MaxInFlight int           // per async worker, 0 means no limit
+	Duration    time.Duration // if set, overrides Runs
+	Timeout     time.Duration // per request, 0 means no timeout
+	MaxBodySize int64         // bytes of each response body to keep, 0 means keep it all
+	DiscardBody bool          // don't keep response bodies at all, for pure load tests
+	KeepFailed  bool          // keep the runs that failed. Every run is aggregated either way
+	KeepAll     bool          // keep every run, which takes a lot of memory on a long benchmark
+	Ramp        []Stage       // if set, how many workers are busy (or with a rate, the rate) over the course of each collection
+	Rate        float64       // if set, runs are started this many times a second, however long they take
+	MaxWorkers  int           // with a rate, how many workers there can be. Concurrent are started up front
+	// if set, latencies are also reported corrected for coordinated omission,
+	// taking each worker to have been meant to send a request this often
+	ExpectedInterval time.Duration
 }
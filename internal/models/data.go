@@ -0,0 +1,16 @@
+package models
+
+const (
+	DataSequential  = "sequential"
+	DataRandom      = "random"
+	DataPartitioned = "partitioned"
+)
+
+// DataSet is a table of values, read from a csv or jsonl file. Each run of the
+// collection takes a row, and its columns become variables for the requests
+type DataSet struct {
+	File string
+	Rows []map[string]string
+	Mode string // sequential, random or partitioned (each worker gets its own share of the rows)
+	Wrap bool   // start again once every row has been used, otherwise stop the runs
+}
@@ -0,0 +1,127 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/jonny-burkholder/swarm/internal/jsonpath"
+)
+
+const (
+	ExtractJSON   = "json"
+	ExtractHeader = "header"
+	ExtractRegex  = "regex"
+)
+
+// Extraction pulls a value out of a response into a variable, so
+// that later requests in the same run can use it
+type Extraction struct {
+	Name   string
+	Source string // json, header or regex
+	Expr   string // the path, header name or regular expression
+	regex  *regexp.Regexp
+}
+
+// NewExtraction checks the expression makes sense for the source, e.g.
+// that a regular expression compiles, and returns the extraction
+func NewExtraction(name, source, expr string) (Extraction, error) {
+	e := Extraction{
+		Name:   name,
+		Source: source,
+		Expr:   expr,
+	}
+
+	switch source {
+	case ExtractJSON:
+		if _, err := jsonpath.Parse(expr); err != nil {
+			return e, err
+		}
+	case ExtractHeader:
+		if expr == "" {
+			return e, fmt.Errorf("%s: header name is empty", name)
+		}
+	case ExtractRegex:
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return e, fmt.Errorf("%s: invalid regular expression: %w", name, err)
+		}
+		e.regex = re
+	default:
+		return e, fmt.Errorf("%s: unknown source '%s', must be one of: json, header, regex", name, source)
+	}
+
+	return e, nil
+}
+
+// ExtractVariables runs every extraction on the request against the response, and
+// stores the values it finds in r.Extracted. Values that can't be found
+// are left out, so that anything that uses them fails loudly
+func (r *Result) ExtractVariables() {
+	if len(r.Request.Extract) == 0 {
+		return
+	}
+
+	r.Extracted = make(map[string]string, len(r.Request.Extract))
+
+	var doc any
+	var docErr error
+	decoded := false
+
+	for _, e := range r.Request.Extract {
+		switch e.Source {
+		case ExtractJSON:
+			if !decoded {
+				doc, docErr = decodeJSON(r.Body)
+				decoded = true
+			}
+			if docErr != nil {
+				continue
+			}
+			v, found, err := jsonpath.Get(doc, e.Expr)
+			if err != nil || !found {
+				continue
+			}
+			r.Extracted[e.Name] = Stringify(v)
+		case ExtractHeader:
+			if v := http.Header(r.Headers).Get(e.Expr); v != "" {
+				r.Extracted[e.Name] = v
+			}
+		case ExtractRegex:
+			re := e.regex
+			if re == nil {
+				// the extraction wasn't made with NewExtraction. A bad pattern is
+				// left out like a value that isn't there, rather than panicking
+				var err error
+				if re, err = regexp.Compile(e.Expr); err != nil {
+					continue
+				}
+			}
+			// use the first capture group if there is one, otherwise the whole match
+			m := re.FindSubmatch(r.Body)
+			if m == nil {
+				continue
+			}
+			r.Extracted[e.Name] = string(m[min(1, len(m)-1)])
+		}
+	}
+}
+
+// Stringify turns a json value into something that can be put in a template.
+// Objects and arrays stay as json
+func Stringify(v any) string {
+	switch val := v.(type) {
+	case string:
+		return val
+	case json.Number:
+		return val.String()
+	case nil:
+		return ""
+	case map[string]any, []any:
+		b, _ := json.Marshal(val)
+		return string(b)
+	}
+	return strings.TrimSpace(fmt.Sprint(v))
+}
@@ -0,0 +1,123 @@
+package models
+
+import (
+	"maps"
+	"testing"
+)
+
+func TestNewExtraction(t *testing.T) {
+	tests := []struct {
+		name    string
+		source  string
+		expr    string
+		wantErr bool
+	}{
+		{name: "json", source: ExtractJSON, expr: "data.id"},
+		{name: "header", source: ExtractHeader, expr: "Location"},
+		{name: "regex", source: ExtractRegex, expr: `id=(\d+)`},
+		{name: "empty header", source: ExtractHeader, expr: "", wantErr: true},
+		{name: "bad regex", source: ExtractRegex, expr: `id=(\d+`, wantErr: true},
+		{name: "unknown source", source: "cookie", expr: "session", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewExtraction("v", tt.source, tt.expr); (err != nil) != tt.wantErr {
+				t.Errorf("NewExtraction(%s, %s) error = %v, want error %v", tt.source, tt.expr, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestExtractVariables(t *testing.T) {
+	extraction := func(source, expr string) Extraction {
+		e, err := NewExtraction("v", source, expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return e
+	}
+
+	tests := []struct {
+		name       string
+		extraction Extraction
+		body       string
+		headers    map[string][]string
+		want       map[string]string
+	}{
+		{
+			name:       "json string",
+			extraction: extraction(ExtractJSON, "data.token"),
+			body:       `{"data":{"token":"abc"}}`,
+			want:       map[string]string{"v": "abc"},
+		},
+		{
+			name:       "json number keeps its digits",
+			extraction: extraction(ExtractJSON, "id"),
+			body:       `{"id":12345678901234567890}`,
+			want:       map[string]string{"v": "12345678901234567890"},
+		},
+		{
+			name:       "json object stays json",
+			extraction: extraction(ExtractJSON, "user"),
+			body:       `{"user":{"id":1}}`,
+			want:       map[string]string{"v": `{"id":1}`},
+		},
+		{
+			name:       "json that isn't there",
+			extraction: extraction(ExtractJSON, "missing"),
+			body:       `{"id":1}`,
+			want:       map[string]string{},
+		},
+		{
+			name:       "body that isn't json",
+			extraction: extraction(ExtractJSON, "id"),
+			body:       `<html>`,
+			want:       map[string]string{},
+		},
+		{
+			name:       "header",
+			extraction: extraction(ExtractHeader, "location"),
+			headers:    map[string][]string{"Location": {"/books/7"}},
+			want:       map[string]string{"v": "/books/7"},
+		},
+		{
+			name:       "regex capture group",
+			extraction: extraction(ExtractRegex, `id=(\d+)`),
+			body:       `ok id=42 done`,
+			want:       map[string]string{"v": "42"},
+		},
+		{
+			name:       "regex whole match",
+			extraction: extraction(ExtractRegex, `\d+`),
+			body:       `ok id=42 done`,
+			want:       map[string]string{"v": "42"},
+		},
+		{
+			name:       "regex not made with NewExtraction",
+			extraction: Extraction{Name: "v", Source: ExtractRegex, Expr: `id=(\d+)`},
+			body:       `id=7`,
+			want:       map[string]string{"v": "7"},
+		},
+		{
+			name:       "bad regex not made with NewExtraction",
+			extraction: Extraction{Name: "v", Source: ExtractRegex, Expr: `id=(\d+`},
+			body:       `id=7`,
+			want:       map[string]string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := Result{
+				Request: Request{Extract: []Extraction{tt.extraction}},
+				Body:    []byte(tt.body),
+				Headers: tt.headers,
+			}
+			r.ExtractVariables()
+			if !maps.Equal(r.Extracted, tt.want) {
+				t.Errorf("Extracted = %v, want %v", r.Extracted, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+const (
+	RampConstant    = "constant"    // jump straight to the target and hold it
+	RampLinear      = "linear"      // move towards the target at a steady pace
+	RampStep        = "step"        // climb to the target in equal steps
+	RampExponential = "exponential" // start slow and finish fast
+	RampLogarithmic = "logarithmic" // start fast and finish slow
+)
+
+// Stage is one part of a ramp. Over Duration the load moves from wherever the
+// stage before left it (or 0 for the first stage) to Target, following Shape
+type Stage struct {
+	Target   float64 // workers, or requests per second
+	Duration time.Duration
+	Shape    string
+	Steps    int // for step, how many steps to take. 0 means the default
+}
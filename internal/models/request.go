@@ -1,6 +1,7 @@
 package models
 
 type Request struct {
+	Name        string
 	Method      string
 	Path        string
 	Auth        Auth
@@ -8,4 +9,38 @@ type Request struct {
 	QueryParams map[string][]string // to be parsed if collection is http
 	Body        []byte
 	Assert      []Assertion
+	Schema      Schema       // the response body must satisfy this, if it's set
+	Extract     []Extraction // values to pull out of the response for later requests
+}
+
+// NeedsBody is true if the response body has to be kept for the request's
+// assertions, schema or extractions to be checked
+func (r Request) NeedsBody() bool {
+	if r.Schema != nil {
+		return true
+	}
+	for _, a := range r.Assert {
+		if a.OnBody() {
+			return true
+		}
+	}
+	for _, e := range r.Extract {
+		if e.Source == ExtractJSON || e.Source == ExtractRegex {
+			return true
+		}
+	}
+	return false
+}
+
+// Schema checks a json response body against a contract
+type Schema interface {
+	// Validate returns every violation in doc, which is
+	// decoded json with numbers as json.Number
+	Validate(doc any) []SchemaViolation
+}
+
+// SchemaViolation is one way in which a response broke the schema
+type SchemaViolation struct {
+	Path    string // json pointer to the offending value, e.g. /items/1/id
+	Message string
 }
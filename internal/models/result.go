@@ -4,10 +4,43 @@ import "time"
 
 type Result struct {
 	Request
-	StatusCode int
-	Body       []byte
-	Headers    map[string][]string
-	Duration   time.Duration
-	Assertions []Assertion
-	Error      error
+	Raw           Request // the request before variables were filled in
+	StatusCode    int
+	Body          []byte
+	BodyTruncated bool  // the body was bigger than the max capture size
+	BodyDiscarded bool  // the body was only counted, not kept
+	BytesReceived int64 // size of the whole response body, even if it wasn't all kept
+	Headers       map[string][]string
+	Duration      time.Duration // from just before the request is sent until the body has been read
+	Wait          time.Duration // how late the request's run started, when runs are started at a rate
+	Timing        Timing
+	Assertions    []Assertion
+	Violations    []SchemaViolation // how the body broke the request's schema, if it has one
+	Extracted     map[string]string // variables pulled out of the response
+	Error         error
+}
+
+// Timing breaks the duration of a request down into its phases. Phases that didn't
+// happen, like DNS and connecting when a connection was reused, are left at 0
+type Timing struct {
+	DNS        time.Duration
+	Connect    time.Duration // tcp connect
+	TLS        time.Duration
+	TTFB       time.Duration // from when the request was written until the first byte of the response
+	Transfer   time.Duration // from the first byte of the response until the body has been read
+	ConnReused bool
+}
+
+// Passed is true if the request was sent without error, every assertion
+// held, and the body satisfied the schema
+func (r Result) Passed() bool {
+	if r.Error != nil {
+		return false
+	}
+	for _, a := range r.Assertions {
+		if !a.Result {
+			return false
+		}
+	}
+	return len(r.Violations) == 0
 }
@@ -1,7 +1,23 @@
 package models
 
+import "time"
+
 type Run struct {
-	ID      int
-	Results []Result
-	Error   error
+	ID        int
+	Scheduled time.Time // when the run was meant to start, if runs are started at a rate
+	Results   []Result
+	Error     error
+}
+
+// Passed is true if the run finished and every result in it passed
+func (r Run) Passed() bool {
+	if r.Error != nil {
+		return false
+	}
+	for _, result := range r.Results {
+		if !result.Passed() {
+			return false
+		}
+	}
+	return true
 }
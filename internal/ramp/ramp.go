@@ -0,0 +1,145 @@
+// Package ramp is for calculating how load swells over a run.
+// For instance, you may want to scale linearly to get a
+// precise idea of where an applications breaks. Or, you
+// may be trying to break as quickly as possible, and want
+// a logarithmic function
+package ramp
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jonny-burkholder/swarm/internal/models"
+)
+
+// defaultSteps is how many steps a step stage takes, if it doesn't say
+const defaultSteps = 5
+
+// curve is how sharply exponential and logarithmic stages bend. An exponential
+// stage doubles how far it's come every 1/curve of the way through
+const curve = 5
+
+// Profile is how much load there should be at each point in a run. It's made up of
+// stages, each moving from where the one before left off to its own target
+type Profile struct {
+	Start float64 // where the first stage starts from
+
+	stages []models.Stage
+	starts []time.Duration // when each stage starts
+	total  time.Duration
+	peak   float64
+}
+
+// NewProfile creates a profile from stages
+func NewProfile(stages []models.Stage) (*Profile, error) {
+	if len(stages) == 0 {
+		return nil, fmt.Errorf("a ramp needs at least one stage")
+	}
+
+	p := &Profile{stages: stages, starts: make([]time.Duration, len(stages))}
+	for i, stage := range stages {
+		switch stage.Shape {
+		case models.RampConstant, models.RampLinear, models.RampStep, models.RampExponential, models.RampLogarithmic:
+		default:
+			return nil, fmt.Errorf("stage %d: unknown shape '%s', must be one of: constant, linear, step, exponential, logarithmic", i+1, stage.Shape)
+		}
+		if stage.Target < 0 || stage.Duration < 0 || stage.Steps < 0 {
+			return nil, fmt.Errorf("stage %d: target, duration and steps can't be negative", i+1)
+		}
+		p.starts[i] = p.total
+		p.total += stage.Duration
+		p.peak = max(p.peak, stage.Target)
+	}
+	if p.total <= 0 {
+		return nil, fmt.Errorf("a ramp needs to last longer than 0s")
+	}
+
+	return p, nil
+}
+
+// At is how much load there should be once elapsed has passed. After the last stage the
+// load stays at its target
+func (p *Profile) At(elapsed time.Duration) float64 {
+	from := p.Start
+	for i, stage := range p.stages {
+		end := p.starts[i] + stage.Duration
+		if elapsed >= end {
+			from = stage.Target
+			continue
+		}
+		progress := float64(elapsed-p.starts[i]) / float64(stage.Duration)
+		return from + (stage.Target-from)*shape(stage, max(progress, 0))
+	}
+	return from
+}
+
+// Duration is how long it takes to get through every stage
+func (p *Profile) Duration() time.Duration {
+	return p.total
+}
+
+// Peak is the most load there will ever be
+func (p *Profile) Peak() float64 {
+	return max(p.peak, p.Start)
+}
+
+// shape is how far through moving to its target a stage is, from 0 to 1,
+// given how far through its duration it is
+func shape(stage models.Stage, progress float64) float64 {
+	switch stage.Shape {
+	case models.RampConstant:
+		return 1
+	case models.RampStep:
+		// each step is held for an equal share of the stage, starting with the first
+		steps := stage.Steps
+		if steps <= 0 {
+			steps = defaultSteps
+		}
+		return min(math.Floor(progress*float64(steps))+1, float64(steps)) / float64(steps)
+	case models.RampExponential:
+		return (math.Exp2(curve*progress) - 1) / (math.Exp2(curve) - 1)
+	case models.RampLogarithmic:
+		// exponential, flipped around
+		return 1 - (math.Exp2(curve*(1-progress))-1)/(math.Exp2(curve)-1)
+	default:
+		return progress
+	}
+}
+
+// ParseStages parses stages from the command line, like "50:1m,50:5m:constant,0:30s".
+// Each is a target and a duration, and optionally a shape. Stages without one get shape
+func ParseStages(s string, shape string, steps int) ([]models.Stage, error) {
+	var stages []models.Stage
+	for raw := range strings.SplitSeq(s, ",") {
+		raw = strings.TrimSpace(raw)
+		parts := strings.Split(raw, ":")
+		if len(parts) < 2 || len(parts) > 3 {
+			return nil, fmt.Errorf("invalid stage '%s', expected target:duration or target:duration:shape", raw)
+		}
+
+		target, err := strconv.ParseFloat(parts[0], 64)
+		if err != nil || target < 0 {
+			return nil, fmt.Errorf("invalid stage '%s', the target must be a number that isn't negative", raw)
+		}
+		d, err := time.ParseDuration(parts[1])
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("invalid stage '%s', expected a duration like 30s or 5m", raw)
+		}
+
+		stage := models.Stage{Target: target, Duration: d, Shape: shape, Steps: steps}
+		if len(parts) == 3 {
+			stage.Shape = parts[2]
+		}
+		stages = append(stages, stage)
+	}
+
+	// make sure the shapes are real
+	if _, err := NewProfile(stages); err != nil {
+		return nil, err
+	}
+
+	return stages, nil
+}
@@ -0,0 +1,187 @@
+package ramp
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/jonny-burkholder/swarm/internal/models"
+)
+
+func TestProfileAt(t *testing.T) {
+	s := time.Second
+
+	tests := []struct {
+		name   string
+		start  float64
+		stages []models.Stage
+		at     map[time.Duration]float64
+	}{
+		{
+			name:   "linear",
+			stages: []models.Stage{{Target: 100, Duration: 10 * s, Shape: models.RampLinear}},
+			at:     map[time.Duration]float64{0: 0, 5 * s: 50, 10 * s: 100, 20 * s: 100},
+		},
+		{
+			name:   "linear from start",
+			start:  10,
+			stages: []models.Stage{{Target: 100, Duration: 10 * s, Shape: models.RampLinear}},
+			at:     map[time.Duration]float64{0: 10, 5 * s: 55},
+		},
+		{
+			name:   "constant",
+			stages: []models.Stage{{Target: 50, Duration: 10 * s, Shape: models.RampConstant}},
+			at:     map[time.Duration]float64{0: 50, 9 * s: 50},
+		},
+		{
+			name:   "step",
+			stages: []models.Stage{{Target: 100, Duration: 10 * s, Shape: models.RampStep, Steps: 4}},
+			at:     map[time.Duration]float64{0: 25, 2400 * time.Millisecond: 25, 2500 * time.Millisecond: 50, 9900 * time.Millisecond: 100},
+		},
+		{
+			name:   "step with the default steps",
+			stages: []models.Stage{{Target: 100, Duration: 10 * s, Shape: models.RampStep}},
+			at:     map[time.Duration]float64{0: 20, 5 * s: 60},
+		},
+		{
+			name:   "exponential",
+			stages: []models.Stage{{Target: 31, Duration: 10 * s, Shape: models.RampExponential}},
+			at:     map[time.Duration]float64{0: 0, 2 * s: 1, 4 * s: 3, 10 * s: 31},
+		},
+		{
+			name:   "logarithmic",
+			stages: []models.Stage{{Target: 31, Duration: 10 * s, Shape: models.RampLogarithmic}},
+			at:     map[time.Duration]float64{0: 0, 6 * s: 28, 8 * s: 30, 10 * s: 31},
+		},
+		{
+			name: "stages follow on from each other",
+			stages: []models.Stage{
+				{Target: 100, Duration: 10 * s, Shape: models.RampLinear},
+				{Target: 100, Duration: 10 * s, Shape: models.RampConstant},
+				{Target: 0, Duration: 10 * s, Shape: models.RampLinear},
+			},
+			at: map[time.Duration]float64{5 * s: 50, 15 * s: 100, 25 * s: 50, 40 * s: 0},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := NewProfile(tt.stages)
+			if err != nil {
+				t.Fatalf("NewProfile() error = %v", err)
+			}
+			p.Start = tt.start
+			for elapsed, want := range tt.at {
+				if got := p.At(elapsed); math.Abs(got-want) > 1e-9 {
+					t.Errorf("At(%v) = %v, want %v", elapsed, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestNewProfile(t *testing.T) {
+	tests := []struct {
+		name         string
+		stages       []models.Stage
+		wantErr      bool
+		wantDuration time.Duration
+		wantPeak     float64
+	}{
+		{
+			name:    "no stages",
+			wantErr: true,
+		},
+		{
+			name:    "unknown shape",
+			stages:  []models.Stage{{Target: 10, Duration: time.Second, Shape: "wobbly"}},
+			wantErr: true,
+		},
+		{
+			name:    "negative target",
+			stages:  []models.Stage{{Target: -1, Duration: time.Second, Shape: models.RampLinear}},
+			wantErr: true,
+		},
+		{
+			name:    "no time at all",
+			stages:  []models.Stage{{Target: 10, Shape: models.RampLinear}},
+			wantErr: true,
+		},
+		{
+			name: "valid",
+			stages: []models.Stage{
+				{Target: 80, Duration: time.Minute, Shape: models.RampLinear},
+				{Target: 20, Duration: 30 * time.Second, Shape: models.RampStep},
+			},
+			wantDuration: 90 * time.Second,
+			wantPeak:     80,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := NewProfile(tt.stages)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewProfile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if p.Duration() != tt.wantDuration {
+				t.Errorf("Duration() = %v, want %v", p.Duration(), tt.wantDuration)
+			}
+			if p.Peak() != tt.wantPeak {
+				t.Errorf("Peak() = %v, want %v", p.Peak(), tt.wantPeak)
+			}
+		})
+	}
+}
+
+func TestParseStages(t *testing.T) {
+	tests := []struct {
+		name    string
+		s       string
+		shape   string
+		steps   int
+		want    []models.Stage
+		wantErr bool
+	}{
+		{
+			name:  "one stage",
+			s:     "50:1m",
+			shape: models.RampLinear,
+			want:  []models.Stage{{Target: 50, Duration: time.Minute, Shape: models.RampLinear}},
+		},
+		{
+			name:  "shapes and spaces",
+			s:     "50:1m, 50:5m:constant ,0:30s",
+			shape: models.RampStep,
+			steps: 3,
+			want: []models.Stage{
+				{Target: 50, Duration: time.Minute, Shape: models.RampStep, Steps: 3},
+				{Target: 50, Duration: 5 * time.Minute, Shape: models.RampConstant, Steps: 3},
+				{Target: 0, Duration: 30 * time.Second, Shape: models.RampStep, Steps: 3},
+			},
+		},
+		{name: "no duration", s: "50", shape: models.RampLinear, wantErr: true},
+		{name: "too many parts", s: "50:1m:linear:x", shape: models.RampLinear, wantErr: true},
+		{name: "bad target", s: "lots:1m", shape: models.RampLinear, wantErr: true},
+		{name: "negative target", s: "-5:1m", shape: models.RampLinear, wantErr: true},
+		{name: "bad duration", s: "50:soon", shape: models.RampLinear, wantErr: true},
+		{name: "bad shape", s: "50:1m:wobbly", shape: models.RampLinear, wantErr: true},
+		{name: "bad default shape", s: "50:1m", shape: "wobbly", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseStages(tt.s, tt.shape, tt.steps)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseStages(%q) error = %v, wantErr %v", tt.s, err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseStages(%q) = %+v, want %+v", tt.s, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package results
+
+import (
+	"time"
+
+	"github.com/jonny-burkholder/swarm/internal/histogram"
+	"github.com/jonny-burkholder/swarm/internal/models"
+)
+
+// Aggregates summarise a collection's runs, for each request and for the collection as a whole
+type Aggregates struct {
+	Runs       int         `json:"runs"`
+	Incomplete int         `json:"incomplete"` // runs that were cut short
+	Total      Aggregate   `json:"total"`
+	Requests   []Aggregate `json:"requests"` // in the same order as the collection's requests
+}
+
+// Aggregate is a models.Aggregate with the numbers worked out. The histogram is kept
+// too, so that aggregates can be merged, or other percentiles found, later on
+type Aggregate struct {
+	Name   string `json:"name"`
+	Count  int    `json:"count"`
+	Errors int    `json:"errors"` // requests that couldn't be sent, or got no response
+	Failed int    `json:"failed"` // requests with a failed assertion or schema violation
+	// requests that errored, failed, or got a 5xx. Files saved before this was kept don't have it
+	Unsuccessful int         `json:"unsuccessful"`
+	Bytes        int64       `json:"bytes"`
+	StatusCodes  map[int]int `json:"status_codes"`
+	Throughput   float64     `json:"throughput"` // requests per second
+	Latencies
+	// why requests failed, keyed by the assertion, the part of the schema, or "request" for errors
+	Failures map[string]Failure `json:"failures,omitempty"`
+
+	// latencies from when each request was due to be sent, corrected for coordinated
+	// omission. Only there when runs were started at a rate, or an expected interval was set
+	Corrected *Latencies `json:"corrected,omitempty"`
+}
+
+type Latencies struct {
+	Min       time.Duration        `json:"min_ns"`
+	Mean      time.Duration        `json:"mean_ns"`
+	Max       time.Duration        `json:"max_ns"`
+	StdDev    time.Duration        `json:"stddev_ns"`
+	P50       time.Duration        `json:"p50_ns"`
+	P90       time.Duration        `json:"p90_ns"`
+	P95       time.Duration        `json:"p95_ns"`
+	P99       time.Duration        `json:"p99_ns"`
+	P999      time.Duration        `json:"p99_9_ns"`
+	Histogram *histogram.Histogram `json:"histogram"`
+}
+
+// NewLatencies works out the percentiles and so on of h
+func NewLatencies(h *histogram.Histogram) Latencies {
+	if h == nil {
+		h = histogram.New()
+	}
+	return Latencies{
+		Min:       time.Duration(h.Min()),
+		Mean:      time.Duration(h.Mean()),
+		Max:       time.Duration(h.Max()),
+		StdDev:    time.Duration(h.StdDev()),
+		P50:       time.Duration(h.Quantile(0.5)),
+		P90:       time.Duration(h.Quantile(0.9)),
+		P95:       time.Duration(h.Quantile(0.95)),
+		P99:       time.Duration(h.Quantile(0.99)),
+		P999:      time.Duration(h.Quantile(0.999)),
+		Histogram: h,
+	}
+}
+
+type Failure struct {
+	Count   int    `json:"count"`
+	Example string `json:"example"`
+}
+
+// NewAggregate works out the numbers for a. elapsed is how long the runs
+// took, which is what throughput is measured over. interval is the expected
+// interval to correct for coordinated omission with, if there is one
+func NewAggregate(a models.Aggregate, elapsed, interval time.Duration) Aggregate {
+	agg := Aggregate{
+		Name:         a.Name,
+		Count:        a.Count,
+		Errors:       a.Errors,
+		Failed:       a.Failed,
+		Unsuccessful: a.Unsuccessful,
+		Bytes:        a.Bytes,
+		StatusCodes:  a.StatusCodes,
+		Latencies:    NewLatencies(a.Latency),
+	}
+	if a.Intended != nil || interval > 0 {
+		corrected := NewLatencies(a.Corrected(interval))
+		agg.Corrected = &corrected
+	}
+	if elapsed > 0 {
+		agg.Throughput = float64(a.Count) / elapsed.Seconds()
+	}
+	if agg.StatusCodes == nil {
+		agg.StatusCodes = map[int]int{}
+	}
+	for key, f := range a.Failures {
+		if agg.Failures == nil {
+			agg.Failures = map[string]Failure{}
+		}
+		agg.Failures[key] = Failure{Count: f.Count, Example: truncate(f.Example, maxMessage)}
+	}
+
+	return agg
+}
+
+// aggregates works out the numbers for a collection. If the runner didn't
+// keep aggregates as it went, they're worked out from the runs
+func aggregates(collection *models.Collection, interval time.Duration) Aggregates {
+	aggs := collection.Aggregates
+	if aggs == nil {
+		aggs = models.NewAggregates(collection.Requests)
+		for _, run := range collection.Runs {
+			aggs.Add(run)
+		}
+	}
+
+	elapsed := collection.End.Sub(collection.Start)
+	res := Aggregates{
+		Runs:       aggs.Runs,
+		Incomplete: aggs.Incomplete,
+		Total:      NewAggregate(aggs.Total, elapsed, interval),
+		Requests:   make([]Aggregate, len(aggs.Requests)),
+	}
+	for i, a := range aggs.Requests {
+		res.Requests[i] = NewAggregate(a, elapsed, interval)
+	}
+
+	return res
+}
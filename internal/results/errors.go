@@ -0,0 +1,16 @@
+package results
+
+import "fmt"
+
+// VersionError means a results file was written in a format this version of swarm can't read
+type VersionError struct {
+	File    string
+	Version int
+}
+
+func (e VersionError) Error() string {
+	if e.Version == 0 {
+		return fmt.Sprintf("%s: not a swarm results file", e.File)
+	}
+	return fmt.Sprintf("%s: results format version %d isn't supported, expected at most %d", e.File, e.Version, FormatVersion)
+}
@@ -0,0 +1,179 @@
+package results
+
+import (
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/jonny-burkholder/swarm/internal/models"
+)
+
+// Run is a models.Run as it's saved. Errors become strings, and things that either
+// can't be saved or shouldn't be, like bodies and credentials, are left out. Extracted
+// values are often tokens, so only their names are kept, and what body and json assertions
+// found is left out too, as is what header assertions found in headers that can hold a
+// secret. Failure messages are cut short, but can still quote the response
+type Run struct {
+	ID      int      `json:"id"`
+	Error   string   `json:"error,omitempty"`
+	Results []Result `json:"results"`
+}
+
+type Result struct {
+	Name          string            `json:"name"`
+	Method        string            `json:"method"`
+	Path          string            `json:"path"` // before variables were filled in, since they can be tokens
+	StatusCode    int               `json:"status_code"`
+	BytesReceived int64             `json:"bytes_received"`
+	BodyTruncated bool              `json:"body_truncated,omitempty"`
+	Duration      time.Duration     `json:"duration_ns"`
+	Wait          time.Duration     `json:"wait_ns,omitempty"` // how late its run started, with a rate
+	Timing        Timing            `json:"timing"`
+	Assertions    []Assertion       `json:"assertions,omitempty"`
+	Violations    []Violation       `json:"violations,omitempty"`
+	Extracted     map[string]string `json:"extracted,omitempty"` // values are always Redacted
+	Error         string            `json:"error,omitempty"`
+}
+
+type Timing struct {
+	DNS        time.Duration `json:"dns_ns"`
+	Connect    time.Duration `json:"connect_ns"`
+	TLS        time.Duration `json:"tls_ns"`
+	TTFB       time.Duration `json:"ttfb_ns"`
+	Transfer   time.Duration `json:"transfer_ns"`
+	ConnReused bool          `json:"conn_reused"`
+}
+
+type Assertion struct {
+	Field    string `json:"field"`
+	Operator string `json:"operator"`
+	Expected any    `json:"expected,omitempty"`
+	Actual   any    `json:"actual,omitempty"`
+	Passed   bool   `json:"passed"`
+	Message  string `json:"message,omitempty"`
+}
+
+type Violation struct {
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+// Passed is true if the request was sent without error, every assertion
+// held, and the body satisfied the schema
+func (r Result) Passed() bool {
+	if r.Error != "" {
+		return false
+	}
+	for _, a := range r.Assertions {
+		if !a.Passed {
+			return false
+		}
+	}
+	return len(r.Violations) == 0
+}
+
+// NewRun converts a run into the form it's saved in
+func NewRun(run models.Run) Run {
+	r := Run{
+		ID:      run.ID,
+		Error:   errString(run.Error),
+		Results: make([]Result, len(run.Results)),
+	}
+	for i, result := range run.Results {
+		r.Results[i] = newResult(result)
+	}
+	return r
+}
+
+func newResult(result models.Result) Result {
+	r := Result{
+		Name:          result.Name,
+		Method:        result.Method,
+		Path:          result.Raw.Path,
+		StatusCode:    result.StatusCode,
+		BytesReceived: result.BytesReceived,
+		BodyTruncated: result.BodyTruncated,
+		Duration:      result.Duration,
+		Wait:          result.Wait,
+		Timing: Timing{
+			DNS:        result.Timing.DNS,
+			Connect:    result.Timing.Connect,
+			TLS:        result.Timing.TLS,
+			TTFB:       result.Timing.TTFB,
+			Transfer:   result.Timing.Transfer,
+			ConnReused: result.Timing.ConnReused,
+		},
+		Error: errString(result.Error),
+	}
+	for name := range result.Extracted {
+		if r.Extracted == nil {
+			r.Extracted = map[string]string{}
+		}
+		r.Extracted[name] = Redacted
+	}
+	for _, a := range result.Assertions {
+		saved := Assertion{
+			Field:    a.Field,
+			Operator: models.Operator(a.Operator),
+			Expected: a.Value,
+			Actual:   a.Actual,
+			Passed:   a.Result,
+			Message:  truncate(a.Message, maxMessage),
+		}
+		if a.OnBody() {
+			saved.Actual = nil
+		}
+		if header, ok := a.OnHeader(); ok && secretHeader(result, header) {
+			saved.Actual = Redacted
+			if saved.Message != "" {
+				saved.Message = Redacted
+			}
+		}
+		r.Assertions = append(r.Assertions, saved)
+	}
+	for _, v := range result.Violations {
+		r.Violations = append(r.Violations, Violation{Path: v.Path, Message: v.Message})
+	}
+	return r
+}
+
+// secretHeader is true if the response header can hold a secret, because it's extracted into a
+// variable or because the request sent it with variables filled in and it might have been echoed
+func secretHeader(result models.Result, header string) bool {
+	for _, e := range result.Extract {
+		if e.Source == models.ExtractHeader && strings.EqualFold(e.Expr, header) {
+			return true
+		}
+	}
+	for name, value := range result.Request.Headers {
+		if strings.EqualFold(name, header) && value != result.Raw.Headers[name] {
+			return true
+		}
+	}
+	return false
+}
+
+// Redacted stands in for values that are kept out of results files
+const Redacted = "[redacted]"
+
+// maxMessage is how long an assertion's message can be once it's saved. Messages
+// say what was found, which for a body assertion is the whole body
+const maxMessage = 256
+
+// truncate cuts s down to at most n bytes, without splitting a character
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n] + "..."
+}
+
+func errString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
@@ -0,0 +1,88 @@
+package results
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jonny-burkholder/swarm/internal/models"
+)
+
+func TestNewRunRedacts(t *testing.T) {
+	run := NewRun(models.Run{ID: 1, Results: []models.Result{{
+		Request:    models.Request{Name: "POST login"},
+		StatusCode: 200,
+		Body:       []byte(`{"token": "secret"}`),
+		Extracted:  map[string]string{"token": "secret"},
+		Assertions: []models.Assertion{
+			{Field: "status_code", Value: 200, Actual: 200, Result: true},
+			{Field: "body", Value: "ok", Actual: `{"token": "secret"}`, Result: false},
+			{Field: "json.token", Value: "", Actual: "secret", Result: false},
+		},
+	}}})
+
+	result := run.Results[0]
+	if got := result.Extracted["token"]; got != Redacted {
+		t.Errorf("Extracted[token] = %q, want %q", got, Redacted)
+	}
+	want := []any{200, nil, nil}
+	for i, a := range result.Assertions {
+		if a.Actual != want[i] {
+			t.Errorf("%s assertion Actual = %v, want %v", a.Field, a.Actual, want[i])
+		}
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		name string
+		s    string
+		n    int
+		want string
+	}{
+		{
+			name: "short enough",
+			s:    "expected 200, got 500",
+			n:    32,
+			want: "expected 200, got 500",
+		},
+		{
+			name: "exactly long enough",
+			s:    "abcd",
+			n:    4,
+			want: "abcd",
+		},
+		{
+			name: "too long",
+			s:    "abcdef",
+			n:    4,
+			want: "abcd...",
+		},
+		{
+			// é is 2 bytes, and cutting at 4 would split it
+			name: "doesn't split a character",
+			s:    "abcé",
+			n:    4,
+			want: "abc...",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := truncate(tt.s, tt.n); got != tt.want {
+				t.Errorf("truncate(%q, %d) = %q, want %q", tt.s, tt.n, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewRunTruncatesMessages(t *testing.T) {
+	body := strings.Repeat("x", 1000)
+	run := NewRun(models.Run{Results: []models.Result{{
+		Assertions: []models.Assertion{{Field: "body", Value: "ok", Result: false, Message: "body was " + body}},
+	}}})
+
+	msg := run.Results[0].Assertions[0].Message
+	if len(msg) != maxMessage+len("...") || !strings.HasPrefix(msg, "body was xxx") {
+		t.Errorf("Message = %q (%d bytes), want the first %d bytes of it", msg, len(msg), maxMessage)
+	}
+}
@@ -0,0 +1,201 @@
+/*
+results reads and writes saved benchmark results.
+
+A results file is a single json document holding the config the benchmark was run with, some
+metadata about where and when it was run, every run of every collection, and aggregates worked
+out from those runs, so that nothing needs to recompute them. Durations are in nanoseconds.
+
+The format is versioned. Anything that changes the meaning of an existing field, or removes one,
+needs a new FormatVersion. Adding fields doesn't
+*/
+package results
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/jonny-burkholder/swarm/internal/models"
+)
+
+// FormatVersion is the version of the results file format that this version of swarm writes
+const FormatVersion = 1
+
+type File struct {
+	Version     int          `json:"version"`
+	Meta        Meta         `json:"meta"`
+	Config      Config       `json:"config"`
+	Collections []Collection `json:"collections"`
+}
+
+// Meta describes the environment the benchmark was run in
+type Meta struct {
+	Host           string    `json:"host"`
+	GitSHA         string    `json:"git_sha,omitempty"` // of the repo the collection file lives in, if any
+	Start          time.Time `json:"start"`
+	End            time.Time `json:"end"`
+	SwarmVersion   string    `json:"swarm_version"`
+	CollectionFile string    `json:"collection_file"`
+}
+
+type Config struct {
+	Runs        int           `json:"runs"`
+	Concurrent  int           `json:"concurrent"`
+	Async       bool          `json:"async"`
+	MaxInFlight int           `json:"max_in_flight"`
+	Duration    time.Duration `json:"duration_ns"`
+	Timeout     time.Duration `json:"timeout_ns"`
+	MaxBodySize int64         `json:"max_body_size"`
+	DiscardBody bool          `json:"discard_body"`
+	KeepFailed  bool          `json:"keep_failed"` // if set, runs that failed were saved
+	KeepAll     bool          `json:"keep_all"`    // if set, every run was saved. With neither, only aggregates were
+	Ramp        []Stage       `json:"ramp,omitempty"`
+	Rate        float64       `json:"rate,omitempty"`
+	MaxWorkers  int           `json:"max_workers,omitempty"`
+	// the expected interval latencies were corrected for coordinated omission with
+	ExpectedInterval time.Duration `json:"expected_interval_ns,omitempty"`
+}
+
+type Stage struct {
+	Target   float64       `json:"target"`
+	Duration time.Duration `json:"duration_ns"`
+	Shape    string        `json:"shape"`
+	Steps    int           `json:"steps,omitempty"`
+}
+
+type Collection struct {
+	Name       string     `json:"name"`
+	BaseUrl    string     `json:"base_url"`
+	Start      time.Time  `json:"start"`
+	End        time.Time  `json:"end"`
+	Dropped    int        `json:"dropped,omitempty"` // runs that were due at a rate, but every worker was busy
+	Late       int        `json:"late,omitempty"`    // runs that started well after they were due
+	Requests   []string   `json:"requests"`          // names, in the order they appear in each run
+	Aggregates Aggregates `json:"aggregates"`
+	Runs       []Run      `json:"runs"`
+}
+
+// New builds a results file from collections that have finished running
+func New(meta Meta, cfg models.Config, collections []*models.Collection) File {
+	file := File{
+		Version: FormatVersion,
+		Meta:    meta,
+		Config: Config{
+			Runs:        cfg.Runs,
+			Concurrent:  cfg.Concurrent,
+			Async:       cfg.Async,
+			MaxInFlight: cfg.MaxInFlight,
+			Duration:    cfg.Duration,
+			Timeout:     cfg.Timeout,
+			MaxBodySize: cfg.MaxBodySize,
+			DiscardBody: cfg.DiscardBody,
+			KeepFailed:  cfg.KeepFailed,
+			KeepAll:     cfg.KeepAll,
+			Rate:        cfg.Rate,
+			MaxWorkers:  cfg.MaxWorkers,
+
+			ExpectedInterval: cfg.ExpectedInterval,
+		},
+		Collections: make([]Collection, 0, len(collections)),
+	}
+	for _, stage := range cfg.Ramp {
+		file.Config.Ramp = append(file.Config.Ramp, Stage(stage))
+	}
+
+	for _, collection := range collections {
+		c := Collection{
+			Name:     collection.Name,
+			BaseUrl:  collection.BaseUrl,
+			Start:    collection.Start,
+			End:      collection.End,
+			Dropped:  collection.Dropped,
+			Late:     collection.Late,
+			Requests: make([]string, len(collection.Requests)),
+			Runs:     make([]Run, len(collection.Runs)),
+		}
+		for i, request := range collection.Requests {
+			c.Requests[i] = request.Name
+		}
+		for i, run := range collection.Runs {
+			c.Runs[i] = NewRun(run)
+		}
+		c.Aggregates = aggregates(collection, cfg.ExpectedInterval)
+		file.Collections = append(file.Collections, c)
+	}
+
+	return file
+}
+
+// Write writes the results file as json
+func (f File) Write(path string) error {
+	out, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	return f.write(out)
+}
+
+func (f File) write(out *os.File) error {
+	// results files can get big, so they aren't indented
+	w := bufio.NewWriter(out)
+	err := json.NewEncoder(w).Encode(f)
+	if err == nil {
+		err = w.Flush()
+	}
+	return errors.Join(err, out.Close())
+}
+
+// Save writes the results file into dir, named after the collection file and start time.
+// If another benchmark of the same collection started in the same second, a number is
+// added to the name rather than overwriting it. It returns the path it was written to
+func (f File) Save(dir string) (string, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+
+	base := strings.TrimSuffix(filepath.Base(f.Meta.CollectionFile), filepath.Ext(f.Meta.CollectionFile))
+	if base == "" || base == "." {
+		base = "results"
+	}
+	name := fmt.Sprintf("%s-%s", base, f.Meta.Start.Format("20060102-150405"))
+
+	for n := 1; ; n++ {
+		path := filepath.Join(dir, name+".json")
+		if n > 1 {
+			path = filepath.Join(dir, fmt.Sprintf("%s-%d.json", name, n))
+		}
+		out, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		if errors.Is(err, fs.ErrExist) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		return path, f.write(out)
+	}
+}
+
+// Load reads a results file, making sure it's in a format we understand
+func Load(path string) (File, error) {
+	in, err := os.Open(path)
+	if err != nil {
+		return File{}, err
+	}
+	defer in.Close()
+
+	file := File{}
+	if err := json.NewDecoder(bufio.NewReader(in)).Decode(&file); err != nil {
+		return File{}, fmt.Errorf("%s: %w", path, err)
+	}
+	if file.Version < 1 || file.Version > FormatVersion {
+		return File{}, VersionError{File: path, Version: file.Version}
+	}
+
+	return file, nil
+}
@@ -0,0 +1,81 @@
+package results
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSave(t *testing.T) {
+	dir := t.TempDir()
+	start := time.Date(2026, 3, 14, 15, 9, 26, 0, time.UTC)
+	f := File{Version: FormatVersion, Meta: Meta{CollectionFile: "collections/library.yml", Start: start}}
+
+	want := []string{
+		"library-20260314-150926.json",
+		"library-20260314-150926-2.json",
+		"library-20260314-150926-3.json",
+	}
+	for _, name := range want {
+		path, err := f.Save(dir)
+		if err != nil {
+			t.Fatalf("Save() error = %v", err)
+		}
+		if path != filepath.Join(dir, name) {
+			t.Errorf("Save() = %s, want %s", path, filepath.Join(dir, name))
+		}
+		if _, err := Load(path); err != nil {
+			t.Errorf("Load(%s) error = %v", path, err)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name        string
+		content     string
+		wantVersion int // in the VersionError, if there should be one
+		wantErr     bool
+	}{
+		{
+			name:    "current version",
+			content: `{"version": 1, "collections": []}`,
+		},
+		{
+			name:        "newer version",
+			content:     `{"version": 2, "collections": []}`,
+			wantVersion: 2,
+			wantErr:     true,
+		},
+		{
+			name:    "not a results file",
+			content: `{"collection": "library"}`,
+			wantErr: true,
+		},
+		{
+			name:    "not json",
+			content: `collection: library`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "results.json")
+			if err := os.WriteFile(path, []byte(tt.content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			_, err := Load(path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Load() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			var verr VersionError
+			if errors.As(err, &verr) && verr.Version != tt.wantVersion {
+				t.Errorf("Load() VersionError.Version = %d, want %d", verr.Version, tt.wantVersion)
+			}
+		})
+	}
+}
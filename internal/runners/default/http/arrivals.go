@@ -0,0 +1,192 @@
+package defaulthttp
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jonny-burkholder/swarm/internal/models"
+	"github.com/jonny-burkholder/swarm/internal/sink"
+)
+
+// lateAfter is how long after it was due a run can start before it counts as late
+const lateAfter = 10 * time.Millisecond
+
+// poolSize is the most workers there can be. With a rate, the pool can grow
+// from Concurrent up to MaxWorkers
+func poolSize(cfg models.Config) int {
+	if cfg.Rate > 0 {
+		return max(cfg.MaxWorkers, cfg.Concurrent)
+	}
+	return cfg.Concurrent
+}
+
+// arrive starts runs at the configured rate, whether or not the ones before them have finished,
+// the same way users keep turning up whether the api is keeping up or not. Handing out runs
+// whenever a worker is free instead quietly sends less load as the api slows down.
+//
+// If every worker is busy when a run is due, another is started, up to MaxWorkers. Past
+// that the run is dropped. Runs that start well after they were due, because a worker had
+// to be started or the sink was falling behind, are counted as late
+func (runner *defaultRunner) arrive(ctx context.Context, collection *models.Collection, start time.Time, pool *workerPool, feed *feeder, resultChan chan models.Run, out sink.Sink) {
+	most := poolSize(runner.Config)
+
+	var deadline <-chan time.Time
+	if runner.Duration > 0 {
+		timer := time.NewTimer(runner.Duration)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+
+	// workers waiting for a run, and runs that are due waiting for a worker to start up
+	idle := []chan iteration{}
+	waiting := []time.Time{}
+
+	due := start  // when the next run is due
+	last := start // when the last one was
+	arrival := time.NewTimer(0)
+	defer arrival.Stop()
+
+	scheduled, dispatched, received, completed, dropped, late := 0, 0, 0, 0, 0, 0
+	gone := 0 // workers that ran out of data and stopped
+	defer func() {
+		collection.Mu.Lock()
+		collection.Dropped, collection.Late = dropped, late
+		collection.Mu.Unlock()
+	}()
+
+	dispatch := func(worker chan iteration, at time.Time) {
+		select {
+		case worker <- iteration{requests: collection.Requests, scheduled: at}:
+			dispatched++
+			if time.Since(at) > lateAfter {
+				late++
+			}
+		case <-ctx.Done():
+		}
+	}
+
+	// start runs until enough have been scheduled, time is up, we run out of data, or
+	// we're cancelled. Either way, wait for every run that was handed out to come back
+	stopping := false
+	done := ctx.Done()
+	defer runner.busy.Store(0)
+	for {
+		runner.busy.Store(int64(dispatched - received))
+		if runner.Duration <= 0 && scheduled >= runner.Runs {
+			stopping = true
+		}
+		if stopping {
+			// these were due, but never got a worker
+			dropped += len(waiting)
+			waiting = waiting[:0]
+			if received == dispatched {
+				break
+			}
+		}
+
+		next := arrival.C
+		if stopping {
+			next = nil
+		}
+
+		select {
+		case <-next:
+			now := time.Now()
+			rate := runner.Rate
+			if runner.Ramp != nil {
+				rate = runner.Ramp(now.Sub(start))
+			}
+			if rate <= 0 {
+				// nothing's due while the ramp is at 0, so check again shortly
+				due, last = now, now
+				arrival.Reset(rampTick)
+				continue
+			}
+			if runner.Ramp != nil && due.After(now) {
+				// we're checking the ramp before the next run is due. The rate may have gone up
+				// since the last run, which brings the next one forward, but runs that would
+				// have been due before now at the new rate weren't due at the old one, so
+				// there's nothing to catch up on
+				if sooner := last.Add(interval(rate)); sooner.Before(due) {
+					due = sooner
+					if due.Before(now) {
+						due = now
+					}
+				}
+				if due.After(now) {
+					arrival.Reset(min(time.Until(due), rampTick))
+					continue
+				}
+			}
+
+			scheduled++
+			// workers that aren't busy but haven't said they're ready yet, because they've
+			// only just started or just sent back a run, will be ready in a moment
+			coming := pool.size - gone - len(idle) - (dispatched - received) - len(waiting)
+			switch {
+			case len(idle) > 0:
+				worker := idle[len(idle)-1]
+				idle = idle[:len(idle)-1]
+				dispatch(worker, due)
+			case coming > 0:
+				waiting = append(waiting, due)
+			case pool.size < most:
+				// the new worker gets this run as soon as it's ready
+				pool.add(1)
+				waiting = append(waiting, due)
+			default:
+				dropped++
+			}
+
+			// if we've fallen behind, due is in the past and the timer fires straight
+			// away, so that we catch up rather than quietly sending less. A ramp is checked
+			// at least every tick, so that a low rate doesn't hold up the next run for longer
+			// than the ramp stays there
+			last, due = due, due.Add(interval(rate))
+			wait := time.Until(due)
+			if runner.Ramp != nil {
+				wait = min(wait, rampTick)
+			}
+			arrival.Reset(wait)
+		case worker := <-pool.next:
+			if len(waiting) > 0 {
+				at := waiting[0]
+				waiting = waiting[1:]
+				dispatch(worker, at)
+				continue
+			}
+			idle = append(idle, worker)
+		case run := <-resultChan:
+			received++
+			if errors.Is(run.Error, ErrDataExhausted) {
+				// the run never happened, so it doesn't count towards the total
+				dispatched--
+				received--
+				scheduled--
+				gone++
+				// partitions of workers that haven't started yet still have rows. Once every
+				// worker that has started is gone, the next run that's due starts another
+				if feed.exhausted() {
+					stopping = true
+				}
+				continue
+			}
+			completed++
+			run.ID = completed
+			// this blocks if the sink is falling behind, which makes runs late
+			out.Write(collection, run)
+		case <-deadline:
+			stopping = true
+			deadline = nil
+		case <-done:
+			stopping = true
+			done = nil
+		}
+	}
+}
+
+// interval is how long there is between runs at rate runs per second
+func interval(rate float64) time.Duration {
+	return time.Duration(float64(time.Second) / rate)
+}
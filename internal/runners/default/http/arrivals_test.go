@@ -0,0 +1,213 @@
+package defaulthttp
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jonny-burkholder/swarm/internal/models"
+	"github.com/jonny-burkholder/swarm/internal/sink"
+)
+
+// slowSink takes a while to write each run, like a sink that's falling behind
+type slowSink struct {
+	sink.Aggregator
+	delay time.Duration
+}
+
+func (s slowSink) Write(collection *models.Collection, run models.Run) error {
+	time.Sleep(s.delay)
+	return s.Aggregator.Write(collection, run)
+}
+
+func TestArrive(t *testing.T) {
+	tests := []struct {
+		name          string
+		cfg           models.Config
+		respondAfter  time.Duration
+		sinkDelay     time.Duration
+		wantCompleted int // -1 if it depends on timing
+		wantLate      bool
+	}{
+		{
+			name:          "keeping up",
+			cfg:           models.Config{Runs: 10, Rate: 100, Concurrent: 2, MaxWorkers: 4},
+			wantCompleted: 10,
+		},
+		{
+			// the first worker takes run 1, a second is started for run 2,
+			// and there's no one left for the rest
+			name:          "dropped once every worker is busy",
+			cfg:           models.Config{Runs: 10, Rate: 100, Concurrent: 1, MaxWorkers: 2},
+			respondAfter:  500 * time.Millisecond,
+			wantCompleted: 2,
+		},
+		{
+			// runs that are due pile up while the sink holds everything up,
+			// and some of them find every worker busy as they catch up
+			name:          "late while the sink falls behind",
+			cfg:           models.Config{Runs: 20, Rate: 100, Concurrent: 1, MaxWorkers: 1},
+			sinkDelay:     50 * time.Millisecond,
+			wantCompleted: -1,
+			wantLate:      true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				time.Sleep(tt.respondAfter)
+			}))
+			defer srv.Close()
+
+			runner := New(tt.cfg)
+			if tt.sinkDelay > 0 {
+				runner.Sink = slowSink{delay: tt.sinkDelay}
+			}
+			collection := &models.Collection{
+				Name:     "test",
+				BaseUrl:  srv.URL,
+				Requests: []models.Request{{Name: "get", Method: http.MethodGet, Path: srv.URL}},
+				Mu:       &sync.Mutex{},
+			}
+
+			if err := runner.Run(context.Background(), []*models.Collection{collection}); err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+
+			completed := 0
+			if collection.Aggregates != nil {
+				completed = collection.Aggregates.Runs
+			}
+			if tt.wantCompleted >= 0 && completed != tt.wantCompleted {
+				t.Errorf("completed %d runs, want %d", completed, tt.wantCompleted)
+			}
+			// every run that was due either happened or was dropped
+			if completed+collection.Dropped != tt.cfg.Runs {
+				t.Errorf("completed %d runs and dropped %d, want %d between them", completed, collection.Dropped, tt.cfg.Runs)
+			}
+			// how late runs are depends on how busy the machine is, so only check for them when they're certain
+			if tt.wantLate && collection.Late == 0 {
+				t.Errorf("Late = 0, want late runs")
+			}
+		})
+	}
+}
+
+func TestArriveWaitsEveryRequest(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	runner := New(models.Config{Runs: 10, Rate: 100, Concurrent: 1, MaxWorkers: 1})
+	collection := &models.Collection{
+		Name:    "test",
+		BaseUrl: srv.URL,
+		Requests: []models.Request{
+			{Name: "first", Method: http.MethodGet, Path: srv.URL},
+			{Name: "second", Method: http.MethodGet, Path: srv.URL},
+		},
+		Mu: &sync.Mutex{},
+	}
+
+	if err := runner.Run(context.Background(), []*models.Collection{collection}); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	// a run is never handed out before it's due, so every request in it had some wait,
+	// and the later ones are held up by it as much as the first
+	for _, a := range collection.Aggregates.Requests {
+		if a.Intended == nil {
+			t.Errorf("%s has no latencies from when it was due", a.Name)
+		}
+	}
+}
+
+func TestArriveRampThroughZero(t *testing.T) {
+	var mu sync.Mutex
+	received := []time.Time{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		received = append(received, time.Now())
+	}))
+	defer srv.Close()
+
+	runner := New(models.Config{Duration: 500 * time.Millisecond, Rate: 100, Concurrent: 2, MaxWorkers: 4})
+	// the rate falls almost to nothing, where a run is due every 10s, and then comes back
+	runner.Ramp = func(elapsed time.Duration) float64 {
+		if elapsed >= 100*time.Millisecond && elapsed < 200*time.Millisecond {
+			return 0.1
+		}
+		return 100
+	}
+	collection := &models.Collection{
+		Name:     "test",
+		BaseUrl:  srv.URL,
+		Requests: []models.Request{{Name: "get", Method: http.MethodGet, Path: srv.URL}},
+		Mu:       &sync.Mutex{},
+	}
+
+	if err := runner.Run(context.Background(), []*models.Collection{collection}); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	// there should be about 30 runs after the dip, but a busy machine can get through far
+	// fewer. Waiting out the run due 10s after the dip would mean none at all
+	mu.Lock()
+	defer mu.Unlock()
+	after := 0
+	for _, at := range received {
+		if at.Sub(collection.Start) > 250*time.Millisecond {
+			after++
+		}
+	}
+	if after == 0 {
+		t.Errorf("no requests after the dip out of %d, want the rate to pick back up", len(received))
+	}
+}
+
+func TestArrivePartitionedStop(t *testing.T) {
+	var mu sync.Mutex
+	sent := []string{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		sent = append(sent, strings.TrimPrefix(r.URL.Path, "/"))
+	}))
+	defer srv.Close()
+
+	// one worker keeps up with the rate, so the rest of the pool is only started as the
+	// ones before it run out. Their partitions still have to be used before it stops
+	runner := New(models.Config{Duration: 5 * time.Second, Rate: 100, Concurrent: 1, MaxWorkers: 4})
+	collection := &models.Collection{
+		Name:     "test",
+		BaseUrl:  srv.URL,
+		Requests: []models.Request{{Name: "get", Method: http.MethodGet, Path: srv.URL + "/{{.id}}"}},
+		Data:     &models.DataSet{Rows: rows(10), Mode: models.DataPartitioned},
+		Mu:       &sync.Mutex{},
+	}
+
+	start := time.Now()
+	if err := runner.Run(context.Background(), []*models.Collection{collection}); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if elapsed := time.Since(start); elapsed >= runner.Duration {
+		t.Errorf("ran for %v, want it to stop once the data ran out", elapsed)
+	}
+
+	slices.SortFunc(sent, func(a, b string) int {
+		x, _ := strconv.Atoi(a)
+		y, _ := strconv.Atoi(b)
+		return x - y
+	})
+	want := []string{"0", "1", "2", "3", "4", "5", "6", "7", "8", "9"}
+	if !slices.Equal(sent, want) {
+		t.Errorf("sent rows %v, want each of %v once", sent, want)
+	}
+}
@@ -5,7 +5,11 @@ import (
 	"fmt"
 )
 
-var ErrCollection = errors.New("not all collections completed successfully")
+var (
+	ErrCollection    = errors.New("not all collections completed successfully")
+	ErrInterrupted   = errors.New("run was interrupted before all requests were sent")
+	ErrDataExhausted = errors.New("every row of the collection's data has been used")
+)
 
 type CollectionError string
 
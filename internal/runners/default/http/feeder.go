@@ -0,0 +1,121 @@
+package defaulthttp
+
+import (
+	"math/rand/v2"
+	"sync"
+
+	"github.com/jonny-burkholder/swarm/internal/models"
+)
+
+// feeder hands out rows of a collection's data set, one per run.
+// A nil feeder hands out empty rows forever
+type feeder struct {
+	mu   sync.Mutex
+	data *models.DataSet
+
+	// sequential and random share a cursor. Random walks a shuffled order,
+	// which is shuffled again every time it wraps
+	order []int
+	pos   int
+	done  bool
+
+	// partitioned gives worker w rows w-1, w-1+n, w-1+2n... for n workers. n is the most the
+	// pool can grow to, so with a rate some partitions belong to workers that haven't started.
+	// Those count as not done yet, since the pool starts another worker once the ones it has
+	// run out and a run is due, and that worker picks up where its partition begins
+	workers  int
+	partPos  []int
+	partDone []bool
+}
+
+func newFeeder(data *models.DataSet, workers int) *feeder {
+	if data == nil {
+		return nil
+	}
+
+	f := &feeder{
+		data:     data,
+		workers:  workers,
+		partPos:  make([]int, workers),
+		partDone: make([]bool, workers),
+	}
+	if data.Mode == models.DataRandom {
+		f.order = rand.Perm(len(data.Rows))
+	}
+
+	return f
+}
+
+// next returns the row for worker's next run, or false if there are no rows left for it
+func (f *feeder) next(worker int) (map[string]string, bool) {
+	if f == nil {
+		return nil, true
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.data.Mode == models.DataPartitioned {
+		return f.nextPartitioned(worker)
+	}
+
+	if f.pos >= len(f.data.Rows) {
+		if !f.data.Wrap {
+			f.done = true
+			return nil, false
+		}
+		f.pos = 0
+		if f.order != nil {
+			f.order = rand.Perm(len(f.data.Rows))
+		}
+	}
+
+	idx := f.pos
+	if f.order != nil {
+		idx = f.order[f.pos]
+	}
+	f.pos++
+
+	return f.data.Rows[idx], true
+}
+
+func (f *feeder) nextPartitioned(worker int) (map[string]string, bool) {
+	w := worker - 1
+	if w < 0 || w >= f.workers || f.partDone[w] {
+		return nil, false
+	}
+
+	idx := w + f.partPos[w]*f.workers
+	if idx >= len(f.data.Rows) {
+		// a worker with no rows at all can't wrap
+		if !f.data.Wrap || w >= len(f.data.Rows) {
+			f.partDone[w] = true
+			return nil, false
+		}
+		f.partPos[w] = 0
+		idx = w
+	}
+	f.partPos[w]++
+
+	return f.data.Rows[idx], true
+}
+
+// exhausted is true once no worker can get any more rows
+func (f *feeder) exhausted() bool {
+	if f == nil {
+		return false
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.data.Mode != models.DataPartitioned {
+		return f.done
+	}
+	for _, done := range f.partDone {
+		if !done {
+			return false
+		}
+	}
+	return true
+}
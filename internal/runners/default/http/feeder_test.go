@@ -0,0 +1,156 @@
+package defaulthttp
+
+import (
+	"slices"
+	"strconv"
+	"testing"
+
+	"github.com/jonny-burkholder/swarm/internal/models"
+)
+
+// rows makes n rows, each with its index as "id"
+func rows(n int) []map[string]string {
+	res := make([]map[string]string, n)
+	for i := range res {
+		res[i] = map[string]string{"id": strconv.Itoa(i)}
+	}
+	return res
+}
+
+func TestFeeder(t *testing.T) {
+	tests := []struct {
+		name          string
+		data          *models.DataSet
+		workers       int
+		calls         []int    // the worker asking for each row
+		want          []string // the id each call gets, or "" when there's none left
+		wantExhausted bool
+	}{
+		{
+			name:    "no data",
+			data:    nil,
+			workers: 2,
+			calls:   []int{1, 2, 1},
+			want:    []string{"", "", ""},
+		},
+		{
+			name:          "sequential",
+			data:          &models.DataSet{Rows: rows(3), Mode: models.DataSequential},
+			workers:       2,
+			calls:         []int{1, 2, 1, 2},
+			want:          []string{"0", "1", "2", ""},
+			wantExhausted: true,
+		},
+		{
+			name:    "sequential wraps",
+			data:    &models.DataSet{Rows: rows(2), Mode: models.DataSequential, Wrap: true},
+			workers: 1,
+			calls:   []int{1, 1, 1, 1, 1},
+			want:    []string{"0", "1", "0", "1", "0"},
+		},
+		{
+			name:          "partitioned",
+			data:          &models.DataSet{Rows: rows(5), Mode: models.DataPartitioned},
+			workers:       2,
+			calls:         []int{1, 2, 1, 2, 1, 1},
+			want:          []string{"0", "1", "2", "3", "4", ""},
+			wantExhausted: false, // worker 2 hasn't found out yet
+		},
+		{
+			name:          "partitioned runs out for everyone",
+			data:          &models.DataSet{Rows: rows(3), Mode: models.DataPartitioned},
+			workers:       2,
+			calls:         []int{1, 2, 1, 2, 1},
+			want:          []string{"0", "1", "2", "", ""},
+			wantExhausted: true,
+		},
+		{
+			name:    "partitioned wraps each worker's share",
+			data:    &models.DataSet{Rows: rows(5), Mode: models.DataPartitioned, Wrap: true},
+			workers: 2,
+			calls:   []int{2, 2, 2, 1, 1, 1, 1},
+			want:    []string{"1", "3", "1", "0", "2", "4", "0"},
+		},
+		{
+			name:          "partitioned worker with no rows can't wrap",
+			data:          &models.DataSet{Rows: rows(1), Mode: models.DataPartitioned, Wrap: true},
+			workers:       2,
+			calls:         []int{2, 1, 1},
+			want:          []string{"", "0", "0"},
+			wantExhausted: false,
+		},
+		{
+			name:    "partitioned worker out of range",
+			data:    &models.DataSet{Rows: rows(4), Mode: models.DataPartitioned},
+			workers: 2,
+			calls:   []int{0, 3},
+			want:    []string{"", ""},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFeeder(tt.data, tt.workers)
+			var got []string
+			for _, w := range tt.calls {
+				row, ok := f.next(w)
+				if !ok {
+					got = append(got, "")
+					continue
+				}
+				if tt.data == nil && row != nil {
+					t.Errorf("next(%d) = %v, want an empty row", w, row)
+				}
+				got = append(got, row["id"])
+			}
+
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("next() gave %q, want %q", got, tt.want)
+			}
+			if f.exhausted() != tt.wantExhausted {
+				t.Errorf("exhausted() = %v, want %v", f.exhausted(), tt.wantExhausted)
+			}
+		})
+	}
+}
+
+func TestFeederRandom(t *testing.T) {
+	tests := []struct {
+		name   string
+		wrap   bool
+		passes int
+	}{
+		{name: "once", wrap: false, passes: 1},
+		{name: "reshuffled every time it wraps", wrap: true, passes: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			const n = 20
+			f := newFeeder(&models.DataSet{Rows: rows(n), Mode: models.DataRandom, Wrap: tt.wrap}, 1)
+
+			// every pass should use each row exactly once
+			for pass := range tt.passes {
+				seen := map[string]bool{}
+				for range n {
+					row, ok := f.next(1)
+					if !ok {
+						t.Fatalf("pass %d: ran out after %d rows, want %d", pass, len(seen), n)
+					}
+					if seen[row["id"]] {
+						t.Fatalf("pass %d: row %s used twice", pass, row["id"])
+					}
+					seen[row["id"]] = true
+				}
+			}
+
+			_, ok := f.next(1)
+			if ok != tt.wrap {
+				t.Errorf("next() after %d passes ok = %v, want %v", tt.passes, ok, tt.wrap)
+			}
+			if f.exhausted() != !tt.wrap {
+				t.Errorf("exhausted() = %v, want %v", f.exhausted(), !tt.wrap)
+			}
+		})
+	}
+}
@@ -6,80 +6,246 @@ if no runner is specified
 package defaulthttp
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
 	"net/http"
-	"net/url"
-	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/jonny-burkholder/swarm/internal/models"
+	"github.com/jonny-burkholder/swarm/internal/sink"
 )
 
+// gracePeriod is how long requests that are already in flight get to
+// finish once a run is cancelled, on top of any client timeout
+const gracePeriod = 10 * time.Second
+
+// runsPerWorker is how many finished runs can be waiting for the sink, per worker,
+// before workers have to wait for it
+const runsPerWorker = 4
+
+// rampTick is how often a ramp is checked for more load, when nothing else is happening
+const rampTick = 50 * time.Millisecond
+
 type defaultRunner struct {
 	models.Config
-	BaseUrl     string
-	Headers     map[string]string
-	QueryParams url.Values
-	Client      *http.Client
+	Client *http.Client
+	// Sink gets every run as it finishes. By default runs are aggregated and kept in
+	// the collection. It's closed when Run returns
+	Sink sink.Sink
+	// Ramp, if set, is how many workers should be busy once elapsed has passed since the
+	// collection started. Concurrent workers are still created, so it should be set to
+	// the most the ramp ever needs. With a rate, it's the rate instead
+	Ramp func(elapsed time.Duration) float64
+
+	busy     atomic.Int64 // workers partway through a run
+	inFlight atomic.Int64 // requests sent that haven't come back yet
+}
+
+// Busy is how many workers are partway through a run right now
+func (runner *defaultRunner) Busy() int {
+	return int(runner.busy.Load())
 }
 
-// TODO: we need config values here
-func New(baseUrl string, headers map[string]string, queryParams url.Values, client ...http.Client) *defaultRunner {
+// InFlight is how many requests have been sent and are waiting on a response right now
+func (runner *defaultRunner) InFlight() int {
+	return int(runner.inFlight.Load())
+}
+
+func New(cfg models.Config, client ...*http.Client) *defaultRunner {
 	runner := defaultRunner{
-		BaseUrl:     baseUrl,
-		Headers:     headers,
-		QueryParams: queryParams,
+		Config: cfg,
+		Sink:   sink.Aggregator{},
+	}
+	// runs are only kept if they're asked for, since a long benchmark has a lot of them
+	switch {
+	case cfg.KeepAll:
+		runner.Sink = sink.Multi(runner.Sink, sink.Keep{})
+	case cfg.KeepFailed:
+		runner.Sink = sink.Multi(runner.Sink, sink.Keep{FailedOnly: true})
 	}
 
 	if len(client) > 0 {
-		runner.Client = &client[0]
+		runner.Client = client[0]
+	} else {
+		runner.Client = &http.Client{
+			Timeout:   cfg.Timeout,
+			Transport: transport(cfg),
+		}
 	}
 
 	return &runner
 }
 
-func (runner *defaultRunner) Run(collections []models.Collection) error {
+// transport is the default transport, but with enough idle connections
+// kept around that every worker can reuse its connection
+func transport(cfg models.Config) *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+
+	perWorker := 1
+	if cfg.Async {
+		// an async worker with no limit could have any number of requests
+		// in flight, so this is just a reasonable guess
+		perWorker = max(cfg.MaxInFlight, 8)
+	}
+	t.MaxIdleConns = 0 // no limit
+	t.MaxIdleConnsPerHost = max(poolSize(cfg)*perWorker, http.DefaultMaxIdleConnsPerHost)
+
+	return t
+}
+
+// Run runs each collection in turn, handing every run to the sink as it finishes.
+// If a duration is set, each collection is run over and over until the duration is
+// up, instead of a set number of times. If ctx is cancelled no new runs are started,
+// but runs that were already going still reach the sink before Run returns ctx.Err()
+func (runner *defaultRunner) Run(ctx context.Context, collections []*models.Collection) error {
+	out := sink.NewBuffered(runner.Sink, max(poolSize(runner.Config), 1)*runsPerWorker)
+
 	// TODO: make collections run async if async
-	// TODO: handle passing in an http client
-	// for each collection
+	errs := []error{}
 	for _, collection := range collections {
-		// create # workers for # concurrent runs
-		resultChan := make(chan []models.Result)
-		doneChan := make(chan struct{})
-		nextChan := newWorkers(runner.Concurrent, resultChan, doneChan, runner.Async)
-		// have worker do runs until run counter is complete
-
-		// listen on the "next" channel for the next worker to be ready
-		go func() {
-			for next := range nextChan {
-				next <- collection.Requests
-			}
-		}()
+		if ctx.Err() != nil {
+			break
+		}
+		if err := runner.runCollection(ctx, collection, out); err != nil {
+			errs = append(errs, fmt.Errorf("%w: %w", CollectionError(collection.Name), err))
+		}
+	}
+
+	if err := out.Close(); err != nil {
+		errs = append(errs, fmt.Errorf("writing results: %w", err))
+	}
+	if ctx.Err() != nil {
+		errs = append(errs, ctx.Err())
+	}
+	if len(errs) > 0 {
+		return errors.Join(append([]error{ErrCollection}, errs...)...)
+	}
+
+	return nil
+}
+
+func (runner *defaultRunner) runCollection(ctx context.Context, collection *models.Collection, out sink.Sink) error {
+	if runner.Runs <= 0 && runner.Duration <= 0 {
+		return fmt.Errorf("either runs or duration must be greater than 0")
+	}
+	if runner.Rate < 0 {
+		return fmt.Errorf("rate can't be negative, got %v", runner.Rate)
+	}
+	if runner.Concurrent < 0 || poolSize(runner.Config) <= 0 {
+		return fmt.Errorf("concurrent workers must be greater than 0, got %d", runner.Concurrent)
+	}
+	if runner.MaxInFlight < 0 {
+		return fmt.Errorf("max in-flight requests can't be negative, got %d", runner.MaxInFlight)
+	}
 
-		wg := &sync.WaitGroup{}
-		wg.Add(runner.Runs)
+	// cancelling workerCtx tells every worker to stop. Requests get their own context,
+	// so that the ones in flight when we're cancelled can finish instead of being cut off
+	workerCtx, stopWorkers := context.WithCancel(ctx)
+	defer stopWorkers()
+	requestCtx, cancelRequests := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancelRequests()
+	stopGrace := context.AfterFunc(ctx, func() {
+		time.AfterFunc(gracePeriod, cancelRequests)
+	})
+	defer stopGrace()
 
-		go func() {
-			wg.Wait()
-			doneChan <- struct{}{}
-		}()
+	start := time.Now()
+	collection.Mu.Lock()
+	collection.Start = start
+	collection.Mu.Unlock()
+	defer func() {
+		collection.Mu.Lock()
+		collection.End = time.Now()
+		collection.Mu.Unlock()
+	}()
+
+	// create # workers for # concurrent runs
+	resultChan := make(chan models.Run)
+	feed := newFeeder(collection.Data, poolSize(runner.Config))
+	pool := newWorkers(workerCtx, requestCtx, runner.Config, feed, resultChan, &runner.inFlight, runner.Client)
+
+	if runner.Rate > 0 {
+		runner.arrive(ctx, collection, start, pool, feed, resultChan, out)
+		return nil
+	}
 
-		id := 1
-	selectloop:
-		for {
+	// when running for a duration, the deadline fires once time is up
+	var deadline <-chan time.Time
+	if runner.Duration > 0 {
+		timer := time.NewTimer(runner.Duration)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+
+	// while ramping, nothing else may happen when more workers are wanted,
+	// so keep checking
+	var tick <-chan time.Time
+	if runner.Ramp != nil {
+		ticker := time.NewTicker(rampTick)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	// have workers do runs until run counter is complete, time is up, we run out of
+	// data, or we're cancelled. Either way, wait for every run that was handed out to come back
+	dispatched, received, completed := 0, 0, 0
+	stopping := false
+	done := ctx.Done()
+	defer runner.busy.Store(0)
+	for {
+		runner.busy.Store(int64(dispatched - received))
+		if runner.Duration <= 0 && dispatched >= runner.Runs {
+			stopping = true
+		}
+		if stopping && received == dispatched {
+			break
+		}
+
+		// a nil channel blocks forever, so once we're done
+		// handing out runs we only listen for results
+		next := pool.next
+		if stopping {
+			next = nil
+		}
+		// while ramping, only hand out runs while fewer workers are busy than the ramp wants.
+		// When it wants fewer, workers that finish just aren't given any more
+		if runner.Ramp != nil && float64(dispatched-received) >= math.Round(runner.Ramp(time.Since(start))) {
+			next = nil
+		}
+
+		select {
+		case worker := <-next:
 			select {
-			case results := <-resultChan:
-				run := models.Run{
-					ID:      id,
-					Results: results,
+			case worker <- iteration{requests: collection.Requests}:
+				dispatched++
+			case <-done:
+			}
+		case run := <-resultChan:
+			received++
+			if errors.Is(run.Error, ErrDataExhausted) {
+				// the run never happened, so it doesn't count towards the total
+				dispatched--
+				received--
+				if feed.exhausted() {
+					stopping = true
 				}
-				collection.Mu.Lock()
-				collection.Runs = append(collection.Runs, run)
-				collection.Mu.Unlock()
-				id++      // increment id
-				wg.Done() // decrement wait group
-			case <-doneChan:
-				close(nextChan)  // this might cause deadlock
-				break selectloop // using a label to shut the linter up
+				continue
 			}
+			completed++
+			run.ID = completed
+			// this blocks if the sink is falling behind, which holds up
+			// handing out new runs until it catches up
+			out.Write(collection, run)
+		case <-tick:
+		case <-deadline:
+			stopping = true
+			deadline = nil
+		case <-done:
+			stopping = true
+			done = nil
 		}
 	}
 
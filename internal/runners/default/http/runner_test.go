@@ -0,0 +1,97 @@
+package defaulthttp
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jonny-burkholder/swarm/internal/models"
+)
+
+// testCollection is a collection of GET requests to each of paths on srv
+func testCollection(srv *httptest.Server, paths ...string) *models.Collection {
+	c := &models.Collection{Name: "test", BaseUrl: srv.URL, Mu: &sync.Mutex{}}
+	for _, path := range paths {
+		c.Requests = append(c.Requests, models.Request{Name: "GET " + path, Method: http.MethodGet, Path: srv.URL + path})
+	}
+	return c
+}
+
+func TestRunDuration(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(5 * time.Millisecond)
+	}))
+	defer srv.Close()
+
+	duration := 200 * time.Millisecond
+	runner := New(models.Config{Duration: duration, Concurrent: 2})
+	collection := testCollection(srv, "/books")
+	if err := runner.Run(context.Background(), []*models.Collection{collection}); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	// runs keep going until time is up, and the ones in flight then are waited for. A busy
+	// machine can be slow to get back to us, so the bounds are loose: it's only there to
+	// catch a run that never stops, or one that stops after the first few runs
+	elapsed := collection.End.Sub(collection.Start)
+	if elapsed < duration || elapsed > duration+2*time.Second {
+		t.Errorf("ran for %s, want about %s", elapsed, duration)
+	}
+	if runs := collection.Aggregates.Runs; runs <= 2*runner.Concurrent {
+		t.Errorf("completed %d runs, want runs to keep going for the whole duration", runs)
+	}
+	if collection.Aggregates.Incomplete != 0 {
+		t.Errorf("Incomplete = %d, want runs in flight when time was up to finish", collection.Aggregates.Incomplete)
+	}
+}
+
+func TestRunCancelled(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	runner := New(models.Config{Runs: 1000, Concurrent: 2, KeepAll: true})
+	collection := testCollection(srv, "/books", "/authors", "/series")
+	err := runner.Run(ctx, []*models.Collection{collection})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Run() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	// runs that were going when it was cancelled stop between requests, but still come back
+	runs := collection.Runs
+	if len(runs) == 0 || len(runs) >= 1000 {
+		t.Fatalf("kept %d runs, want the ones from before it was cancelled", len(runs))
+	}
+	if collection.Aggregates.Runs != len(runs) {
+		t.Errorf("aggregated %d runs, want all %d", collection.Aggregates.Runs, len(runs))
+	}
+	interrupted := 0
+	for _, run := range runs {
+		if errors.Is(run.Error, ErrInterrupted) {
+			interrupted++
+		}
+		if run.Error == nil && len(run.Results) != 3 {
+			t.Errorf("run %d has %d results and no error, want 3", run.ID, len(run.Results))
+		}
+		if errors.Is(run.Error, ErrInterrupted) && len(run.Results) >= 3 {
+			t.Errorf("run %d was interrupted, but has every result", run.ID)
+		}
+		for _, result := range run.Results {
+			// requests in flight get to finish rather than being cut off
+			if result.Error != nil {
+				t.Errorf("run %d %s error = %v", run.ID, result.Name, result.Error)
+			}
+		}
+	}
+	// each worker takes 60ms a run, so both are partway through one at 100ms
+	if interrupted == 0 || collection.Aggregates.Incomplete != interrupted {
+		t.Errorf("%d runs were interrupted and %d counted as incomplete, want the same number above 0", interrupted, collection.Aggregates.Incomplete)
+	}
+}
@@ -0,0 +1,124 @@
+package defaulthttp
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sync/atomic"
+	"time"
+
+	"github.com/jonny-burkholder/swarm/internal/models"
+	"github.com/jonny-burkholder/swarm/internal/templating"
+)
+
+// sender sends requests and turns the responses into results
+type sender struct {
+	client      *http.Client
+	maxBodySize int64 // 0 means keep the whole body
+	discardBody bool
+	templates   *templating.Engine
+	inFlight    *atomic.Int64 // requests sent but not yet read, shared with the other senders
+}
+
+// send fills in any variables in the request, sends it, and returns the result
+func (s *sender) send(ctx context.Context, request models.Request, vars map[string]string) models.Result {
+	raw := request
+	request, err := s.templates.Request(request, vars)
+	result := models.Result{
+		Request: request,
+		Raw:     raw,
+	}
+	if err != nil {
+		result.Error = fmt.Errorf("filling in variables: %w", err)
+		return result
+	}
+
+	// parse the url
+	reqUrl, err := url.Parse(request.Path)
+	if err != nil {
+		result.Error = err
+		return result
+	}
+	// add the query params to the url
+	reqUrl.RawQuery = url.Values(request.QueryParams).Encode()
+
+	// prepare the http request
+	t := &tracer{}
+	req, err := http.NewRequestWithContext(t.trace(ctx), request.Method, reqUrl.String(), bytes.NewBuffer(request.Body))
+	if err != nil {
+		result.Error = err
+		return result
+	}
+	for k, v := range request.Headers {
+		// use Set() for idempotence
+		req.Header.Set(k, v)
+	}
+	if request.Auth != nil {
+		if err = request.Auth.Authenticate(req); err != nil {
+			result.Error = err
+			return result
+		}
+	}
+
+	// send the request
+	if s.inFlight != nil {
+		s.inFlight.Add(1)
+		defer s.inFlight.Add(-1)
+	}
+	start := time.Now()
+	res, err := s.client.Do(req)
+	if err != nil {
+		done := time.Now()
+		result.Duration = done.Sub(start)
+		result.Timing = t.timing(done)
+		result.Error = err
+		return result
+	}
+	defer res.Body.Close()
+
+	// populate the result
+	result.StatusCode = res.StatusCode
+	result.Headers = res.Header
+	result.Error = s.readBody(res.Body, &result)
+	done := time.Now()
+	result.Duration = done.Sub(start)
+	result.Timing = t.timing(done)
+	if result.Error == nil {
+		result.CheckAssertions()
+		result.ExtractVariables()
+	}
+
+	return result
+}
+
+// readBody reads as much of the body as we want to keep into the result, then drains the
+// rest. The body always has to be read to the end, or the connection can't be reused
+func (s *sender) readBody(body io.Reader, result *models.Result) error {
+	if s.discardBody {
+		n, err := io.Copy(io.Discard, body)
+		result.BytesReceived = n
+		result.BodyDiscarded = true
+		return err
+	}
+
+	r := body
+	if s.maxBodySize > 0 {
+		r = io.LimitReader(body, s.maxBodySize)
+	}
+	buf := &bytes.Buffer{}
+	n, err := io.Copy(buf, r)
+	result.Body = buf.Bytes()
+	result.BytesReceived = n
+	if err != nil {
+		return err
+	}
+
+	rest, err := io.Copy(io.Discard, body)
+	result.BytesReceived += rest
+	result.BodyTruncated = rest > 0
+
+	return err
+}
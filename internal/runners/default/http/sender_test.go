@@ -0,0 +1,153 @@
+package defaulthttp
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jonny-burkholder/swarm/internal/models"
+	"github.com/jonny-burkholder/swarm/internal/results"
+	"github.com/jonny-burkholder/swarm/internal/templating"
+)
+
+func TestReadBody(t *testing.T) {
+	const body = "Gardens of the Moon"
+	tests := []struct {
+		name          string
+		sender        sender
+		wantBody      string
+		wantTruncated bool
+		wantDiscarded bool
+	}{
+		{
+			name:     "whole body",
+			sender:   sender{},
+			wantBody: body,
+		},
+		{
+			name:     "under the max size",
+			sender:   sender{maxBodySize: 100},
+			wantBody: body,
+		},
+		{
+			name:     "exactly the max size",
+			sender:   sender{maxBodySize: int64(len(body))},
+			wantBody: body,
+		},
+		{
+			name:          "truncated",
+			sender:        sender{maxBodySize: 7},
+			wantBody:      "Gardens",
+			wantTruncated: true,
+		},
+		{
+			name:          "discarded",
+			sender:        sender{discardBody: true},
+			wantDiscarded: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := strings.NewReader(body)
+			result := models.Result{}
+			if err := tt.sender.readBody(r, &result); err != nil {
+				t.Fatalf("readBody() error = %v", err)
+			}
+
+			if string(result.Body) != tt.wantBody {
+				t.Errorf("Body = %q, want %q", result.Body, tt.wantBody)
+			}
+			if result.BodyTruncated != tt.wantTruncated || result.BodyDiscarded != tt.wantDiscarded {
+				t.Errorf("BodyTruncated, BodyDiscarded = %v, %v, want %v, %v", result.BodyTruncated, result.BodyDiscarded, tt.wantTruncated, tt.wantDiscarded)
+			}
+			// the whole body is counted, however much of it was kept
+			if result.BytesReceived != int64(len(body)) {
+				t.Errorf("BytesReceived = %d, want %d", result.BytesReceived, len(body))
+			}
+			// and read to the end, so the connection can be reused
+			if r.Len() != 0 {
+				t.Errorf("%d bytes of the body were left unread", r.Len())
+			}
+		})
+	}
+}
+
+func TestSend(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Book", r.Header.Get("X-Book"))
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(strings.Repeat("x", 1000)))
+	}))
+	defer srv.Close()
+
+	s := &sender{client: srv.Client(), maxBodySize: 10, templates: templating.New(1)}
+	request := models.Request{
+		Name:    "POST books",
+		Method:  http.MethodPost,
+		Path:    srv.URL + "/books",
+		Headers: map[string]string{"X-Book": "{{.title}}"},
+	}
+
+	for i := range 2 {
+		result := s.send(context.Background(), request, map[string]string{"title": "Deadhouse Gates"})
+		if result.Error != nil {
+			t.Fatalf("send() error = %v", result.Error)
+		}
+		if result.StatusCode != http.StatusCreated || result.Headers["X-Book"][0] != "Deadhouse Gates" {
+			t.Errorf("status %d, X-Book %v, want %d and the header that was sent", result.StatusCode, result.Headers["X-Book"], http.StatusCreated)
+		}
+		if len(result.Body) != 10 || !result.BodyTruncated || result.BytesReceived != 1000 {
+			t.Errorf("kept %d bytes of %d, truncated %v, want 10 of 1000, truncated", len(result.Body), result.BytesReceived, result.BodyTruncated)
+		}
+		// the rest of the body was drained, so the second request reuses the connection
+		if i == 1 && !result.Timing.ConnReused {
+			t.Error("second request didn't reuse the connection")
+		}
+	}
+}
+
+func TestSendSavesNoSecrets(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Token", r.Header.Get("X-Token"))
+		w.Header().Set("X-Session", "session-secret")
+	}))
+	defer srv.Close()
+
+	extract, err := models.NewExtraction("session", models.ExtractHeader, "x-session")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &sender{client: srv.Client(), templates: templating.New(1)}
+	request := models.Request{
+		Name:    "GET shelf",
+		Method:  http.MethodGet,
+		Path:    srv.URL + "/shelves/{{.token}}",
+		Headers: map[string]string{"X-Token": "{{.token}}"},
+		// both fail, so their messages quote what was found
+		Assert: []models.Assertion{
+			{Field: "header.X-Token", Value: "nope"},
+			{Field: "headers.X-Session", Value: "nope"},
+		},
+		Extract: []models.Extraction{extract},
+	}
+
+	result := s.send(context.Background(), request, map[string]string{"token": "token-secret"})
+	if result.Error != nil {
+		t.Fatalf("send() error = %v", result.Error)
+	}
+	run := results.NewRun(models.Run{ID: 1, Results: []models.Result{result}})
+	if saved := run.Results[0]; saved.Path != request.Path {
+		t.Errorf("saved Path = %s, want it as written, %s", saved.Path, request.Path)
+	}
+	b, err := json.Marshal(run)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved := string(b); strings.Contains(saved, "secret") {
+		t.Errorf("saved result has a secret in it: %s", saved)
+	}
+}
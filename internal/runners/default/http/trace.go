@@ -0,0 +1,94 @@
+package defaulthttp
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http/httptrace"
+	"sync"
+	"time"
+
+	"github.com/jonny-burkholder/swarm/internal/models"
+)
+
+// tracer records when each phase of a request starts and ends. Some of the
+// hooks can be called from other goroutines (e.g. when dialing several
+// addresses at once), hence the mutex
+type tracer struct {
+	mu sync.Mutex
+
+	dnsStart, dnsDone         time.Time
+	connectStart, connectDone time.Time
+	tlsStart, tlsDone         time.Time
+	wroteRequest, firstByte   time.Time
+	reused                    bool
+}
+
+// trace returns a context that will report the phases of a request to t
+func (t *tracer) trace(ctx context.Context) context.Context {
+	return httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) {
+			t.set(&t.dnsStart)
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			t.set(&t.dnsDone)
+		},
+		ConnectStart: func(string, string) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			// keep the first start if several addresses are tried
+			if t.connectStart.IsZero() {
+				t.connectStart = time.Now()
+			}
+		},
+		ConnectDone: func(string, string, error) {
+			t.set(&t.connectDone)
+		},
+		TLSHandshakeStart: func() {
+			t.set(&t.tlsStart)
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			t.set(&t.tlsDone)
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.reused = info.Reused
+		},
+		WroteRequest: func(httptrace.WroteRequestInfo) {
+			t.set(&t.wroteRequest)
+		},
+		GotFirstResponseByte: func() {
+			t.set(&t.firstByte)
+		},
+	})
+}
+
+func (t *tracer) set(field *time.Time) {
+	now := time.Now()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	*field = now
+}
+
+// timing works out how long each phase took, given when the body finished being read
+func (t *tracer) timing(done time.Time) models.Timing {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return models.Timing{
+		DNS:        between(t.dnsStart, t.dnsDone),
+		Connect:    between(t.connectStart, t.connectDone),
+		TLS:        between(t.tlsStart, t.tlsDone),
+		TTFB:       between(t.wroteRequest, t.firstByte),
+		Transfer:   between(t.firstByte, done),
+		ConnReused: t.reused,
+	}
+}
+
+// between returns the time from start to end, or 0 if either didn't happen
+func between(start, end time.Time) time.Duration {
+	if start.IsZero() || end.IsZero() {
+		return 0
+	}
+	return end.Sub(start)
+}
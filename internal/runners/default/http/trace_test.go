@@ -0,0 +1,51 @@
+package defaulthttp
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jonny-burkholder/swarm/internal/models"
+	"github.com/jonny-burkholder/swarm/internal/templating"
+)
+
+func TestTiming(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	s := &sender{client: srv.Client(), templates: templating.New(1)}
+	request := models.Request{Name: "GET books", Method: http.MethodGet, Path: srv.URL + "/books"}
+
+	// the first request has to connect and shake hands
+	first := s.send(context.Background(), request, nil)
+	if first.Error != nil {
+		t.Fatalf("send() error = %v", first.Error)
+	}
+	if first.Timing.ConnReused {
+		t.Error("first request ConnReused = true, want a new connection")
+	}
+	if first.Timing.Connect <= 0 || first.Timing.TLS <= 0 {
+		t.Errorf("first request Connect = %s, TLS = %s, want both recorded", first.Timing.Connect, first.Timing.TLS)
+	}
+
+	// the next reuses its connection, so those phases never happen
+	next := s.send(context.Background(), request, nil)
+	if next.Error != nil {
+		t.Fatalf("send() error = %v", next.Error)
+	}
+	if !next.Timing.ConnReused {
+		t.Error("next request ConnReused = false, want the connection reused")
+	}
+	if next.Timing.DNS != 0 || next.Timing.Connect != 0 || next.Timing.TLS != 0 {
+		t.Errorf("next request DNS = %s, Connect = %s, TLS = %s, want 0", next.Timing.DNS, next.Timing.Connect, next.Timing.TLS)
+	}
+
+	for _, result := range []models.Result{first, next} {
+		if result.Timing.TTFB <= 0 || result.Timing.TTFB > result.Duration {
+			t.Errorf("TTFB = %s, want it recorded, and within the request's %s", result.Timing.TTFB, result.Duration)
+		}
+	}
+}
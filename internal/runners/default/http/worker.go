@@ -1,154 +1,269 @@
 package defaulthttp
 
 import (
-	"bytes"
+	"context"
+	"maps"
 	"net/http"
-	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/jonny-burkholder/swarm/internal/models"
+	"github.com/jonny-burkholder/swarm/internal/templating"
 )
 
-// TODO: add context for cancel
+// iteration is a run for a worker to do
+type iteration struct {
+	requests  []models.Request
+	scheduled time.Time // when it was meant to start, if it was scheduled
+}
 
 // newWorkers creates new workers to run the requests from a collection. They can send requests either
-// syncronously or asyncronously. The function returns a slice of type chan []models.Request, as each
-// worker will have its own channel to receive requests on, so that the caller can trivially keep track
-// of the number of runs, and close each channel when the runs are complete
-func newWorkers(numWorkers int, resChan chan []models.Result, doneChan chan struct{}, async bool, client ...*http.Client) chan chan []models.Request {
-	res := make([]chan []models.Request, numWorkers)
-
-	var workerClient *http.Client
-	if len(client) > 0 {
-		workerClient = client[0]
+// syncronously or asyncronously. Each worker has its own channel to receive runs on, which it
+// sends on the pool's next channel whenever it's ready for more work, so that the caller can trivially
+// hand out runs to whichever worker is free. Cancelling ctx stops every worker, and any run a worker
+// is partway through is cut short. Requests are sent with reqCtx, so that requests already in
+// flight can be allowed to finish. If the collection has data, each run starts by taking a row from
+// the feeder. A worker that can't get a row sends back a run with ErrDataExhausted, and stops.
+// cfg.Concurrent workers are started straight away, and more can be added to the pool later.
+// inFlight is kept up to date with how many requests every worker has sent but not yet had back
+func newWorkers(ctx, reqCtx context.Context, cfg models.Config, feed *feeder, resChan chan models.Run, inFlight *atomic.Int64, client ...*http.Client) *workerPool {
+	pool := &workerPool{
+		ctx:      ctx,
+		reqCtx:   reqCtx,
+		cfg:      cfg,
+		feed:     feed,
+		resChan:  resChan,
+		inFlight: inFlight,
+		client:   http.DefaultClient,
+		// create a buffered channel that will tell the caller what the next available worker is
+		// 1/4 of the number of workers ought to do it
+		next: make(chan chan iteration, cfg.Concurrent/4),
+	}
+	if len(client) > 0 && client[0] != nil {
+		pool.client = client[0]
 	}
 
-	// create a buffered channel that will tell the caller what the next available worker is
-	// 1/4 of the number of workers ought to do it
-	nextChan := make(chan chan []models.Request, numWorkers/4)
+	pool.add(cfg.Concurrent)
+
+	return pool
+}
+
+type workerPool struct {
+	ctx      context.Context
+	reqCtx   context.Context
+	cfg      models.Config
+	feed     *feeder
+	resChan  chan models.Run
+	inFlight *atomic.Int64
+	client   *http.Client
+	next     chan chan iteration
+	size     int // workers started so far
+}
+
+// add starts n more workers
+func (p *workerPool) add(n int) {
+	// every worker gets its own sender, so that things like
+	// sequence counters in templates belong to the worker
+	newSender := func(worker int) *sender {
+		return &sender{
+			client:      p.client,
+			maxBodySize: p.cfg.MaxBodySize,
+			discardBody: p.cfg.DiscardBody,
+			templates:   templating.New(worker),
+			inFlight:    p.inFlight,
+		}
+	}
 
 	// slightly uglier than checking in the loop,
 	// but I'm guessing more performant, if slightly
-	if async {
-		for i := range numWorkers {
-			c := make(chan []models.Request)
+	if p.cfg.Async {
+		for range n {
+			p.size++
 			wrk := asyncWorker{
-				requestChan: c,
-				resultChan:  resChan,
-				nextChan:    nextChan,
-				doneChan:    doneChan,
-				client:      workerClient,
+				requestChan: make(chan iteration),
+				resultChan:  p.resChan,
+				nextChan:    p.next,
+				ctx:         p.ctx,
+				reqCtx:      p.reqCtx,
+				sender:      newSender(p.size),
+				id:          p.size,
+				feeder:      p.feed,
+				maxInFlight: p.cfg.MaxInFlight,
 			}
-			res[i] = c
 
 			go wrk.run()
 		}
 	} else {
-		for i := range numWorkers {
-			c := make(chan []models.Request)
+		for range n {
+			p.size++
 			wrk := syncWorker{
-				requestChan: c,
-				resultChan:  resChan,
-				nextChan:    nextChan,
-				doneChan:    doneChan,
-				client:      workerClient,
+				requestChan: make(chan iteration),
+				resultChan:  p.resChan,
+				nextChan:    p.next,
+				ctx:         p.ctx,
+				reqCtx:      p.reqCtx,
+				sender:      newSender(p.size),
+				id:          p.size,
+				feeder:      p.feed,
 			}
-			res[i] = c
 
 			go wrk.run()
 		}
 	}
-
-	return nextChan
 }
 
 type syncWorker struct {
-	requestChan chan []models.Request
-	resultChan  chan []models.Result
-	nextChan    chan chan []models.Request
-	doneChan    chan struct{}
-	client      *http.Client
+	requestChan chan iteration
+	resultChan  chan models.Run
+	nextChan    chan chan iteration
+	ctx         context.Context
+	reqCtx      context.Context
+	sender      *sender
+	id          int
+	feeder      *feeder
 }
 
 type asyncWorker struct {
-	requestChan chan []models.Request
-	resultChan  chan []models.Result
-	nextChan    chan chan []models.Request
-	doneChan    chan struct{}
-	client      *http.Client
+	requestChan chan iteration
+	resultChan  chan models.Run
+	nextChan    chan chan iteration
+	ctx         context.Context
+	reqCtx      context.Context
+	sender      *sender
+	id          int
+	feeder      *feeder
+	maxInFlight int // 0 means every request in the run at once
+}
+
+// ready lets the runner know the worker is free, then waits for the requests of its
+// next run. It returns false if the worker has been told to stop
+func ready(ctx context.Context, nextChan chan chan iteration, requestChan chan iteration) (iteration, bool) {
+	select {
+	case nextChan <- requestChan:
+	case <-ctx.Done():
+		return iteration{}, false
+	}
+
+	select {
+	case it := <-requestChan:
+		return it, true
+	case <-ctx.Done():
+		return iteration{}, false
+	}
 }
 
 func (w syncWorker) run() {
+	for {
+		// let the caller know we're ready for more work
+		it, ok := ready(w.ctx, w.nextChan, w.requestChan)
+		if !ok {
+			return
+		}
+		requests := it.requests
+
+		// variables only live for one run, so that workers don't trip over each other
+		row, ok := w.feeder.next(w.id)
+		if !ok {
+			w.resultChan <- models.Run{Error: ErrDataExhausted}
+			return
+		}
+		vars := make(map[string]string, len(row))
+		maps.Copy(vars, row)
+		w.sender.templates.NewRun()
 
-	if w.client == nil {
-		w.client = http.DefaultClient
+		run := models.Run{
+			Scheduled: it.scheduled,
+			Results:   make([]models.Result, 0, len(requests)),
+		}
+		// each request is sent as soon as the one before is done, so a run that starts late
+		// pushes every request in it back by the same amount
+		var wait time.Duration
+		if !it.scheduled.IsZero() {
+			wait = max(time.Since(it.scheduled), 0)
+		}
+		for _, request := range requests {
+			if w.ctx.Err() != nil {
+				run.Error = ErrInterrupted
+				break
+			}
+			result := w.sender.send(w.reqCtx, request, vars)
+			result.Wait = wait
+			maps.Copy(vars, result.Extracted)
+			run.Results = append(run.Results, result)
+		}
+		// return the results to the resultchan. The runner always waits for
+		// runs it has handed out, so there's no need to check ctx here
+		w.resultChan <- run
 	}
+}
+
+// run sends all the requests in a run at once, or at most maxInFlight at a time, the way
+// a browser fires off api calls on page load. Results are kept in the same order as the requests.
+// Since nothing waits for anything else, variables extracted from one response can't be used
+// by other requests in the same run
+func (w asyncWorker) run() {
+	for {
+		it, ok := ready(w.ctx, w.nextChan, w.requestChan)
+		if !ok {
+			return
+		}
+		requests := it.requests
 
-	go func() {
-		<-w.doneChan
-		close(w.requestChan)
-	}()
+		row, ok := w.feeder.next(w.id)
+		if !ok {
+			w.resultChan <- models.Run{Error: ErrDataExhausted}
+			return
+		}
+		vars := make(map[string]string, len(row))
+		maps.Copy(vars, row)
+		w.sender.templates.NewRun()
+
+		limit := w.maxInFlight
+		if limit <= 0 || limit > len(requests) {
+			limit = len(requests)
+		}
+		inFlight := make(chan struct{}, limit)
 
-	for requests := range w.requestChan {
 		results := make([]models.Result, len(requests))
+		wg := &sync.WaitGroup{}
+		run := models.Run{Scheduled: it.scheduled}
+		sent := 0
+	sendloop:
 		for i, request := range requests {
-			result := models.Result{
-				Request: request,
-			}
-
-			// parse the url
-			reqUrl, err := url.Parse(request.Path)
-			if err != nil {
-				result.Error = err
-				results[i] = result
-				continue
-			}
-			// add the query params to the url
-			reqUrl.RawQuery = url.Values(request.QueryParams).Encode()
-
-			// prepare the http request
-			req, err := http.NewRequest(request.Method, reqUrl.String(), bytes.NewBuffer(request.Body))
-			if err != nil {
-				result.Error = err
-				results[i] = result
-				continue
-			}
-			for k, v := range request.Headers {
-				// use Set() for idempotence
-				req.Header.Set(k, v)
+			// wait for a free slot, unless we've been cancelled
+			select {
+			case inFlight <- struct{}{}:
+			case <-w.ctx.Done():
+				break sendloop
 			}
-			if err = request.Auth.Authenticate(req); err != nil {
-				result.Error = err
-				results[i] = result
-				continue
+			if w.ctx.Err() != nil {
+				<-inFlight
+				break
 			}
 
-			// send the request
-			res, err := w.client.Do(req)
-			if err != nil {
-				result.Error = err
-				results[i] = result
-				continue
-			}
-			// populate the result
-			result.StatusCode = res.StatusCode
-			b := []byte{}
-			_, err = res.Body.Read(b)
-			if err != nil {
-				result.Error = err
-			}
-			result.Body = b
-			// call any assertions
-			// TODO: implement this. Right now we're not doing anything with the response body
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				// every request was due when the run was, even
+				// if it had to wait for a slot
+				var wait time.Duration
+				if !it.scheduled.IsZero() {
+					wait = max(time.Since(it.scheduled), 0)
+				}
+				results[i] = w.sender.send(w.reqCtx, request, vars)
+				results[i].Wait = wait
+				<-inFlight
+			}()
+			sent++
+		}
+		wg.Wait()
 
-			// add the result to the results slice
-			results[i] = result
+		if sent < len(requests) {
+			run.Error = ErrInterrupted
 		}
-		// return the results to the resultchan
-		w.resultChan <- results
-		w.nextChan <- w.requestChan
-	}
-}
+		run.Results = results[:sent]
 
-func (w asyncWorker) run() {
-	// TODO: implement the nightmare of async requests
+		w.resultChan <- run
+	}
 }
@@ -0,0 +1,80 @@
+package defaulthttp
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jonny-burkholder/swarm/internal/models"
+)
+
+func TestAsyncWorkerOrder(t *testing.T) {
+	// later requests come back first
+	delays := map[string]time.Duration{"/a": 60 * time.Millisecond, "/b": 30 * time.Millisecond, "/c": 0}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(delays[r.URL.Path])
+		w.Write([]byte(r.URL.Path))
+	}))
+	defer srv.Close()
+
+	runner := New(models.Config{Runs: 3, Concurrent: 1, Async: true, KeepAll: true})
+	collection := testCollection(srv, "/a", "/b", "/c")
+	if err := runner.Run(context.Background(), []*models.Collection{collection}); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	paths := []string{"/a", "/b", "/c"}
+	for _, run := range collection.Runs {
+		for i, result := range run.Results {
+			if result.Name != "GET "+paths[i] || string(result.Body) != paths[i] {
+				t.Errorf("run %d result %d is %s with body %s, want the response to %s", run.ID, i, result.Name, result.Body, paths[i])
+			}
+		}
+	}
+}
+
+func TestAsyncWorkerMaxInFlight(t *testing.T) {
+	tests := []struct {
+		name        string
+		maxInFlight int
+		want        int // the most requests the server should see at once
+	}{
+		{name: "no limit", maxInFlight: 0, want: 6},
+		{name: "limited", maxInFlight: 2, want: 2},
+		{name: "limit over the number of requests", maxInFlight: 10, want: 6},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mu := sync.Mutex{}
+			current, most := 0, 0
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				current++
+				most = max(most, current)
+				mu.Unlock()
+				time.Sleep(30 * time.Millisecond)
+				mu.Lock()
+				current--
+				mu.Unlock()
+			}))
+			defer srv.Close()
+
+			runner := New(models.Config{Runs: 2, Concurrent: 1, Async: true, MaxInFlight: tt.maxInFlight})
+			collection := testCollection(srv, "/1", "/2", "/3", "/4", "/5", "/6")
+			if err := runner.Run(context.Background(), []*models.Collection{collection}); err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+
+			if most != tt.want {
+				t.Errorf("server saw at most %d requests at once, want %d", most, tt.want)
+			}
+			if n := collection.Aggregates.Total.Count; n != 12 {
+				t.Errorf("sent %d requests, want 12", n)
+			}
+		})
+	}
+}
@@ -0,0 +1,144 @@
+/*
+	schema validates response bodies against json schemas, so that a collection
+
+can double as a contract test
+*/
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jonny-burkholder/swarm/internal/models"
+	"github.com/santhosh-tekuri/jsonschema/v6"
+	"gopkg.in/yaml.v3"
+)
+
+// Schema is a compiled json schema. It satisfies models.Schema
+type Schema struct {
+	schema *jsonschema.Schema
+}
+
+// Load compiles the schema in the file at path, which can be json or yaml.
+// Relative $refs are resolved from the file's directory
+func Load(path string) (*Schema, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := readFile(abs)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return compile("file://"+filepath.ToSlash(abs), doc)
+}
+
+// readFile reads a json or yaml schema file, going by its extension
+func readFile(path string) (any, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	ext := strings.ToLower(filepath.Ext(path))
+	if ext != ".yml" && ext != ".yaml" {
+		return jsonschema.UnmarshalJSON(bytes.NewReader(b))
+	}
+
+	var doc any
+	if err := yaml.Unmarshal(b, &doc); err != nil {
+		return nil, err
+	}
+	return toJSON(doc)
+}
+
+// fileLoader loads the files that schemas $ref, so they can be yaml too
+type fileLoader struct{}
+
+func (fileLoader) Load(loc string) (any, error) {
+	path, err := jsonschema.FileLoader{}.ToFile(loc)
+	if err != nil {
+		return nil, err
+	}
+	return readFile(path)
+}
+
+// Compile compiles a schema that's already been decoded, e.g. one written inline
+// in a collection. The name identifies the schema in error messages
+func Compile(name string, doc any) (*Schema, error) {
+	doc, err := toJSON(doc)
+	if err != nil {
+		return nil, err
+	}
+	return compile("inline:///"+url.PathEscape(name), doc)
+}
+
+// toJSON converts a decoded yaml document into what the compiler expects, which
+// is values that look like they came from encoding/json. There's no direct way,
+// so take the long way round
+func toJSON(doc any) (any, error) {
+	b, err := json.Marshal(doc)
+	if err != nil {
+		return nil, fmt.Errorf("schema can't be encoded as json: %w", err)
+	}
+	return jsonschema.UnmarshalJSON(bytes.NewReader(b))
+}
+
+func compile(loc string, doc any) (*Schema, error) {
+	c := jsonschema.NewCompiler()
+	c.UseLoader(jsonschema.SchemeURLLoader{"file": fileLoader{}})
+	if err := c.AddResource(loc, doc); err != nil {
+		return nil, err
+	}
+	sch, err := c.Compile(loc)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Schema{schema: sch}, nil
+}
+
+// Validate returns every way in which doc breaks the schema. doc should be
+// decoded json, with numbers as json.Number
+func (s *Schema) Validate(doc any) []models.SchemaViolation {
+	err := s.schema.Validate(doc)
+	if err == nil {
+		return nil
+	}
+
+	var verr *jsonschema.ValidationError
+	if !errors.As(err, &verr) {
+		return []models.SchemaViolation{{Message: err.Error()}}
+	}
+
+	return leaves(verr.DetailedOutput(), nil)
+}
+
+// leaves collects the errors at the bottom of the tree. The ones above them just
+// say that something further down failed, which isn't much use to anyone
+func leaves(unit *jsonschema.OutputUnit, res []models.SchemaViolation) []models.SchemaViolation {
+	if len(unit.Errors) == 0 {
+		if unit.Error == nil {
+			return res
+		}
+		path := unit.InstanceLocation
+		if path == "" {
+			path = "/"
+		}
+		return append(res, models.SchemaViolation{
+			Path:    path,
+			Message: unit.Error.String(),
+		})
+	}
+
+	for i := range unit.Errors {
+		res = leaves(&unit.Errors[i], res)
+	}
+	return res
+}
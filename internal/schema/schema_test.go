@@ -0,0 +1,192 @@
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/jonny-burkholder/swarm/internal/models"
+)
+
+// book is the same schema in every format the tests load it in
+const book = `{
+	"type": "object",
+	"required": ["title", "pages"],
+	"properties": {
+		"title": {"type": "string"},
+		"pages": {"type": "integer", "minimum": 1}
+	}
+}`
+
+const bookYAML = `
+type: object
+required: [title, pages]
+properties:
+  title:
+    type: string
+  pages:
+    type: integer
+    minimum: 1
+`
+
+// decode decodes a response body the way the runner does
+func decode(t *testing.T, body string) any {
+	t.Helper()
+	dec := json.NewDecoder(bytes.NewReader([]byte(body)))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		t.Fatalf("decoding %s: %v", body, err)
+	}
+	return v
+}
+
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   map[string]string
+		load    string // the file to load
+		body    string
+		want    []models.SchemaViolation
+		wantErr bool
+	}{
+		{
+			name:  "json",
+			files: map[string]string{"book.json": book},
+			load:  "book.json",
+			body:  `{"title": "Memories of Ice", "pages": 925}`,
+		},
+		{
+			name:  "yaml",
+			files: map[string]string{"book.yml": bookYAML},
+			load:  "book.yml",
+			body:  `{"title": "Memories of Ice", "pages": 0}`,
+			want:  []models.SchemaViolation{{Path: "/pages", Message: "minimum: got 0, want 1"}},
+		},
+		{
+			name: "relative ref",
+			files: map[string]string{
+				"shelf.json":        `{"type": "array", "items": {"$ref": "schemas/book.yaml"}}`,
+				"schemas/book.yaml": bookYAML,
+			},
+			load: "shelf.json",
+			body: `[{"title": "Memories of Ice", "pages": 925}, {"title": 7, "pages": 1}]`,
+			want: []models.SchemaViolation{{Path: "/1/title", Message: "got number, want string"}},
+		},
+		{
+			name:    "missing ref",
+			files:   map[string]string{"shelf.json": `{"type": "array", "items": {"$ref": "book.json"}}`},
+			load:    "shelf.json",
+			wantErr: true,
+		},
+		{
+			name:    "invalid json",
+			files:   map[string]string{"book.json": `{"type": "object"`},
+			load:    "book.json",
+			wantErr: true,
+		},
+		{
+			name:    "missing file",
+			load:    "book.json",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := writeFiles(t, tt.files)
+			sch, err := Load(filepath.Join(dir, tt.load))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Load() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			if got := sch.Validate(decode(t, tt.body)); !slices.Equal(got, tt.want) {
+				t.Errorf("Validate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompile(t *testing.T) {
+	// the way an inline schema comes out of a collection's yaml
+	doc := map[string]any{
+		"type":     "object",
+		"required": []any{"title"},
+		"properties": map[string]any{
+			"title": map[string]any{"type": "string"},
+			"pages": map[string]any{"type": "integer", "minimum": 1},
+		},
+	}
+	sch, err := Compile("GET books", doc)
+	if err != nil {
+		t.Fatalf("Compile() error = %v", err)
+	}
+
+	tests := []struct {
+		name string
+		body string
+		want []models.SchemaViolation
+	}{
+		{
+			name: "valid",
+			body: `{"title": "Toll the Hounds", "pages": 1296}`,
+		},
+		{
+			name: "big integers are still integers",
+			body: `{"title": "Toll the Hounds", "pages": 9007199254740993}`,
+		},
+		{
+			name: "every leaf is reported",
+			body: `{"pages": 1.5}`,
+			want: []models.SchemaViolation{
+				{Path: "/", Message: "missing property 'title'"},
+				{Path: "/pages", Message: "got number, want integer"},
+			},
+		},
+		{
+			name: "not an object at all",
+			body: `[]`,
+			want: []models.SchemaViolation{{Path: "/", Message: "got array, want object"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := sch.Validate(decode(t, tt.body))
+			slices.SortFunc(got, func(a, b models.SchemaViolation) int {
+				return strings.Compare(a.Path, b.Path)
+			})
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Validate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompileInvalid(t *testing.T) {
+	if _, err := Compile("GET books", map[string]any{"type": 7}); err == nil {
+		t.Error("Compile() error = nil, want an error for an invalid schema")
+	}
+}
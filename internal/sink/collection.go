@@ -0,0 +1,45 @@
+package sink
+
+import "github.com/jonny-burkholder/swarm/internal/models"
+
+// Aggregator adds every run to its collection's aggregates
+type Aggregator struct{}
+
+func (Aggregator) Write(collection *models.Collection, run models.Run) error {
+	collection.Mu.Lock()
+	defer collection.Mu.Unlock()
+
+	if collection.Aggregates == nil {
+		collection.Aggregates = models.NewAggregates(collection.Requests)
+	}
+	collection.Aggregates.Add(run)
+
+	return nil
+}
+
+func (Aggregator) Close() error {
+	return nil
+}
+
+// Keep adds runs to their collection's Runs, so they can be looked at or saved
+// later. Keeping every run of a long benchmark takes a lot of memory, so
+// FailedOnly can be set to keep only the runs that didn't pass
+type Keep struct {
+	FailedOnly bool
+}
+
+func (k Keep) Write(collection *models.Collection, run models.Run) error {
+	if k.FailedOnly && run.Passed() {
+		return nil
+	}
+
+	collection.Mu.Lock()
+	defer collection.Mu.Unlock()
+	collection.Runs = append(collection.Runs, run)
+
+	return nil
+}
+
+func (Keep) Close() error {
+	return nil
+}
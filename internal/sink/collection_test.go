@@ -0,0 +1,55 @@
+package sink
+
+import (
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/jonny-burkholder/swarm/internal/models"
+)
+
+func TestKeep(t *testing.T) {
+	runs := []models.Run{
+		{ID: 1, Results: []models.Result{{StatusCode: 200}}},
+		{ID: 2, Results: []models.Result{{Error: errors.New("connection refused")}}},
+		{ID: 3, Results: []models.Result{{StatusCode: 200, Assertions: []models.Assertion{{Field: "status_code", Value: 201}}}}},
+		{ID: 4, Error: errors.New("cancelled")},
+	}
+
+	tests := []struct {
+		name    string
+		keep    Keep
+		wantIDs []int
+	}{
+		{
+			name:    "every run",
+			keep:    Keep{},
+			wantIDs: []int{1, 2, 3, 4},
+		},
+		{
+			name:    "failed only",
+			keep:    Keep{FailedOnly: true},
+			wantIDs: []int{2, 3, 4},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			collection := &models.Collection{Mu: &sync.Mutex{}}
+			for _, run := range runs {
+				if err := tt.keep.Write(collection, run); err != nil {
+					t.Fatalf("Write() error = %v", err)
+				}
+			}
+
+			ids := []int{}
+			for _, run := range collection.Runs {
+				ids = append(ids, run.ID)
+			}
+			if !slices.Equal(ids, tt.wantIDs) {
+				t.Errorf("kept runs %v, want %v", ids, tt.wantIDs)
+			}
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package sink
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"os"
+
+	"github.com/jonny-burkholder/swarm/internal/models"
+	"github.com/jonny-burkholder/swarm/internal/results"
+)
+
+// File writes every run to a file as a line of json, as soon as it's done, in the same
+// form runs are saved in results files. Runs are written whether they're kept or not
+type File struct {
+	f   *os.File
+	w   *bufio.Writer
+	enc *json.Encoder
+}
+
+// line is one line of the file
+type line struct {
+	Collection string      `json:"collection"`
+	Run        results.Run `json:"run"`
+}
+
+func NewFile(path string) (*File, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+
+	w := bufio.NewWriter(f)
+	return &File{
+		f:   f,
+		w:   w,
+		enc: json.NewEncoder(w),
+	}, nil
+}
+
+func (s *File) Write(collection *models.Collection, run models.Run) error {
+	return s.enc.Encode(line{Collection: collection.Name, Run: results.NewRun(run)})
+}
+
+func (s *File) Close() error {
+	return errors.Join(s.w.Flush(), s.f.Close())
+}
@@ -0,0 +1,96 @@
+/*
+sink is where finished runs go.
+
+The runner hands every run to a Sink as soon as it comes back from a worker. What happens to it
+then is up to the sink: it can be aggregated, kept in memory, written to a file, shown on screen,
+or any combination of those, so that a long benchmark doesn't have to hold on to every result
+*/
+package sink
+
+import (
+	"errors"
+
+	"github.com/jonny-burkholder/swarm/internal/models"
+)
+
+type Sink interface {
+	// Write is called with every finished run of a collection. It's never
+	// called from more than one goroutine at a time
+	Write(collection *models.Collection, run models.Run) error
+	// Close is called once there are no more runs
+	Close() error
+}
+
+// Multi writes every run to each of sinks in turn
+func Multi(sinks ...Sink) Sink {
+	return multi(sinks)
+}
+
+type multi []Sink
+
+func (m multi) Write(collection *models.Collection, run models.Run) error {
+	errs := []error{}
+	for _, s := range m {
+		if err := s.Write(collection, run); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (m multi) Close() error {
+	errs := []error{}
+	for _, s := range m {
+		if err := s.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Buffered hands runs to another sink on a separate goroutine, so that a slow sink doesn't
+// hold up the runner until it has to. Once size runs are waiting, Write blocks until there's
+// room, which in turn stops the runner handing out new runs until the sink catches up
+type Buffered struct {
+	sink Sink
+	runs chan entry
+	done chan struct{}
+	err  error // the first error from the sink
+}
+
+type entry struct {
+	collection *models.Collection
+	run        models.Run
+}
+
+func NewBuffered(s Sink, size int) *Buffered {
+	b := &Buffered{
+		sink: s,
+		runs: make(chan entry, size),
+		done: make(chan struct{}),
+	}
+
+	go func() {
+		defer close(b.done)
+		for e := range b.runs {
+			if err := b.sink.Write(e.collection, e.run); err != nil && b.err == nil {
+				b.err = err
+			}
+		}
+	}()
+
+	return b
+}
+
+// Write queues a run for the sink. Errors from the sink come back from Close
+func (b *Buffered) Write(collection *models.Collection, run models.Run) error {
+	b.runs <- entry{collection: collection, run: run}
+	return nil
+}
+
+// Close waits for every queued run to be written, then closes the sink
+func (b *Buffered) Close() error {
+	close(b.runs)
+	<-b.done
+	return errors.Join(b.err, b.sink.Close())
+}
@@ -0,0 +1,153 @@
+package sink
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/jonny-burkholder/swarm/internal/models"
+)
+
+// recorder remembers the ids of the runs written to it. If gate is set, each
+// write waits for it first, like a sink that's falling behind
+type recorder struct {
+	ids      []int
+	gate     chan struct{}
+	writeErr error
+	closeErr error
+	closed   bool
+}
+
+func (r *recorder) Write(collection *models.Collection, run models.Run) error {
+	if r.gate != nil {
+		<-r.gate
+	}
+	r.ids = append(r.ids, run.ID)
+	return r.writeErr
+}
+
+func (r *recorder) Close() error {
+	r.closed = true
+	return r.closeErr
+}
+
+func TestBufferedOrder(t *testing.T) {
+	r := &recorder{}
+	b := NewBuffered(r, 4)
+	for id := 1; id <= 100; id++ {
+		b.Write(nil, models.Run{ID: id})
+	}
+	if err := b.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	if len(r.ids) != 100 || !slices.IsSorted(r.ids) {
+		t.Errorf("sink got runs %v, want 1 to 100 in order", r.ids)
+	}
+	if !r.closed {
+		t.Error("sink wasn't closed")
+	}
+}
+
+func TestBufferedBackpressure(t *testing.T) {
+	r := &recorder{gate: make(chan struct{})}
+	b := NewBuffered(r, 2)
+
+	// the first is taken straight away and held up by the gate, the next two fill the buffer
+	for id := 1; id <= 3; id++ {
+		b.Write(nil, models.Run{ID: id})
+	}
+	written := make(chan struct{})
+	go func() {
+		b.Write(nil, models.Run{ID: 4})
+		close(written)
+	}()
+
+	select {
+	case <-written:
+		t.Fatal("Write() returned while the buffer was full")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(r.gate)
+	select {
+	case <-written:
+	case <-time.After(time.Second):
+		t.Fatal("Write() still blocked once the sink caught up")
+	}
+	if err := b.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if !slices.Equal(r.ids, []int{1, 2, 3, 4}) {
+		t.Errorf("sink got runs %v, want [1 2 3 4]", r.ids)
+	}
+}
+
+func TestBufferedClose(t *testing.T) {
+	errWrite, errClose := errors.New("write failed"), errors.New("close failed")
+	tests := []struct {
+		name     string
+		sink     *recorder
+		wantErrs []error
+	}{
+		{
+			name: "no errors",
+			sink: &recorder{},
+		},
+		{
+			name:     "close error",
+			sink:     &recorder{closeErr: errClose},
+			wantErrs: []error{errClose},
+		},
+		{
+			name:     "write and close errors",
+			sink:     &recorder{writeErr: errWrite, closeErr: errClose},
+			wantErrs: []error{errWrite, errClose},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := NewBuffered(tt.sink, 1)
+			if err := b.Write(nil, models.Run{ID: 1}); err != nil {
+				t.Fatalf("Write() error = %v, want errors to wait for Close", err)
+			}
+
+			err := b.Close()
+			if (err != nil) != (len(tt.wantErrs) > 0) {
+				t.Fatalf("Close() error = %v, want %v", err, tt.wantErrs)
+			}
+			for _, want := range tt.wantErrs {
+				if !errors.Is(err, want) {
+					t.Errorf("Close() error = %v, want it to include %v", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestMulti(t *testing.T) {
+	errA, errB := errors.New("a failed"), errors.New("b failed")
+	a := &recorder{writeErr: errA}
+	b := &recorder{}
+	c := &recorder{writeErr: errB, closeErr: errB}
+	m := Multi(a, b, c)
+
+	err := m.Write(nil, models.Run{ID: 1})
+	if !errors.Is(err, errA) || !errors.Is(err, errB) {
+		t.Errorf("Write() error = %v, want both sinks' errors", err)
+	}
+	for i, s := range []*recorder{a, b, c} {
+		if !slices.Equal(s.ids, []int{1}) {
+			t.Errorf("sink %d got runs %v, want [1], even after another failed", i, s.ids)
+		}
+	}
+
+	if err := m.Close(); !errors.Is(err, errB) {
+		t.Errorf("Close() error = %v, want %v", err, errB)
+	}
+	if !a.closed || !b.closed || !c.closed {
+		t.Error("not every sink was closed")
+	}
+}
@@ -0,0 +1,146 @@
+package stats
+
+import (
+	"math"
+	"math/rand/v2"
+	"slices"
+
+	"github.com/jonny-burkholder/swarm/internal/histogram"
+)
+
+// Interval is a confidence interval
+type Interval struct {
+	Low  float64 `json:"low"`
+	High float64 `json:"high"`
+}
+
+// Contains is true if v is inside the interval
+func (i Interval) Contains(v float64) bool {
+	return i.Low <= v && v <= i.High
+}
+
+// Bootstrap works out confidence intervals for how much quantiles changed between
+// baseline and candidate, by resampling both over and over and seeing how much the
+// difference moves around
+type Bootstrap struct {
+	Resamples  int
+	Confidence float64 // e.g. 0.95
+	Seed       uint64  // so that the same files always give the same intervals
+}
+
+// QuantileDiffs returns an interval for candidate minus baseline at each of quantiles. It
+// returns nil if there's nothing to resample, because either histogram is empty or Resamples is 0
+func (b Bootstrap) QuantileDiffs(baseline, candidate *histogram.Histogram, quantiles ...float64) []Interval {
+	if baseline.Count() == 0 || candidate.Count() == 0 || b.Resamples <= 0 {
+		return nil
+	}
+	res := make([]Interval, len(quantiles))
+
+	rng := rand.New(rand.NewPCG(b.Seed, b.Seed))
+	base, cand := newSampler(baseline), newSampler(candidate)
+	diffs := make([][]float64, len(quantiles))
+	for range b.Resamples {
+		bs, cs := base.resample(rng), cand.resample(rng)
+		for i, q := range quantiles {
+			diffs[i] = append(diffs[i], float64(cs.quantile(q)-bs.quantile(q)))
+		}
+	}
+
+	alpha := (1 - b.Confidence) / 2
+	for i, d := range diffs {
+		slices.Sort(d)
+		res[i] = Interval{Low: pick(d, alpha), High: pick(d, 1-alpha)}
+	}
+
+	return res
+}
+
+// pick takes the q quantile of sorted values
+func pick(sorted []float64, q float64) float64 {
+	i := int(math.Round(q * float64(len(sorted)-1)))
+	return sorted[min(max(i, 0), len(sorted)-1)]
+}
+
+// sampler resamples a histogram. Drawing every value one at a time would be far too slow
+// for millions of requests, so instead each bucket's count is drawn from a binomial, given
+// how many values are still to be drawn and how many were in the buckets that are left
+type sampler struct {
+	values []int64
+	counts []uint64
+	total  uint64
+}
+
+func newSampler(h *histogram.Histogram) *sampler {
+	s := &sampler{total: h.Count()}
+	for v, c := range h.Buckets() {
+		s.values = append(s.values, v)
+		s.counts = append(s.counts, c)
+	}
+	return s
+}
+
+func (s *sampler) resample(rng *rand.Rand) *sampler {
+	res := &sampler{values: s.values, counts: make([]uint64, len(s.counts)), total: s.total}
+	left, leftCount := s.total, s.total
+	for i, c := range s.counts {
+		if left == 0 {
+			break
+		}
+		n := left
+		if i < len(s.counts)-1 {
+			n = binomial(rng, left, float64(c)/float64(leftCount))
+		}
+		res.counts[i] = n
+		left -= n
+		leftCount -= c
+	}
+	return res
+}
+
+func (s *sampler) quantile(q float64) int64 {
+	want := max(uint64(math.Ceil(q*float64(s.total))), 1)
+	seen := uint64(0)
+	for i, c := range s.counts {
+		seen += c
+		if seen >= want {
+			return s.values[i]
+		}
+	}
+	return s.values[len(s.values)-1]
+}
+
+// binomial draws from a binomial distribution. When it's very lopsided successes (or failures)
+// are counted one at a time, by skipping ahead geometrically distributed numbers of trials,
+// otherwise the normal approximation is close enough
+func binomial(rng *rand.Rand, n uint64, p float64) uint64 {
+	switch {
+	case p <= 0:
+		return 0
+	case p >= 1:
+		return n
+	}
+
+	mean := float64(n) * p
+	if mean < 30 {
+		return rareSuccesses(rng, n, p)
+	}
+	if float64(n)-mean < 30 {
+		return n - rareSuccesses(rng, n, 1-p)
+	}
+
+	x := math.Round(mean + rng.NormFloat64()*math.Sqrt(mean*(1-p)))
+	return uint64(min(max(x, 0), float64(n)))
+}
+
+func rareSuccesses(rng *rand.Rand, n uint64, p float64) uint64 {
+	logq := math.Log1p(-p)
+	successes, trial := uint64(0), 0.0
+	for {
+		// the number of trials until the next success
+		trial += math.Floor(math.Log(1-rng.Float64())/logq) + 1
+		if trial > float64(n) {
+			return successes
+		}
+		successes++
+	}
+}
@@ -0,0 +1,142 @@
+package stats
+
+import (
+	"math"
+	"math/rand/v2"
+	"slices"
+	"testing"
+
+	"github.com/jonny-burkholder/swarm/internal/histogram"
+)
+
+func TestQuantileDiffs(t *testing.T) {
+	b := Bootstrap{Resamples: 200, Confidence: 0.95, Seed: 1}
+
+	tests := []struct {
+		name      string
+		boot      Bootstrap
+		baseline  *histogram.Histogram
+		candidate *histogram.Histogram
+		wantNil   bool
+		contains  []float64 // per quantile, a difference the interval should contain
+		excludes  []float64 // and one it shouldn't
+	}{
+		{
+			name:      "empty baseline",
+			boot:      b,
+			baseline:  hist(),
+			candidate: span(1, 100),
+			wantNil:   true,
+		},
+		{
+			name:      "empty candidate",
+			boot:      b,
+			baseline:  span(1, 100),
+			candidate: hist(),
+			wantNil:   true,
+		},
+		{
+			name:      "no resamples",
+			boot:      Bootstrap{Confidence: 0.95},
+			baseline:  span(1, 100),
+			candidate: span(1, 100),
+			wantNil:   true,
+		},
+		{
+			name:      "no change",
+			boot:      b,
+			baseline:  span(1, 1000),
+			candidate: span(1, 1000),
+			contains:  []float64{0, 0},
+			excludes:  []float64{100, 100},
+		},
+		{
+			name:      "shifted by 100",
+			boot:      b,
+			baseline:  span(1, 1000),
+			candidate: span(101, 1100),
+			contains:  []float64{100, 100},
+			excludes:  []float64{0, 0},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.boot.QuantileDiffs(tt.baseline, tt.candidate, 0.5, 0.9)
+			if tt.wantNil {
+				if got != nil {
+					t.Errorf("QuantileDiffs() = %v, want nil", got)
+				}
+				return
+			}
+			if len(got) != 2 {
+				t.Fatalf("QuantileDiffs() = %v, want 2 intervals", got)
+			}
+			for i, in := range got {
+				if !in.Contains(tt.contains[i]) {
+					t.Errorf("interval %d = %+v, want it to contain %v", i, in, tt.contains[i])
+				}
+				if in.Contains(tt.excludes[i]) {
+					t.Errorf("interval %d = %+v, want it to leave out %v", i, in, tt.excludes[i])
+				}
+			}
+		})
+	}
+}
+
+func TestQuantileDiffsSeed(t *testing.T) {
+	baseline, candidate := span(1, 1000), span(51, 1050)
+
+	tests := []struct {
+		name     string
+		a, b     uint64
+		wantSame bool
+	}{
+		{name: "same seed", a: 7, b: 7, wantSame: true},
+		{name: "different seed", a: 7, b: 8, wantSame: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := Bootstrap{Resamples: 100, Confidence: 0.95, Seed: tt.a}.QuantileDiffs(baseline, candidate, 0.5, 0.99)
+			b := Bootstrap{Resamples: 100, Confidence: 0.95, Seed: tt.b}.QuantileDiffs(baseline, candidate, 0.5, 0.99)
+			if same := slices.Equal(a, b); same != tt.wantSame {
+				t.Errorf("seeds %d and %d gave %v and %v, want the same = %v", tt.a, tt.b, a, b, tt.wantSame)
+			}
+		})
+	}
+}
+
+func TestBinomial(t *testing.T) {
+	tests := []struct {
+		name string
+		n    uint64
+		p    float64
+	}{
+		{name: "never", n: 100, p: 0},
+		{name: "always", n: 100, p: 1},
+		{name: "rare", n: 1000, p: 0.001},
+		{name: "common", n: 1000, p: 0.999},
+		{name: "normal", n: 100000, p: 0.3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rng := rand.New(rand.NewPCG(1, 1))
+			const draws = 2000
+			sum := 0.0
+			for range draws {
+				x := binomial(rng, tt.n, tt.p)
+				if x > tt.n {
+					t.Fatalf("binomial(%d, %v) = %d, more than n", tt.n, tt.p, x)
+				}
+				sum += float64(x)
+			}
+			// the mean of the draws should be well within 5 standard errors of np
+			mean, want := sum/draws, float64(tt.n)*tt.p
+			if se := math.Sqrt(want * (1 - tt.p) / draws); math.Abs(mean-want) > 5*se+1e-9 {
+				t.Errorf("binomial(%d, %v) averaged %v, want about %v", tt.n, tt.p, mean, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+/*
+stats has the tests compare uses to tell real changes in latency from noise.
+
+Everything works on histograms rather than raw latencies, since that's what results files keep.
+Values in the same histogram bucket are treated as ties, which at 0.1% precision makes no real
+difference
+*/
+package stats
+
+import (
+	"iter"
+	"math"
+
+	"github.com/jonny-burkholder/swarm/internal/histogram"
+)
+
+// MannWhitney is the result of a Mann-Whitney U test
+type MannWhitney struct {
+	U float64 // for the candidate
+	P float64 // two-sided
+	// the chance that a request from the candidate is slower than one from the baseline,
+	// counting ties as half. 0.5 means neither is faster
+	ProbSlower float64
+}
+
+// MannWhitneyU tests whether the latencies in candidate tend to be larger or smaller than
+// the ones in baseline. It uses the normal approximation with a correction for ties, which
+// is plenty accurate for the number of requests in a benchmark
+func MannWhitneyU(baseline, candidate *histogram.Histogram) MannWhitney {
+	n1, n2 := float64(baseline.Count()), float64(candidate.Count())
+	if n1 == 0 || n2 == 0 {
+		return MannWhitney{P: 1, ProbSlower: 0.5}
+	}
+	n := n1 + n2
+
+	// walk both histograms in order, giving each group of ties their average rank
+	rank2, ties := 0.0, 0.0
+	seen := 0.0
+	for _, c := range merge(baseline.Buckets(), candidate.Buckets()) {
+		t := float64(c[0] + c[1])
+		avg := seen + (t+1)/2
+		rank2 += float64(c[1]) * avg
+		ties += t*t*t - t
+		seen += t
+	}
+
+	u2 := rank2 - n2*(n2+1)/2
+	mean := n1 * n2 / 2
+	res := MannWhitney{U: u2, P: 1, ProbSlower: u2 / (n1 * n2)}
+
+	sigma := math.Sqrt(n1 * n2 / 12 * ((n + 1) - ties/(n*(n-1))))
+	if sigma == 0 {
+		// every value is the same
+		return res
+	}
+	diff := math.Abs(u2-mean) - 0.5 // continuity correction
+	if diff <= 0 {
+		return res
+	}
+	res.P = math.Erfc(diff / sigma / math.Sqrt2)
+
+	return res
+}
+
+// merge walks two sorted lots of buckets together, yielding each value
+// with its count in a and its count in b
+func merge(a, b iter.Seq2[int64, uint64]) iter.Seq2[int64, [2]uint64] {
+	return func(yield func(int64, [2]uint64) bool) {
+		nextA, stopA := iter.Pull2(a)
+		defer stopA()
+		nextB, stopB := iter.Pull2(b)
+		defer stopB()
+
+		va, ca, okA := nextA()
+		vb, cb, okB := nextB()
+		for okA || okB {
+			var v int64
+			var counts [2]uint64
+			switch {
+			case okA && (!okB || va < vb):
+				v, counts = va, [2]uint64{ca, 0}
+				va, ca, okA = nextA()
+			case okB && (!okA || vb < va):
+				v, counts = vb, [2]uint64{0, cb}
+				vb, cb, okB = nextB()
+			default:
+				// the same value in both
+				v, counts = va, [2]uint64{ca, cb}
+				va, ca, okA = nextA()
+				vb, cb, okB = nextB()
+			}
+			if !yield(v, counts) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package stats
+
+import (
+	"math"
+	"testing"
+
+	"github.com/jonny-burkholder/swarm/internal/histogram"
+)
+
+// hist records values into a new histogram
+func hist(values ...int64) *histogram.Histogram {
+	h := histogram.New()
+	for _, v := range values {
+		h.Record(v)
+	}
+	return h
+}
+
+// span records every value from lo to hi
+func span(lo, hi int64) *histogram.Histogram {
+	h := histogram.New()
+	for v := lo; v <= hi; v++ {
+		h.Record(v)
+	}
+	return h
+}
+
+func TestMannWhitneyU(t *testing.T) {
+	// p-values worked out by hand with the normal approximation, continuity
+	// correction and tie correction, which is what scipy's asymptotic method gives
+	tests := []struct {
+		name      string
+		baseline  *histogram.Histogram
+		candidate *histogram.Histogram
+		want      MannWhitney
+	}{
+		{
+			name:      "empty",
+			baseline:  hist(),
+			candidate: hist(1, 2, 3),
+			want:      MannWhitney{P: 1, ProbSlower: 0.5},
+		},
+		{
+			name:      "the same",
+			baseline:  hist(1, 2, 3),
+			candidate: hist(1, 2, 3),
+			want:      MannWhitney{U: 4.5, P: 1, ProbSlower: 0.5},
+		},
+		{
+			name:      "every value the same",
+			baseline:  hist(5, 5, 5),
+			candidate: hist(5, 5),
+			want:      MannWhitney{U: 3, P: 1, ProbSlower: 0.5},
+		},
+		{
+			name:      "candidate all slower",
+			baseline:  hist(1, 2, 3, 4, 5),
+			candidate: hist(6, 7, 8, 9, 10),
+			want:      MannWhitney{U: 25, P: 0.012186, ProbSlower: 1},
+		},
+		{
+			name:      "candidate all faster",
+			baseline:  hist(6, 7, 8, 9, 10),
+			candidate: hist(1, 2, 3, 4, 5),
+			want:      MannWhitney{U: 0, P: 0.012186, ProbSlower: 0},
+		},
+		{
+			name:      "ties",
+			baseline:  hist(1, 2, 2, 3),
+			candidate: hist(2, 3, 3, 4),
+			want:      MannWhitney{U: 13, P: 0.172034, ProbSlower: 0.8125},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := MannWhitneyU(tt.baseline, tt.candidate)
+			if got.U != tt.want.U || got.ProbSlower != tt.want.ProbSlower || math.Abs(got.P-tt.want.P) > 1e-5 {
+				t.Errorf("MannWhitneyU() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
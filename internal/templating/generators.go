@@ -0,0 +1,119 @@
+package templating
+
+import (
+	"fmt"
+	"strings"
+)
+
+const alphanumeric = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+// randInt returns a number between lo and hi, inclusive
+func (e *Engine) randInt(lo, hi int) int {
+	if hi < lo {
+		lo, hi = hi, lo
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return lo + e.rand.IntN(hi-lo+1)
+}
+
+func (e *Engine) randString(n int) string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	b := make([]byte, max(n, 0))
+	for i := range b {
+		b[i] = alphanumeric[e.rand.IntN(len(alphanumeric))]
+	}
+	return string(b)
+}
+
+func (e *Engine) pick(options ...any) any {
+	if len(options) == 0 {
+		return ""
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return options[e.rand.IntN(len(options))]
+}
+
+func (e *Engine) pickFrom(options []string) string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return options[e.rand.IntN(len(options))]
+}
+
+// uuid returns a random, version 4 uuid. It doesn't need to be
+// cryptographically random, just different every time
+func (e *Engine) uuid() string {
+	e.mu.Lock()
+	hi, lo := e.rand.Uint64(), e.rand.Uint64()
+	e.mu.Unlock()
+
+	hi = (hi &^ 0xf000) | 0x4000           // version 4
+	lo = (lo &^ (0xc << 60)) | (0x8 << 60) // variant 10
+	return fmt.Sprintf("%08x-%04x-%04x-%04x-%012x",
+		hi>>32, (hi>>16)&0xffff, hi&0xffff, lo>>48, lo&0xffffffffffff)
+}
+
+func (e *Engine) seq(name string) int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.seqs[name]++
+	return e.seqs[name]
+}
+
+func (e *Engine) runSeq(name string) int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.runSeqs[name]++
+	return e.runSeqs[name]
+}
+
+func (e *Engine) currentIteration() int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.iteration
+}
+
+func (e *Engine) name() string {
+	return e.pickFrom(e.lists.firstNames) + " " + e.pickFrom(e.lists.lastNames)
+}
+
+func (e *Engine) username() string {
+	return strings.ToLower(e.pickFrom(e.lists.firstNames)) + fmt.Sprint(e.randInt(1, 9999))
+}
+
+// email addresses use example.com, which is reserved for exactly this
+func (e *Engine) email() string {
+	return fmt.Sprintf("%s.%s%d@example.com",
+		strings.ToLower(e.pickFrom(e.lists.firstNames)), strings.ToLower(e.pickFrom(e.lists.lastNames)), e.randInt(1, 9999))
+}
+
+// wordLists are what the name, word and city generators pick from. Each engine
+// gets its own when it's made
+type wordLists struct {
+	firstNames, lastNames, words, cities []string
+}
+
+func newWordLists() wordLists {
+	return wordLists{
+		firstNames: []string{
+			"Alice", "Bob", "Carol", "Dave", "Eve", "Frank", "Grace", "Heidi", "Ivan", "Judy",
+			"Karl", "Laura", "Mallory", "Nina", "Oscar", "Peggy", "Quinn", "Rupert", "Sybil", "Trent",
+			"Uma", "Victor", "Wendy", "Xavier", "Yara", "Zed", "Amara", "Bao", "Chidi", "Dmitri",
+		},
+		lastNames: []string{
+			"Smith", "Johnson", "Williams", "Brown", "Jones", "Garcia", "Miller", "Davis", "Martinez", "Lopez",
+			"Wilson", "Anderson", "Thomas", "Taylor", "Moore", "Nguyen", "Kim", "Okafor", "Ivanova", "Rossi",
+			"Meyer", "Silva", "Tanaka", "Khan", "Cohen", "Novak", "Jensen", "Dubois", "Kowalski", "Singh",
+		},
+		words: []string{
+			"apple", "river", "stone", "cloud", "ember", "forest", "harbor", "lantern", "meadow", "orbit",
+			"pepper", "quartz", "rocket", "saddle", "thunder", "umbrella", "velvet", "willow", "yonder", "zephyr",
+		},
+		cities: []string{
+			"Lisbon", "Nairobi", "Osaka", "Toronto", "Melbourne", "Bogotá", "Reykjavik", "Seoul", "Denver", "Kraków",
+			"Cairo", "Lima", "Oslo", "Chennai", "Austin", "Hanoi", "Dublin", "Quito", "Perth", "Porto",
+		},
+	}
+}
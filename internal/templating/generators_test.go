@@ -0,0 +1,91 @@
+package templating
+
+import (
+	"regexp"
+	"strconv"
+	"testing"
+)
+
+func TestGenerators(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		want     string // a regular expression the whole output has to match
+	}{
+		{name: "randInt", template: `{{randInt 1 3}}`, want: `[1-3]`},
+		{name: "randInt backwards", template: `{{randInt 3 1}}`, want: `[1-3]`},
+		{name: "randInt one value", template: `{{randInt 7 7}}`, want: `7`},
+		{name: "randString", template: `{{randString 12}}`, want: `[a-zA-Z0-9]{12}`},
+		{name: "randString negative", template: `{{randString -1}}`, want: ``},
+		{name: "pick", template: `{{pick "a" "b" "c"}}`, want: `[abc]`},
+		{name: "pick nothing", template: `{{pick}}`, want: ``},
+		{name: "uuid", template: `{{uuid}}`, want: `[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}`},
+		{name: "timestamp", template: `{{timestamp}}`, want: `[0-9]{10}`},
+		{name: "timestampMs", template: `{{timestampMs}}`, want: `[0-9]{13}`},
+		{name: "now", template: `{{now.Format "2006"}}`, want: `20[0-9]{2}`},
+		{name: "worker", template: `{{worker}}`, want: `3`},
+		{name: "name", template: `{{name}}`, want: `[A-Z][a-z]+ [A-Z][a-z]+`},
+		{name: "email", template: `{{email}}`, want: `[a-z]+\.[a-z]+[0-9]{1,4}@example\.com`},
+		{name: "username", template: `{{username}}`, want: `[a-z]+[0-9]{1,4}`},
+		{name: "word", template: `{{word}}`, want: `[a-z]+`},
+		{name: "city", template: `{{city}}`, want: `\pL+`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := New(3)
+			want := regexp.MustCompile(`^` + tt.want + `$`)
+			for range 20 {
+				got, err := e.Render(tt.template, nil)
+				if err != nil {
+					t.Fatalf("Render(%s) error = %v", tt.template, err)
+				}
+				if !want.MatchString(got) {
+					t.Fatalf("Render(%s) = %q, want a match for %s", tt.template, got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestSequences(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		runs     int // runs, each rendering the template twice
+		want     []int
+	}{
+		{name: "seq counts across runs", template: `{{seq "id"}}`, runs: 2, want: []int{1, 2, 3, 4}},
+		{name: "runSeq starts again every run", template: `{{runSeq "id"}}`, runs: 2, want: []int{1, 2, 1, 2}},
+		{name: "iteration", template: `{{iteration}}`, runs: 2, want: []int{1, 1, 2, 2}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := New(0)
+			var got []int
+			for range tt.runs {
+				e.NewRun()
+				for range 2 {
+					s, err := e.Render(tt.template, nil)
+					if err != nil {
+						t.Fatalf("Render(%s) error = %v", tt.template, err)
+					}
+					n, err := strconv.Atoi(s)
+					if err != nil {
+						t.Fatalf("Render(%s) = %q, want a number", tt.template, s)
+					}
+					got = append(got, n)
+				}
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("got %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
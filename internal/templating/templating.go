@@ -0,0 +1,179 @@
+/*
+	templating fills in the variables in a request's path, headers, query params
+
+and body, using go templates. Variables are referenced like {{.token}}, or
+{{index . "some-name"}} if the name isn't a valid go identifier. There are also
+built in functions for generating data, so that every request isn't identical:
+
+	{{randInt 1 100}}      a random number between 1 and 100, inclusive
+	{{randString 12}}      12 random letters and numbers
+	{{pick "a" "b" "c"}}   one of the arguments, at random
+	{{uuid}}               a random (v4) uuid
+	{{now}}                the current time, e.g. {{now.Format "2006-01-02"}}
+	{{timestamp}}          the current unix time in seconds
+	{{timestampMs}}        the current unix time in milliseconds
+	{{seq "name"}}         a counter for this worker, starting at 1, that counts up every time it's used
+	{{runSeq "name"}}      like seq, but starting again at 1 every run
+	{{worker}}             the number of the worker sending the request
+	{{iteration}}          how many runs this worker has started, including this one
+	{{env "NAME"}}         the environment variable NAME
+	{{firstName}}, {{lastName}}, {{name}}, {{email}}, {{username}}, {{word}}, {{city}}
+	{{json .value}}        the value as a json string, quotes and all, for putting in bodies
+*/
+package templating
+
+import (
+	"encoding/json"
+	"maps"
+	"math/rand/v2"
+	"os"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/jonny-burkholder/swarm/internal/models"
+)
+
+// Engine renders templates. Each worker should have its own, since the sequence
+// counters belong to the engine, but it's safe to use from several goroutines
+type Engine struct {
+	worker    int
+	funcs     template.FuncMap
+	templates sync.Map // parsed templates, since the same handful of strings are rendered over and over
+
+	mu        sync.Mutex
+	rand      *rand.Rand
+	seqs      map[string]int
+	runSeqs   map[string]int
+	iteration int
+	lists     wordLists
+}
+
+// New creates an engine for the given worker
+func New(worker int) *Engine {
+	e := &Engine{
+		worker:  worker,
+		rand:    rand.New(rand.NewPCG(rand.Uint64(), rand.Uint64())),
+		seqs:    map[string]int{},
+		runSeqs: map[string]int{},
+		lists:   newWordLists(),
+	}
+
+	e.funcs = template.FuncMap{
+		"randInt":     e.randInt,
+		"randString":  e.randString,
+		"pick":        e.pick,
+		"uuid":        e.uuid,
+		"now":         time.Now,
+		"timestamp":   func() int64 { return time.Now().Unix() },
+		"timestampMs": func() int64 { return time.Now().UnixMilli() },
+		"seq":         e.seq,
+		"runSeq":      e.runSeq,
+		"worker":      func() int { return e.worker },
+		"iteration":   e.currentIteration,
+		"env":         os.Getenv,
+		"firstName":   func() string { return e.pickFrom(e.lists.firstNames) },
+		"lastName":    func() string { return e.pickFrom(e.lists.lastNames) },
+		"name":        e.name,
+		"email":       e.email,
+		"username":    e.username,
+		"word":        func() string { return e.pickFrom(e.lists.words) },
+		"city":        func() string { return e.pickFrom(e.lists.cities) },
+		"json":        toJSON,
+	}
+
+	return e
+}
+
+// NewRun starts the run-scoped counters again. Workers call it at the start of every run
+func (e *Engine) NewRun() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	clear(e.runSeqs)
+	e.iteration++
+}
+
+// Check makes sure s is a valid template, without rendering it
+func Check(s string) error {
+	_, err := New(0).parse(s)
+	return err
+}
+
+// Render fills in the variables in s. Referencing a variable
+// that doesn't exist is an error
+func (e *Engine) Render(s string, vars map[string]string) (string, error) {
+	// most strings won't have anything in them to fill in
+	if !strings.Contains(s, "{{") {
+		return s, nil
+	}
+
+	tmpl, err := e.parse(s)
+	if err != nil {
+		return "", err
+	}
+
+	sb := &strings.Builder{}
+	if err := tmpl.Execute(sb, vars); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
+
+func (e *Engine) parse(s string) (*template.Template, error) {
+	if tmpl, ok := e.templates.Load(s); ok {
+		return tmpl.(*template.Template), nil
+	}
+
+	tmpl, err := template.New("").Funcs(e.funcs).Option("missingkey=error").Parse(s)
+	if err != nil {
+		return nil, err
+	}
+	e.templates.Store(s, tmpl)
+
+	return tmpl, nil
+}
+
+// Request returns a copy of req with the variables filled in
+// in its path, headers, query params and body
+func (e *Engine) Request(req models.Request, vars map[string]string) (models.Request, error) {
+	var err error
+
+	if req.Path, err = e.Render(req.Path, vars); err != nil {
+		return req, err
+	}
+
+	headers := maps.Clone(req.Headers)
+	for k, v := range headers {
+		if headers[k], err = e.Render(v, vars); err != nil {
+			return req, err
+		}
+	}
+	req.Headers = headers
+
+	params := make(map[string][]string, len(req.QueryParams))
+	for k, values := range req.QueryParams {
+		params[k] = make([]string, len(values))
+		for i, v := range values {
+			if params[k][i], err = e.Render(v, vars); err != nil {
+				return req, err
+			}
+		}
+	}
+	req.QueryParams = params
+
+	if len(req.Body) > 0 {
+		body, err := e.Render(string(req.Body), vars)
+		if err != nil {
+			return req, err
+		}
+		req.Body = []byte(body)
+	}
+
+	return req, nil
+}
+
+func toJSON(v any) (string, error) {
+	b, err := json.Marshal(v)
+	return string(b), err
+}
@@ -0,0 +1,123 @@
+package templating
+
+import (
+	"testing"
+
+	"github.com/jonny-burkholder/swarm/internal/models"
+)
+
+func TestRender(t *testing.T) {
+	vars := map[string]string{"token": "abc", "user-id": "42", "name": `Jo "JB" B`}
+
+	tests := []struct {
+		name     string
+		template string
+		want     string
+		wantErr  bool
+	}{
+		{name: "nothing to fill in", template: "/books", want: "/books"},
+		{name: "variable", template: "Bearer {{.token}}", want: "Bearer abc"},
+		{name: "name that isn't an identifier", template: `/users/{{index . "user-id"}}`, want: "/users/42"},
+		{name: "json", template: `{"name":{{json .name}}}`, want: `{"name":"Jo \"JB\" B"}`},
+		{name: "env", template: `{{env "SWARM_TEST_VAR"}}`, want: "set"},
+		{name: "missing variable", template: "{{.missing}}", wantErr: true},
+		{name: "bad template", template: "{{.token", wantErr: true},
+		{name: "unknown function", template: "{{nope}}", wantErr: true},
+	}
+
+	t.Setenv("SWARM_TEST_VAR", "set")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := New(0).Render(tt.template, vars)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Render(%s) error = %v, want error %v", tt.template, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Render(%s) = %q, want %q", tt.template, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		wantErr  bool
+	}{
+		{name: "plain", template: "/books"},
+		{name: "variable", template: "{{.id}}"},
+		{name: "generator", template: "{{randInt 1 10}}"},
+		{name: "unclosed", template: "{{.id", wantErr: true},
+		{name: "unknown function", template: "{{nope}}", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := Check(tt.template); (err != nil) != tt.wantErr {
+				t.Errorf("Check(%s) error = %v, want error %v", tt.template, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRequest(t *testing.T) {
+	req := models.Request{
+		Path:        "/books/{{.id}}",
+		Headers:     map[string]string{"Authorization": "Bearer {{.token}}"},
+		QueryParams: map[string][]string{"tag": {"{{.tag}}", "fixed"}},
+		Body:        []byte(`{"id":"{{.id}}"}`),
+	}
+
+	tests := []struct {
+		name       string
+		vars       map[string]string
+		wantPath   string
+		wantHeader string
+		wantParams []string
+		wantBody   string
+		wantErr    bool
+	}{
+		{
+			name:       "everything filled in",
+			vars:       map[string]string{"id": "7", "token": "abc", "tag": "new"},
+			wantPath:   "/books/7",
+			wantHeader: "Bearer abc",
+			wantParams: []string{"new", "fixed"},
+			wantBody:   `{"id":"7"}`,
+		},
+		{
+			name:    "missing variable",
+			vars:    map[string]string{"id": "7"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := New(0).Request(req, tt.vars)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Request() error = %v, want error %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got.Path != tt.wantPath {
+				t.Errorf("Path = %q, want %q", got.Path, tt.wantPath)
+			}
+			if h := got.Headers["Authorization"]; h != tt.wantHeader {
+				t.Errorf("Authorization = %q, want %q", h, tt.wantHeader)
+			}
+			if p := got.QueryParams["tag"]; len(p) != 2 || p[0] != tt.wantParams[0] || p[1] != tt.wantParams[1] {
+				t.Errorf("tag = %q, want %q", p, tt.wantParams)
+			}
+			if string(got.Body) != tt.wantBody {
+				t.Errorf("Body = %s, want %s", got.Body, tt.wantBody)
+			}
+			// the request it was given is left alone
+			if req.Headers["Authorization"] != "Bearer {{.token}}" || req.QueryParams["tag"][0] != "{{.tag}}" {
+				t.Errorf("Request() changed the request it was given: %+v", req)
+			}
+		})
+	}
+}
@@ -0,0 +1,4 @@
+// version is the version of swarm, kept in one place so that saved results can record it
+package version
+
+const Version = "0.1.0"
@@ -7,6 +7,8 @@ import (
 
 	"github.com/jonny-burkholder/swarm/cmd/benchmark"
 	"github.com/jonny-burkholder/swarm/cmd/compare"
+	"github.com/jonny-burkholder/swarm/cmd/swarm"
+	"github.com/jonny-burkholder/swarm/internal/version"
 )
 
 func main() {
@@ -36,6 +38,8 @@ func main() {
 		err = runBenchmark(os.Args[2:], verbose, quiet)
 	case "compare", "comp":
 		err = runCompare(os.Args[2:], verbose, quiet)
+	case "swarm", "break":
+		err = runSwarm(os.Args[2:], verbose, quiet)
 	case "help", "-h", "--help":
 		printUsage()
 		return
@@ -78,6 +82,7 @@ func runBenchmark(args []string, verbose, quiet bool) error {
 	}
 	if quiet {
 		cmd.LogLevel = "error"
+		cmd.Progress = "off"
 	}
 
 	return cmd.Run()
@@ -107,6 +112,35 @@ func runCompare(args []string, verbose, quiet bool) error {
 	return cmd.Run(remainingArgs)
 }
 
+func runSwarm(args []string, verbose, quiet bool) error {
+	cmd := swarm.NewSwarmCommand()
+
+	// Create flag set for swarm command
+	fs := flag.NewFlagSet("swarm", flag.ExitOnError)
+	cmd.SetupFlags(fs)
+
+	// Add global flags to the command flag set
+	fs.BoolVar(&verbose, "verbose", verbose, "Enable verbose output")
+	fs.BoolVar(&verbose, "v", verbose, "Enable verbose output (short)")
+	fs.BoolVar(&quiet, "quiet", quiet, "Suppress all output except errors")
+	fs.BoolVar(&quiet, "q", quiet, "Suppress all output except errors (short)")
+
+	// Parse flags
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	// Apply global flags
+	if verbose && cmd.LogLevel == "info" {
+		cmd.LogLevel = "debug"
+	}
+	if quiet {
+		cmd.LogLevel = "error"
+	}
+
+	return cmd.Run()
+}
+
 func printUsage() {
 	fmt.Println("swarm - The ultimate API testing and benchmarking tool")
 	fmt.Println()
@@ -116,6 +150,7 @@ func printUsage() {
 	fmt.Println("Available Commands:")
 	fmt.Println("  benchmark, bench    Run API benchmarks")
 	fmt.Println("  compare, comp       Compare benchmark results")
+	fmt.Println("  swarm, break        Add load until the API breaks")
 	fmt.Println("  help               Show this help message")
 	fmt.Println("  version            Show version information")
 	fmt.Println()
@@ -127,5 +162,5 @@ func printUsage() {
 }
 
 func printVersion() {
-	fmt.Println("swarm version 0.1.0")
+	fmt.Printf("swarm version %s\n", version.Version)
 }
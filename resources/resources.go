@@ -0,0 +1,9 @@
+// resources are files swarm needs at runtime, built into the binary so it works from anywhere
+package resources
+
+import _ "embed"
+
+// CompareHTML is the template for html compare reports
+//
+//go:embed default/html/compare.html.tmpl
+var CompareHTML string